@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiKeyHeader is the header of the static API keys (see services.APIKeyHeader). The services package is not
+// imported to keep the Google Cloud clients out of the CLI
+const apiKeyHeader = "X-API-Key"
+
+// client sends the requests to the EventSync endpoints and decodes the data of the JSON envelope of the responses in
+// the models types
+type client struct {
+	baseURL    string
+	apiKey     string
+	token      string
+	httpClient *http.Client
+}
+
+// apiError is the error of a request rejected by EventSync, with the error of the JSON envelope of the response
+type apiError struct {
+	StatusCode int
+	Detail     *models.ErrorDetail
+}
+
+// Error returns the HTTP status code and the error code and message of the response
+func (e *apiError) Error() string {
+	if e.Detail == nil {
+		return fmt.Sprintf("request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("request failed with status %d: %s %s", e.StatusCode, e.Detail.Code, e.Detail.Message)
+}
+
+// newClient creates the client of the EventSync instance at the baseURL. The admin and ingestion endpoints are
+// authenticated with the API key or, if set, the Google ID token
+func newClient(baseURL string, apiKey string, token string, timeout time.Duration) *client {
+	return &client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		token:      token,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// doJSON sends the request with the value in JSON body, if not nil, and decodes the data of the response in the result
+func (c *client) doJSON(ctx context.Context, method string, path string, query url.Values, value interface{}, result interface{}) error {
+	var body io.Reader
+	headers := http.Header{}
+	if value != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		headers.Set("Content-Type", "application/json")
+	}
+	_, err := c.do(ctx, method, path, query, headers, body, result)
+	return err
+}
+
+// do sends the request and returns the JSON envelope of the response, with its data decoded in the result if not nil.
+// The responses out of the 2xx range are returned as an apiError
+func (c *client) do(ctx context.Context, method string, path string, query url.Values, headers http.Header, body io.Reader, result interface{}) (envelope *models.Response, err error) {
+	requestURL := c.baseURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return
+	}
+	for name, values := range headers {
+		request.Header[name] = values
+	}
+	request.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.apiKey != "" {
+		request.Header.Set(apiKeyHeader, c.apiKey)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	envelope = &models.Response{Data: result}
+	decodeErr := json.NewDecoder(response.Body).Decode(envelope)
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return nil, &apiError{StatusCode: response.StatusCode, Detail: envelope.Error}
+	}
+	if decodeErr != nil {
+		return nil, errors.New(fmt.Sprintf("invalid JSON response of %s %s with error %s", method, path, decodeErr))
+	}
+	return envelope, nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filterFlags are the time range and eventKeys flags of the commands
+type filterFlags struct {
+	startDate string
+	endDate   string
+	eventKeys string
+}
+
+// addFilterFlags adds the time range and eventKeys flags to the flag set
+func addFilterFlags(fs *flag.FlagSet, filter *filterFlags) {
+	fs.StringVar(&filter.startDate, "start-date", "", "beginning of the time range, in RFC3339 format")
+	fs.StringVar(&filter.endDate, "end-date", "", "end of the time range, in RFC3339 format")
+	fs.StringVar(&filter.eventKeys, "event-keys", "", "subset of endpoints eventKey, comma separated")
+}
+
+// eventFilter builds the EventFilter of the flags. The unset flags are left unset
+func (f *filterFlags) eventFilter() (filter models.EventFilter, err error) {
+	if filter.StartDate, err = parseTime("start-date", f.startDate); err != nil {
+		return
+	}
+	if filter.EndDate, err = parseTime("end-date", f.endDate); err != nil {
+		return
+	}
+	for _, eventKey := range strings.Split(f.eventKeys, ",") {
+		if eventKey = strings.TrimSpace(eventKey); eventKey != "" {
+			filter.EventKeys = append(filter.EventKeys, eventKey)
+		}
+	}
+	return
+}
+
+// query returns the query parameters of the flags
+func (f *filterFlags) query() (query url.Values, err error) {
+	filter, err := f.eventFilter()
+	if err != nil {
+		return
+	}
+	query = url.Values{}
+	setTimeParam(query, "startDate", filter.StartDate)
+	setTimeParam(query, "endDate", filter.EndDate)
+	if len(filter.EventKeys) > 0 {
+		query.Set("eventKeys", strings.Join(filter.EventKeys, ","))
+	}
+	return
+}
+
+// sendCommand sends a test event to the endpoint of the eventKey and prints the ingestion response
+func sendCommand(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error {
+	method := fs.String("method", http.MethodPost, "HTTP method of the event")
+	data := fs.String("data", "", "body of the event")
+	dataFile := fs.String("data-file", "", "file of the body of the event, - for the standard input")
+	var headers, queryParams stringsFlag
+	fs.Var(&headers, "header", "header of the event, in \"name:value\" format (repeatable)")
+	fs.Var(&queryParams, "query", "query parameter of the event, in \"name=value\" format (repeatable)")
+	positional, err := parseArgs(fs, args, options, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	body := strings.NewReader(*data)
+	if *dataFile != "" {
+		if *data != "" {
+			return errors.New("the --data and --data-file flags are exclusive")
+		}
+		content, err := readDataFile(*dataFile)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(string(content))
+	}
+
+	requestHeaders := http.Header{}
+	for _, header := range headers {
+		name, value, found := strings.Cut(header, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return errors.New(fmt.Sprintf("the header %q must be in \"name:value\" format", header))
+		}
+		requestHeaders.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	query := url.Values{}
+	for _, queryParam := range queryParams {
+		name, value, found := strings.Cut(queryParam, "=")
+		if !found || name == "" {
+			return errors.New(fmt.Sprintf("the query parameter %q must be in \"name=value\" format", queryParam))
+		}
+		query.Add(name, value)
+	}
+
+	response, err := options.client().do(ctx, strings.ToUpper(*method), "/event/"+url.PathEscape(positional[0]), query, requestHeaders, body, nil)
+	if err != nil {
+		return err
+	}
+	return printResult(stdout, options.output, response, func(w io.Writer) { printSendTable(w, *response) })
+}
+
+// configCommand prints the configuration of the instance
+func configCommand(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error {
+	if _, err := parseArgs(fs, args, options, 0, 0); err != nil {
+		return err
+	}
+
+	config := models.EventSyncConfig{}
+	if err := options.client().doJSON(ctx, http.MethodGet, "/config", nil, nil, &config); err != nil {
+		return err
+	}
+	return printResult(stdout, options.output, config, func(w io.Writer) { printConfigTable(w, config) })
+}
+
+// statusCommand prints the sync readiness status, now or as of a date
+func statusCommand(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error {
+	asOf := fs.String("as-of", "", "date of the status evaluation, in RFC3339 format. Now if omitted")
+	if _, err := parseArgs(fs, args, options, 0, 0); err != nil {
+		return err
+	}
+
+	asOfDate, err := parseTime("as-of", *asOf)
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	setTimeParam(query, "asOf", asOfDate)
+
+	syncStatus := models.SyncStatus{}
+	if err = options.client().doJSON(ctx, http.MethodGet, "/status", query, nil, &syncStatus); err != nil {
+		return err
+	}
+	return printResult(stdout, options.output, syncStatus, func(w io.Writer) { printStatusTable(w, syncStatus) })
+}
+
+// triggerCommand triggers the event sync message of the time range and prints what has been sent
+func triggerCommand(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error {
+	filter := filterFlags{}
+	addFilterFlags(fs, &filter)
+	respectConditions := fs.Bool("respect-conditions", false, "trigger only if the endpoints conditions are met")
+	keepEventAfterTrigger := fs.String("keep-event-after-trigger", "", "override of the keepEventAfterTrigger configuration: true or false")
+	if _, err := parseArgs(fs, args, options, 0, 0); err != nil {
+		return err
+	}
+
+	triggerRequest := models.TriggerRequest{RespectConditions: *respectConditions}
+	var err error
+	if triggerRequest.EventFilter, err = filter.eventFilter(); err != nil {
+		return err
+	}
+	if triggerRequest.KeepEventAfterTrigger, err = parseBool("keep-event-after-trigger", *keepEventAfterTrigger); err != nil {
+		return err
+	}
+
+	triggerResponse := models.TriggerResponse{}
+	if err = options.client().doJSON(ctx, http.MethodPost, "/trigger", nil, triggerRequest, &triggerResponse); err != nil {
+		return err
+	}
+	return printResult(stdout, options.output, triggerResponse, func(w io.Writer) { printTriggerTable(w, triggerResponse) })
+}
+
+// resetCommand sets the events of the time range as already exported and prints what has been reset
+func resetCommand(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error {
+	filter := filterFlags{}
+	addFilterFlags(fs, &filter)
+	if _, err := parseArgs(fs, args, options, 0, 0); err != nil {
+		return err
+	}
+
+	resetRequest := models.ResetRequest{}
+	var err error
+	if resetRequest.EventFilter, err = filter.eventFilter(); err != nil {
+		return err
+	}
+
+	resetResponse := models.ResetResponse{}
+	if err = options.client().doJSON(ctx, http.MethodPost, "/reset", nil, resetRequest, &resetResponse); err != nil {
+		return err
+	}
+	return printResult(stdout, options.output, resetResponse, func(w io.Writer) { printResetTable(w, resetResponse) })
+}
+
+// eventsCommand prints a page of the stored events, or all the pages
+func eventsCommand(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error {
+	filter := filterFlags{}
+	addFilterFlags(fs, &filter)
+	exported := fs.String("exported", "", "export state of the events: true or false. Both if omitted")
+	var headers stringsFlag
+	fs.Var(&headers, "header", "header that the events must have, in \"name:value\" format (repeatable)")
+	content := fs.String("content", "", "string that the event content must contain")
+	pageSize := fs.Int("page-size", 0, "maximal number of events of the page")
+	pageToken := fs.String("page-token", "", "cursor of the page, returned by the previous page")
+	all := fs.Bool("all", false, "get all the pages")
+	if _, err := parseArgs(fs, args, options, 0, 0); err != nil {
+		return err
+	}
+
+	query, err := filter.query()
+	if err != nil {
+		return err
+	}
+	exportedValue, err := parseBool("exported", *exported)
+	if err != nil {
+		return err
+	}
+	if exportedValue != nil {
+		query.Set("exported", strconv.FormatBool(*exportedValue))
+	}
+	for _, header := range headers {
+		query.Add("header", header)
+	}
+	if *content != "" {
+		query.Set("content", *content)
+	}
+	if *pageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(*pageSize))
+	}
+	if *pageToken != "" {
+		query.Set("pageToken", *pageToken)
+	}
+
+	c := options.client()
+	eventPage := models.EventPage{Events: make([]models.EventItem, 0)}
+	for {
+		page := models.EventPage{}
+		if err = c.doJSON(ctx, http.MethodGet, "/events", query, nil, &page); err != nil {
+			return err
+		}
+		eventPage.Events = append(eventPage.Events, page.Events...)
+		eventPage.NextPageToken = page.NextPageToken
+		if !*all || page.NextPageToken == "" {
+			break
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+	return printResult(stdout, options.output, eventPage, func(w io.Writer) { printEventsTable(w, eventPage) })
+}
+
+// historyCommand prints the trigger history records, or the record of the eventID
+func historyCommand(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error {
+	startDate := fs.String("start-date", "", "beginning of the time range, in RFC3339 format")
+	endDate := fs.String("end-date", "", "end of the time range, in RFC3339 format")
+	status := fs.String("status", "", "outcome of the latest delivery: SUCCESS or FAILURE")
+	limit := fs.Int("limit", 0, "maximal number of records, the most recent first")
+	positional, err := parseArgs(fs, args, options, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	c := options.client()
+	if len(positional) == 1 {
+		history := models.TriggerHistory{}
+		if err = c.doJSON(ctx, http.MethodGet, "/history/"+url.PathEscape(positional[0]), nil, nil, &history); err != nil {
+			return err
+		}
+		return printResult(stdout, options.output, history, func(w io.Writer) { printHistoryTable(w, []models.TriggerHistory{history}) })
+	}
+
+	filter := models.HistoryFilter{Status: models.DeliveryStatusType(strings.ToUpper(*status)), Limit: *limit}
+	if filter.StartDate, err = parseTime("start-date", *startDate); err != nil {
+		return err
+	}
+	if filter.EndDate, err = parseTime("end-date", *endDate); err != nil {
+		return err
+	}
+	query := url.Values{}
+	setTimeParam(query, "startDate", filter.StartDate)
+	setTimeParam(query, "endDate", filter.EndDate)
+	if filter.Status != "" {
+		query.Set("status", string(filter.Status))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	histories := make([]models.TriggerHistory, 0)
+	if err = c.doJSON(ctx, http.MethodGet, "/history", query, nil, &histories); err != nil {
+		return err
+	}
+	return printResult(stdout, options.output, histories, func(w io.Writer) { printHistoryTable(w, histories) })
+}
+
+// readDataFile returns the content of the file, or of the standard input for "-"
+func readDataFile(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+// parseTime returns the RFC3339 time value of the flag name, or nil if it is not set
+func parseTime(name string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("the --%s flag %q must be in RFC3339 format", name, value))
+	}
+	return &t, nil
+}
+
+// parseBool returns the boolean value of the flag name, or nil if it is not set
+func parseBool(name string, value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("the --%s flag %q must be a boolean", name, value))
+	}
+	return &b, nil
+}
+
+// setTimeParam sets the query parameter name in RFC3339 format, if the time is set
+func setTimeParam(query url.Values, name string, t *time.Time) {
+	if t != nil {
+		query.Set(name, t.Format(time.RFC3339Nano))
+	}
+}
@@ -0,0 +1,183 @@
+// eventsyncctl drives a running EventSync instance over HTTP: send test events, show the configuration and the sync
+// status, trigger, reset, list the events and the trigger history. The results are printed as tables or in JSON.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// commandUsage is the description of the commands, displayed when a command is invalid
+const commandUsage = `Usage: eventsyncctl <command> [flags]
+
+Commands:
+  send <eventKey>      send a test event to the endpoint
+  config               show the configuration, with the secrets redacted
+  status               show the sync readiness status
+  trigger              trigger the event sync message of a time range
+  reset                set the events of a time range as already exported
+  events               list the stored events
+  history [<eventID>]  list the trigger history, or show one record
+
+Common flags, also set by the environment variables:
+  --url <url>          EventSync URL (EVENTSYNC_URL, default http://localhost:8080)
+  --api-key <key>      API key of the endpoints role (EVENTSYNC_API_KEY)
+  --token <token>      Google ID token, sent as Bearer token instead of the API key (EVENTSYNC_TOKEN)
+  -o, --output <fmt>   output format: table (default) or json
+  --timeout <duration> request timeout (default 30s)
+
+Run "eventsyncctl <command> --help" for the flags of the command.
+`
+
+// Output formats of the results
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// errUsage is returned by the commands when the arguments are invalid. The usage has already been printed
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of the CLI
+type command struct {
+	// usage is the synopsis of the command arguments
+	usage string
+	// run adds the flags of the command to the flag set, parses the arguments, sends the requests and prints the
+	// result
+	run func(ctx context.Context, fs *flag.FlagSet, options *globalOptions, args []string, stdout io.Writer) error
+}
+
+// commands are the subcommands of the CLI, per name
+var commands = map[string]command{
+	"send":    {usage: "send <eventKey> [--method POST] [--data <text> | --data-file <file|->] [--header name:value]... [--query name=value]...", run: sendCommand},
+	"config":  {usage: "config", run: configCommand},
+	"status":  {usage: "status [--as-of <RFC3339>]", run: statusCommand},
+	"trigger": {usage: "trigger [--start-date <RFC3339>] [--end-date <RFC3339>] [--event-keys <k1,k2>] [--respect-conditions] [--keep-event-after-trigger true|false]", run: triggerCommand},
+	"reset":   {usage: "reset [--start-date <RFC3339>] [--end-date <RFC3339>] [--event-keys <k1,k2>]", run: resetCommand},
+	"events":  {usage: "events [--start-date <RFC3339>] [--end-date <RFC3339>] [--event-keys <k1,k2>] [--exported true|false] [--header name:value]... [--content <text>] [--page-size <n>] [--page-token <token> | --all]", run: eventsCommand},
+	"history": {usage: "history [<eventID>] [--start-date <RFC3339>] [--end-date <RFC3339>] [--status SUCCESS|FAILURE] [--limit <n>]", run: historyCommand},
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command of the arguments and returns the exit code: 0 on success, 1 if the request failed and 2 if
+// the arguments are invalid
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stderr, commandUsage)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+	cmd, found := commands[args[0]]
+	if !found {
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], commandUsage)
+		return 2
+	}
+
+	options := &globalOptions{}
+	err := cmd.run(ctx, newFlagSet(args[0], cmd.usage, stderr, options), options, args[1:], stdout)
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintf(stderr, "eventsyncctl %s: %s\n", args[0], err)
+		return 1
+	}
+}
+
+// globalOptions are the connection and output flags of all the commands
+type globalOptions struct {
+	url     string
+	apiKey  string
+	token   string
+	output  string
+	timeout time.Duration
+}
+
+// newFlagSet creates the flag set of the command, with the common flags. The default values of the connection flags
+// are read from the environment variables
+func newFlagSet(name string, usage string, stderr io.Writer, options *globalOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: eventsyncctl %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&options.url, "url", getEnv("EVENTSYNC_URL", "http://localhost:8080"), "EventSync URL")
+	fs.StringVar(&options.apiKey, "api-key", os.Getenv("EVENTSYNC_API_KEY"), "API key of the endpoints role")
+	fs.StringVar(&options.token, "token", os.Getenv("EVENTSYNC_TOKEN"), "Google ID token, sent as Bearer token instead of the API key")
+	fs.StringVar(&options.output, "output", outputTable, "output format: table or json")
+	fs.StringVar(&options.output, "o", outputTable, "output format: table or json (shorthand)")
+	fs.DurationVar(&options.timeout, "timeout", 30*time.Second, "request timeout")
+	return fs
+}
+
+// parseArgs parses the flags of the command, before and after the positional arguments, and checks the number of
+// positional arguments and the output format
+func parseArgs(fs *flag.FlagSet, args []string, options *globalOptions, minPositional int, maxPositional int) (positional []string, err error) {
+	for {
+		if err = fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) < minPositional || len(positional) > maxPositional {
+		fmt.Fprintf(fs.Output(), "invalid number of arguments %d\n", len(positional))
+		fs.Usage()
+		return nil, errUsage
+	}
+	if options.output != outputTable && options.output != outputJSON {
+		fmt.Fprintf(fs.Output(), "invalid output format %q, table or json expected\n", options.output)
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+// client creates the client of the EventSync instance of the options
+func (o *globalOptions) client() *client {
+	return newClient(o.url, o.apiKey, o.token, o.timeout)
+}
+
+// getEnv returns the value of the environment variable, or the defaultValue if it is not set
+func getEnv(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// stringsFlag is a repeatable string flag
+type stringsFlag []string
+
+// String returns the values, comma separated
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set adds the value
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
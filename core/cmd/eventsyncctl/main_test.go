@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/guillaumeblaquiere/eventsync/core/eventsync"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	cfg, err := eventsync.LoadConfig(`{
+		"serviceName": "eventsyncctl",
+		"trigger": {"type": "window", "observationPeriod": 3600, "keepEventAfterTrigger": false},
+		"endpoints": [{"eventKey": "entry1", "acceptedHttpMethods": ["POST"], "eventToSend": "ALL", "minNbOfOccurrence": 1},
+			{"eventKey": "entry2"}],
+		"targetPubSub": {"topic": "projects/test/topics/eventsyncctl"},
+		"auth": {"admin": {"apiKeys": ["admin-key"]}}
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	target, err := eventsync.NewWriterTarget(filepath.Join(t.TempDir(), "messages.jsonl"))
+	if err != nil {
+		t.Fatalf("NewWriterTarget() error = %v", err)
+	}
+	now := time.Date(2022, 03, 28, 10, 0, 0, 0, time.UTC)
+	engine, err := eventsync.New(cfg, eventsync.WithStore(eventsync.NewMemoryStore()), eventsync.WithTarget(target), eventsync.WithClock(eventsync.FixedClock(now)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	t.Setenv("EVENTSYNC_URL", server.URL)
+	t.Setenv("EVENTSYNC_API_KEY", "admin-key")
+
+	// The steps run in order on the same instance
+	tests := []struct {
+		name         string
+		args         []string
+		wantExitCode int
+		wantOutput   []string
+	}{
+		{name: "no command", args: []string{}, wantExitCode: 2},
+		{name: "unknown command", args: []string{"unknown"}, wantExitCode: 2},
+		{name: "missing eventKey", args: []string{"send"}, wantExitCode: 2},
+		{name: "invalid output", args: []string{"status", "-o", "yaml"}, wantExitCode: 2},
+		{name: "invalid date", args: []string{"trigger", "--start-date", "yesterday"}, wantExitCode: 1},
+		{name: "unknown eventKey", args: []string{"send", "entry3"}, wantExitCode: 1},
+		{name: "invalid API key", args: []string{"status", "--api-key", "wrong"}, wantExitCode: 1},
+		{name: "config", args: []string{"config"}, wantOutput: []string{"eventsyncctl  window", "entry1     POST"}},
+		{name: "send first event", args: []string{"send", "entry1", "--data", `{"id": 1}`, "--header", "X-Source:cli"}, wantOutput: []string{"CONDITIONS_NOT_MET"}},
+		{name: "status", args: []string{"status"}, wantOutput: []string{"2022-03-28T10:00:00Z", "entry1     1       1                true", "entry2     0       1                false"}},
+		{name: "status as of a date", args: []string{"status", "--as-of", "2022-03-28T09:00:00Z"}, wantOutput: []string{"entry1     0"}},
+		{name: "send second event", args: []string{"send", "entry2", "--data", "test2"}, wantOutput: []string{"TRIGGERED"}},
+		{name: "events", args: []string{"events", "--event-keys", "entry1,entry2", "--exported", "true"}, wantOutput: []string{"DOCUMENT ID", `entry1     POST    {"id": 1}`, "entry2     POST    test2"}},
+		{name: "history", args: []string{"history", "--status", "success"}, wantOutput: []string{"SUCCESS  2       1"}},
+		{name: "send third event", args: []string{"send", "--data", "test3", "entry1"}, wantOutput: []string{"CONDITIONS_NOT_MET"}},
+		{name: "trigger respecting the conditions", args: []string{"trigger", "--respect-conditions"}, wantOutput: []string{"false      -", "the endpoints conditions are not met", "entry1     1"}},
+		{name: "reset", args: []string{"reset", "--event-keys", "entry1"}, wantOutput: []string{"2022-03-28T09:00:00Z  2022-03-28T10:00:00Z", "entry1     1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			if exitCode := run(context.Background(), tt.args, stdout, stderr); exitCode != tt.wantExitCode {
+				t.Fatalf("run() = %d, want %d, stderr %s", exitCode, tt.wantExitCode, stderr)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("run() output = %s, want %q", stdout, want)
+				}
+			}
+		})
+	}
+
+	t.Run("JSON output", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		if exitCode := run(context.Background(), []string{"history", "-o", "json"}, stdout, &bytes.Buffer{}); exitCode != 0 {
+			t.Fatalf("run() = %d, want 0", exitCode)
+		}
+		histories := make([]models.TriggerHistory, 0)
+		if err := json.Unmarshal(stdout.Bytes(), &histories); err != nil {
+			t.Fatalf("invalid JSON output %s with error %v", stdout, err)
+		}
+		if len(histories) != 1 || len(histories[0].DocumentIDs) != 2 {
+			t.Errorf("run() histories = %v, want 1 history of 2 events", histories)
+		}
+	})
+}
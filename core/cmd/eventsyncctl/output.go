@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// maxContentLength is the maximal number of characters of the event content in the tables
+const maxContentLength = 60
+
+// printResult prints the value in indented JSON, or as the table written by the printTable function. The table
+// columns are separated by tabs and aligned on the output
+func printResult(w io.Writer, output string, value interface{}, printTable func(w io.Writer)) error {
+	if output == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	printTable(tw)
+	return tw.Flush()
+}
+
+// printSendTable prints the ingestion response of an event
+func printSendTable(w io.Writer, response models.Response) {
+	fmt.Fprintln(w, "STATUS\tEVENT KEY\tDOCUMENT ID\tTRIGGER\tEVENT ID\tERROR")
+	triggerStatus, eventID, triggerError := "-", "-", "-"
+	if response.Trigger != nil {
+		triggerStatus = string(response.Trigger.Status)
+		eventID = orDash(response.Trigger.EventID)
+		if response.Trigger.Error != nil {
+			triggerError = fmt.Sprintf("%s %s", response.Trigger.Error.Code, response.Trigger.Error.Message)
+		}
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", response.Status, response.EventKey, orDash(response.DocumentID), triggerStatus, eventID, triggerError)
+}
+
+// printConfigTable prints the trigger and the target of the configuration, then its endpoints
+func printConfigTable(w io.Writer, config models.EventSyncConfig) {
+	fmt.Fprintln(w, "SERVICE NAME\tTRIGGER\tOBSERVATION PERIOD\tKEEP EVENTS\tTOPIC")
+	triggerType, observationPeriod, keepEvents, topic := "-", "-", "-", "-"
+	if config.Trigger != nil {
+		triggerType = string(config.Trigger.Type)
+		observationPeriod = (time.Duration(config.Trigger.ObservationPeriod) * time.Second).String()
+		keepEvents = fmt.Sprint(config.Trigger.KeepEventAfterTrigger)
+	}
+	if config.TargetPubSub != nil {
+		topic = config.TargetPubSub.Topic
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", config.ServiceName, triggerType, observationPeriod, keepEvents, topic)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "EVENT KEY\tMETHODS\tMIN OCCURRENCES\tEVENT TO SEND\tVERIFICATION")
+	for _, endpoint := range config.Endpoints {
+		methods := make([]string, 0, len(endpoint.AcceptedHttpMethods))
+		for _, method := range endpoint.AcceptedHttpMethods {
+			methods = append(methods, string(method))
+		}
+		sort.Strings(methods)
+		verification := "-"
+		if endpoint.Verification != nil {
+			verification = string(endpoint.Verification.Type)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", endpoint.EventKey, orDash(strings.Join(methods, ",")), endpoint.MinNbOfOccurrence, endpoint.EventToSend, verification)
+	}
+}
+
+// printStatusTable prints the sync readiness status, then the readiness of each endpoint
+func printStatusTable(w io.Writer, syncStatus models.SyncStatus) {
+	fmt.Fprintln(w, "SERVICE NAME\tDATE\tTRIGGER\tCONDITIONS MET\tWOULD TRIGGER")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\n", syncStatus.ServiceName, formatTime(&syncStatus.Date), syncStatus.TriggerType, syncStatus.ConditionsMet, syncStatus.WouldTrigger)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "EVENT KEY\tEVENTS\tMIN OCCURRENCES\tREADY\tLAST EVENT\tSIGNATURE FAILURES")
+	for _, endpoint := range syncStatus.Endpoints {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%t\t%s\t%d\n", endpoint.EventKey, endpoint.NumberOfEvents, endpoint.MinNbOfOccurrence, endpoint.Ready, formatTime(endpoint.LastEventDate), endpoint.SignatureFailures)
+	}
+}
+
+// printTriggerTable prints the result of a manual trigger, then the number of events per eventKey
+func printTriggerTable(w io.Writer, triggerResponse models.TriggerResponse) {
+	fmt.Fprintln(w, "TRIGGERED\tEVENT ID\tSTART DATE\tEND DATE\tKEEP EVENTS\tREASON")
+	fmt.Fprintf(w, "%t\t%s\t%s\t%s\t%t\t%s\n", triggerResponse.Triggered, orDash(triggerResponse.EventID), formatTime(&triggerResponse.StartDate), formatTime(&triggerResponse.EndDate), triggerResponse.KeepEventAfterTrigger, orDash(triggerResponse.Reason))
+
+	fmt.Fprintln(w)
+	printEventCounts(w, "EVENTS", triggerResponse.NumberOfEvents)
+}
+
+// printResetTable prints the time range of a manual reset, then the number of events reset per eventKey
+func printResetTable(w io.Writer, resetResponse models.ResetResponse) {
+	fmt.Fprintln(w, "START DATE\tEND DATE")
+	fmt.Fprintf(w, "%s\t%s\n", formatTime(&resetResponse.StartDate), formatTime(&resetResponse.EndDate))
+
+	fmt.Fprintln(w)
+	printEventCounts(w, "EVENTS RESET", resetResponse.NumberOfEventsReset)
+}
+
+// printEventsTable prints the events of the page with their document ID, then the token of the next page if any
+func printEventsTable(w io.Writer, eventPage models.EventPage) {
+	fmt.Fprintln(w, "DATETIME\tDOCUMENT ID\tEVENT KEY\tMETHOD\tCONTENT")
+	for _, item := range eventPage.Events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", formatTime(&item.Datetime), item.DocumentID, item.EventKey, item.Method, formatContent(item.Content))
+	}
+	if eventPage.NextPageToken != "" {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "next page token: %s\n", eventPage.NextPageToken)
+	}
+}
+
+// printHistoryTable prints the trigger history records, with the target of the latest delivery
+func printHistoryTable(w io.Writer, histories []models.TriggerHistory) {
+	fmt.Fprintln(w, "EVENT ID\tDATE\tSTATUS\tEVENTS\tDELIVERIES\tTARGET")
+	for _, history := range histories {
+		target := "-"
+		if len(history.Deliveries) > 0 {
+			target = history.Deliveries[len(history.Deliveries)-1].Target
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", history.EventID, formatTime(&history.Date), history.Status, len(history.DocumentIDs), len(history.Deliveries), target)
+	}
+}
+
+// printEventCounts prints the number of events per eventKey, sorted by eventKey
+func printEventCounts(w io.Writer, title string, counts map[string]int) {
+	eventKeys := make([]string, 0, len(counts))
+	for eventKey := range counts {
+		eventKeys = append(eventKeys, eventKey)
+	}
+	sort.Strings(eventKeys)
+
+	fmt.Fprintf(w, "EVENT KEY\t%s\n", title)
+	for _, eventKey := range eventKeys {
+		fmt.Fprintf(w, "%s\t%d\n", eventKey, counts[eventKey])
+	}
+}
+
+// formatTime returns the time in RFC3339 format, or "-" if it is not set
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatContent returns the event content on one line, in JSON if it is not a string, truncated to maxContentLength
+// characters
+func formatContent(content interface{}) string {
+	text, isString := content.(string)
+	if !isString && content != nil {
+		data, _ := json.Marshal(content)
+		text = string(data)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxContentLength {
+		return string(runes[:maxContentLength-3]) + "..."
+	}
+	return orDash(text)
+}
+
+// orDash returns the value, or "-" if it is empty, to keep the table columns aligned
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
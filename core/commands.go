@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"os"
+	"strings"
+)
+
+// commandUsage is the description of the commands, displayed when a command is invalid
+const commandUsage = `Usage:
+  eventsync                   start the EventSync server
+  eventsync --local [--local-target <stdout|file>]
+                              start the server without Google Cloud: in-memory events, messages written to the target
+  eventsync validate <file>   check the JSON or YAML configuration file, offline, and exit
+  eventsync schema [<file>]   write the JSON Schema of the configuration in the file, or in the standard output
+`
+
+// runCommand runs the command in the arguments, if any, and returns the exit code. If there is no command, false is
+// returned and the server must be started.
+func runCommand(args []string) (exitCode int, isCommand bool) {
+	// The server flags, like --local, are not commands
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return 0, false
+	}
+	switch args[0] {
+	case "validate":
+		if len(args) != 2 {
+			fmt.Fprint(os.Stderr, commandUsage)
+			return 2, true
+		}
+		return validateCommand(args[1]), true
+	case "schema":
+		if len(args) > 2 {
+			fmt.Fprint(os.Stderr, commandUsage)
+			return 2, true
+		}
+		return schemaCommand(args[1:]), true
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", args[0], commandUsage)
+		return 2, true
+	}
+}
+
+// validateCommand runs the configuration checks on the file, without GCP credentials. The environment variables
+// referenced in the configuration must be set.
+func validateCommand(file string) int {
+	config, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "impossible to read the configuration file %s with error: %s\n", file, err)
+		return 1
+	}
+	err = services.ValidateConfig(string(config))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration %s with error: %s\n", file, err)
+		return 1
+	}
+	return 0
+}
+
+// schemaCommand writes the generated JSON Schema of the configuration in the file in argument, or in the standard
+// output
+func schemaCommand(args []string) int {
+	schema, err := services.GenerateConfigSchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "impossible to generate the configuration schema with error: %s\n", err)
+		return 1
+	}
+	if len(args) == 0 {
+		os.Stdout.Write(schema)
+		return 0
+	}
+	err = os.WriteFile(args[0], schema, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "impossible to write the configuration schema in %s with error: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"cloud.google.com/go/pubsub"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/eventsync"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The end-to-end tests post the events through the real handlers and check the published event sync messages. They
+// run on the Firestore and Pub/Sub emulators if FIRESTORE_EMULATOR_HOST and PUBSUB_EMULATOR_HOST are set, else on the
+// local mode (in-memory store and file target):
+//
+//	gcloud emulators firestore start --host-port=localhost:8081
+//	gcloud beta emulators pubsub start --host-port=localhost:8085
+//	FIRESTORE_EMULATOR_HOST=localhost:8081 PUBSUB_EMULATOR_HOST=localhost:8085 go test -run TestE2E .
+
+// e2eTopic is the topic of the end-to-end tests. It is created on the Pub/Sub emulator
+const e2eTopic = "projects/" + utils.EmulatorProjectID + "/topics/eventsync-e2e"
+
+// e2eMessageTimeout is the maximal time to wait for the published messages
+const e2eMessageTimeout = 10 * time.Second
+
+// e2eMessage is a published event sync message
+type e2eMessage struct {
+	attributes     map[string]string
+	eventGenerated models.EventGenerated
+}
+
+// e2eStep is a request sent to the server after the clock advance, with the expected response
+type e2eStep struct {
+	advance           time.Duration
+	method            string
+	path              string
+	body              string
+	wantStatusCode    int
+	wantTriggerStatus models.TriggerOutcomeType
+}
+
+func TestE2E(t *testing.T) {
+	t.Setenv(services.ForceAsyncEventTriggerEnvVar, "false")
+
+	tests := []struct {
+		name string
+		// withEntry3 adds the endpoint entry3 with 2 minimum occurrences
+		withEntry3 bool
+		steps      []e2eStep
+		// wantEvents is the number of events per eventKey of each published message
+		wantEvents []map[string]int
+	}{
+		{
+			name: "window trigger when all the endpoints have events",
+			steps: []e2eStep{
+				{method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodPost, path: "/event/entry2", body: "test2", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeTriggered},
+			},
+			wantEvents: []map[string]int{{"entry1": 1, "entry2": 1}},
+		},
+		{
+			name:       "minimum occurrences",
+			withEntry3: true,
+			steps: []e2eStep{
+				{method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodPost, path: "/event/entry3", body: "test3", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodPost, path: "/event/entry2", body: "test2", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodPost, path: "/event/entry3", body: "test3", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeTriggered},
+			},
+			wantEvents: []map[string]int{{"entry1": 1, "entry2": 1, "entry3": 2}},
+		},
+		{
+			name: "events exported only once",
+			steps: []e2eStep{
+				{method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodPost, path: "/event/entry2", body: "test2", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeTriggered},
+				{method: http.MethodPost, path: "/event/entry2", body: "test2", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeTriggered},
+			},
+			wantEvents: []map[string]int{{"entry1": 1, "entry2": 1}, {"entry1": 1, "entry2": 1}},
+		},
+		{
+			name: "events out of the observation period",
+			steps: []e2eStep{
+				{method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{advance: 2 * time.Hour, method: http.MethodPost, path: "/event/entry2", body: "test2", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{advance: time.Minute, method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeTriggered},
+			},
+			wantEvents: []map[string]int{{"entry1": 1, "entry2": 1}},
+		},
+		{
+			name: "manual trigger",
+			steps: []e2eStep{
+				{method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodGet, path: "/trigger", wantStatusCode: http.StatusOK},
+			},
+			wantEvents: []map[string]int{{"entry1": 1, "entry2": 0}},
+		},
+		{
+			name: "method not accepted",
+			steps: []e2eStep{
+				{method: http.MethodGet, path: "/event/entry1", wantStatusCode: http.StatusMethodNotAllowed},
+				{method: http.MethodPost, path: "/event/entry1", body: "test1", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeConditionsNotMet},
+				{method: http.MethodPost, path: "/event/entry2", body: "test2", wantStatusCode: http.StatusOK, wantTriggerStatus: models.TriggerOutcomeTriggered},
+			},
+			wantEvents: []map[string]int{{"entry1": 1, "entry2": 1}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			// Each test has its own collections
+			serviceName := fmt.Sprintf("e2e-%d-%d", time.Now().UnixNano(), i)
+			cfg := newE2EConfig(t, serviceName, tt.withEntry3)
+
+			clock := &e2eClock{now: time.Date(2022, 03, 28, 0, 0, 0, 0, time.UTC)}
+			targetOpts, receive := newE2ETarget(t, ctx, serviceName)
+			engine, err := eventsync.New(cfg, append(append(newE2EStore(), targetOpts...), eventsync.WithClock(clock))...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			// The engine is mounted behind a prefix, like in a host service
+			mux := http.NewServeMux()
+			mux.Handle("/sync/", http.StripPrefix("/sync", engine))
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			for _, step := range tt.steps {
+				clock.advance(step.advance)
+				sendE2EStep(t, server.URL+"/sync", step)
+			}
+
+			messages := receive(len(tt.wantEvents))
+			if len(messages) != len(tt.wantEvents) {
+				t.Fatalf("%d messages published, want %d", len(messages), len(tt.wantEvents))
+			}
+			for j, message := range messages {
+				if message.attributes["serviceName"] != serviceName {
+					t.Errorf("message %d serviceName attribute = %q, want %q", j, message.attributes["serviceName"], serviceName)
+				}
+				if message.eventGenerated.ServiceName != serviceName || message.eventGenerated.EventID == "" {
+					t.Errorf("message %d = %+v, want the eventID and the serviceName %q", j, message.eventGenerated, serviceName)
+				}
+				for eventKey, want := range tt.wantEvents[j] {
+					eventList := message.eventGenerated.Events[eventKey]
+					if eventList == nil || eventList.NumberOfEvents != want || len(eventList.Events) != want {
+						t.Errorf("message %d has %+v events for %q, want %d", j, eventList, eventKey, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// newE2EConfig loads and checks the window trigger configuration of the serviceName, with 2 endpoints and a third
+// one with 2 minimum occurrences if withEntry3
+func newE2EConfig(t *testing.T, serviceName string, withEntry3 bool) *eventsync.Config {
+	endpoints := `{"eventKey": "entry1", "acceptedHttpMethods": ["POST"], "eventToSend": "ALL", "minNbOfOccurrence": 1},
+		{"eventKey": "entry2"}`
+	if withEntry3 {
+		endpoints += `, {"eventKey": "entry3", "eventToSend": "ALL", "minNbOfOccurrence": 2}`
+	}
+	cfg, err := eventsync.LoadConfig(fmt.Sprintf(`{
+		"serviceName": %q,
+		"trigger": {"type": "window", "observationPeriod": 3600, "keepEventAfterTrigger": false},
+		"endpoints": [%s],
+		"targetPubSub": {"topic": %q}
+	}`, serviceName, endpoints, e2eTopic))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	return cfg
+}
+
+// newE2EStore returns the option of the in-memory store, none on the emulator: the default Firestore store is used
+func newE2EStore() []eventsync.Option {
+	if utils.IsFirestoreEmulator() {
+		return nil
+	}
+	return []eventsync.Option{eventsync.WithStore(eventsync.NewMemoryStore())}
+}
+
+// newE2ETarget returns the option of the writer target in a temporary file, none on the emulator: the default Pub/Sub
+// target is used. The receive function returns the published messages, waiting for the expected number of messages
+// on Pub/Sub
+func newE2ETarget(t *testing.T, ctx context.Context, serviceName string) (opts []eventsync.Option, receive func(want int) []e2eMessage) {
+	if !utils.IsPubSubEmulator() {
+		path := filepath.Join(t.TempDir(), "messages.jsonl")
+		target, err := eventsync.NewWriterTarget(path)
+		if err != nil {
+			t.Fatalf("NewWriterTarget() error = %v", err)
+		}
+		return []eventsync.Option{eventsync.WithTarget(target)}, func(want int) []e2eMessage {
+			return readE2EMessages(t, path)
+		}
+	}
+
+	client, err := pubsub.NewClient(ctx, utils.EmulatorProjectID)
+	if err != nil {
+		t.Fatalf("impossible to create the Pub/Sub client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	topicSplit := strings.Split(e2eTopic, "/")
+	topic := client.Topic(topicSplit[3])
+	if exists, err := topic.Exists(ctx); err != nil || !exists {
+		if topic, err = client.CreateTopic(ctx, topicSplit[3]); err != nil {
+			t.Fatalf("impossible to create the topic: %v", err)
+		}
+	}
+	// The subscription of the test receives only the messages of its serviceName
+	subscription, err := client.CreateSubscription(ctx, serviceName, pubsub.SubscriptionConfig{
+		Topic:  topic,
+		Filter: fmt.Sprintf("attributes.serviceName = %q", serviceName),
+	})
+	if err != nil {
+		t.Fatalf("impossible to create the subscription: %v", err)
+	}
+	t.Cleanup(func() { subscription.Delete(context.Background()) })
+
+	return nil, func(want int) []e2eMessage {
+		return receiveE2EMessages(t, subscription, want)
+	}
+}
+
+// e2eClock is the clock of the engine, moved forward by the steps
+type e2eClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now returns the current time of the test
+func (c *e2eClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// advance moves the clock forward
+func (c *e2eClock) advance(duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(duration)
+}
+
+// readE2EMessages reads the messages written in the target file
+func readE2EMessages(t *testing.T, target string) (messages []e2eMessage) {
+	file, err := os.Open(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("impossible to open the target: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := struct {
+			Attributes map[string]string     `json:"attributes"`
+			Data       models.EventGenerated `json:"data"`
+		}{}
+		if err = json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid message line %q: %v", scanner.Text(), err)
+		}
+		messages = append(messages, e2eMessage{attributes: line.Attributes, eventGenerated: line.Data})
+	}
+	return
+}
+
+// receiveE2EMessages receives the messages of the subscription until the expected number or the timeout, the oldest
+// first
+func receiveE2EMessages(t *testing.T, subscription *pubsub.Subscription, want int) (messages []e2eMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), e2eMessageTimeout)
+	defer cancel()
+	received := make(chan e2eMessage)
+	go func() {
+		err := subscription.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+			msg.Ack()
+			message := e2eMessage{attributes: msg.Attributes}
+			if err := json.Unmarshal(msg.Data, &message.eventGenerated); err != nil {
+				t.Errorf("invalid message %q: %v", msg.Data, err)
+			}
+			select {
+			case received <- message:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil {
+			t.Errorf("impossible to receive the messages: %v", err)
+		}
+	}()
+
+	for len(messages) < want {
+		select {
+		case message := <-received:
+			messages = append(messages, message)
+		case <-ctx.Done():
+			return
+		}
+	}
+	// The messages are not ordered on the subscription
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].eventGenerated.Date.Before(messages[j].eventGenerated.Date)
+	})
+	return
+}
+
+// sendE2EStep sends the request of the step and checks the response
+func sendE2EStep(t *testing.T, serverURL string, step e2eStep) {
+	request, err := http.NewRequest(step.method, serverURL+step.path, strings.NewReader(step.body))
+	if err != nil {
+		t.Fatalf("impossible to create the request: %v", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", step.method, step.path, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != step.wantStatusCode {
+		t.Fatalf("%s %s status = %d, want %d", step.method, step.path, response.StatusCode, step.wantStatusCode)
+	}
+	if step.wantTriggerStatus == "" {
+		return
+	}
+
+	eventResponse := models.Response{}
+	if err = json.NewDecoder(response.Body).Decode(&eventResponse); err != nil {
+		t.Fatalf("%s %s invalid response: %v", step.method, step.path, err)
+	}
+	if eventResponse.Trigger == nil || eventResponse.Trigger.Status != step.wantTriggerStatus {
+		t.Errorf("%s %s trigger = %+v, want the status %s", step.method, step.path, eventResponse.Trigger, step.wantTriggerStatus)
+	}
+}
@@ -2,50 +2,105 @@ package main
 
 import (
 	"context"
-	"eventsync/handlers"
-	"eventsync/services"
-	"log"
+	"flag"
+	"github.com/guillaumeblaquiere/eventsync/core/eventsync"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"log/slog"
 	"net/http"
 	"os"
 )
 
+//go:generate go run . schema config.schema.json
+
+// localMode replaces Firestore and Pub/Sub by an in-memory store and the localTarget, for the local development
+var localMode = flag.Bool("local", false, "run without Google Cloud: in-memory event store, messages written to --local-target")
+
+// localTarget is the destination of the event sync messages in local mode: stdout or a file path
+var localTarget = flag.String("local-target", services.StdoutTarget, "destination of the event sync messages in local mode: stdout or a file path")
+
 func main() {
 
-	config := os.Getenv(services.ConfigEnvVar)
+	// The offline commands (validate, schema) don't start the server
+	if exitCode, isCommand := runCommand(os.Args[1:]); isCommand {
+		os.Exit(exitCode)
+	}
+	flag.Parse()
 
-	configService, err := services.LoadConfig(config)
+	ctx := context.Background()
+
+	// The logs are JSON lines with the Cloud Logging fields. The trace correlation requires the project ID. In local
+	// mode, they are written on the standard error to not mix with the event sync messages of the stdout target
+	logLevel, err := utils.ParseLogLevel(os.Getenv(utils.LogLevelEnvVar))
 	if err != nil {
-		log.Fatalf("impossible to load the configuration with error: %s\n", err)
+		fatal("invalid log level", err)
 	}
+	runtime := utils.GetRuntime()
+	logOutput := os.Stdout
+	if *localMode {
+		logOutput = os.Stderr
+	}
+	logger := slog.New(utils.NewCloudLoggingHandler(logOutput, logLevel, runtime.ProjectID()))
+	slog.SetDefault(logger)
+	slog.Info("runtime detected", "runtime", runtime.Name(), "projectID", runtime.ProjectID())
 
-	err = configService.CheckConfig()
+	// The configuration is read in the source, if any, and watched for changes. Else the env var is used
+	config := os.Getenv(services.ConfigEnvVar)
+	configVersion := ""
+	var configSource services.ConfigSource
+	if configSourceLocation := os.Getenv(services.ConfigSourceEnvVar); configSourceLocation != "" {
+		configSource, err = services.NewConfigSource(ctx, configSourceLocation)
+		if err != nil {
+			fatal("impossible to create the configuration source", err)
+		}
+		config, configVersion, err = configSource.Read(ctx)
+		if err != nil {
+			fatal("impossible to read the configuration source", err, "source", configSource.String())
+		}
+	}
+
+	cfg, err := eventsync.LoadConfig(config)
 	if err != nil {
-		log.Fatalf("invalid configuration with error %s\n", err)
+		fatal("invalid configuration", err)
 	}
+	slog.SetDefault(logger.With(slog.String("serviceName", cfg.ServiceName())))
 
-	ctx := context.Background()
+	// The spans of the requests, the storage and the publications follow the events up to the event sync messages
+	if err = utils.SetupTracing(cfg.ServiceName()); err != nil {
+		fatal("impossible to set up the tracing", err)
+	}
 
-	eventService, err := services.NewEventService(ctx, configService)
-	if err != nil {
-		log.Fatalf("impossible to create the event service with error %s\n", err)
+	// The local mode requires no Google Cloud project: the events are lost at the stop and no index is created. All
+	// the logs include the service name
+	opts := []eventsync.Option{eventsync.WithLogger(logger)}
+	if *localMode {
+		slog.Warn("local mode, the events are kept in memory", "target", *localTarget)
+		target, err := eventsync.NewWriterTarget(*localTarget)
+		if err != nil {
+			fatal("impossible to create the local target", err)
+		}
+		opts = append(opts, eventsync.WithStore(eventsync.NewMemoryStore()), eventsync.WithTarget(target))
 	}
 
-	triggerService, err := services.NewTriggerService(ctx, configService, eventService)
+	engine, err := eventsync.New(cfg, opts...)
 	if err != nil {
-		log.Fatalf("impossible to create the trigger service with error %s\n", err)
+		fatal("impossible to create the EventSync engine", err)
 	}
 
-	configHandler := handlers.ConfigHandler{ConfigService: configService}
-	eventHandler := handlers.EventHandler{EventService: eventService, ConfigService: configService, TriggerService: triggerService}
-	resetHandler := handlers.ResetHandler{ConfigService: configService, EventService: eventService}
-	triggerHandler := handlers.TriggerHandler{ConfigService: configService, TriggerService: triggerService, EventService: eventService}
-
-	// To accept event, a dedicated endpoints is reserved to this.
-	http.HandleFunc(services.EventPathPrefix, eventHandler.Event)
-	http.HandleFunc("/config", configHandler.Config)
-	http.HandleFunc("/trigger", triggerHandler.Trigger)
-	http.HandleFunc("/reset", resetHandler.Reset)
+	if configSource != nil {
+		watchInterval, err := services.GetConfigWatchInterval()
+		if err != nil {
+			fatal("invalid configuration watch interval", err)
+		}
+		go engine.WatchConfig(ctx, configSource, configVersion, watchInterval)
+	}
 
-	http.ListenAndServe(":8080", nil)
+	err = http.ListenAndServe(":8080", engine)
+	fatal("the server stopped", err)
+}
 
+// fatal logs the error with the critical severity and stops the service
+func fatal(message string, err error, args ...any) {
+	slog.Log(context.Background(), utils.LevelCritical, message, append([]any{"error", err}, args...)...)
+	os.Exit(1)
 }
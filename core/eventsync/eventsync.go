@@ -0,0 +1,297 @@
+// Package eventsync embeds an EventSync engine in a Go service. The engine is an http.Handler which serves all the
+// EventSync endpoints (/event/<eventKey>, /trigger, /status,...) and can be mounted behind the router and the
+// middlewares of the host service:
+//
+//	config, err := eventsync.LoadConfig(configText)
+//	engine, err := eventsync.New(config, eventsync.WithStore(eventsync.NewMemoryStore()))
+//	mux.Handle("/sync/", http.StripPrefix("/sync", engine))
+//
+// Without option, the events are stored in Firestore and the event sync messages published on the targetPubSub topic
+// of the configuration, like the standalone service.
+package eventsync
+
+import (
+	"context"
+	"github.com/guillaumeblaquiere/eventsync/core/handlers"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Config is a checked EventSync configuration (see LoadConfig)
+type Config struct {
+	config      string
+	serviceName string
+}
+
+// Target delivers the event sync messages
+type Target interface {
+	// Target returns the name of the destination of the messages, recorded in the deliveries of the trigger history
+	Target() string
+	// Publish sends the message data with its attributes and returns the ID of the published message
+	Publish(ctx context.Context, data []byte, attributes map[string]string) (messageID string, err error)
+}
+
+// Clock provides the current time of the engine: the event dates, the observation windows and the sync message dates
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+}
+
+// Role is the set of endpoints protected by the same authentication
+type Role = services.Role
+
+const (
+	// RoleIngestion is the role of the events endpoints
+	RoleIngestion = services.RoleIngestion
+	// RoleAdmin is the role of the administration endpoints
+	RoleAdmin = services.RoleAdmin
+	// RoleMetrics is the role of the metrics endpoint, with the admin authentication if not configured
+	RoleMetrics = services.RoleMetrics
+)
+
+// Verifier verifies the credentials of a request and returns the authenticated principal. ErrNoCredentials must be
+// returned if the request doesn't contain the credentials handled by the verifier, ErrPermissionDenied if the
+// principal is not allowed.
+type Verifier = services.AuthVerifier
+
+// ErrNoCredentials must be returned by a Verifier when the request doesn't contain its type of credentials
+var ErrNoCredentials = services.ErrNoCredentials
+
+// ErrPermissionDenied must be returned by a Verifier when the credentials are valid but the principal is not allowed
+var ErrPermissionDenied = services.ErrPermissionDenied
+
+// ConfigSource is the location of a configuration to watch (see NewConfigSource)
+type ConfigSource interface {
+	// Read returns the configuration content and its version. The version changes when the content changes
+	Read(ctx context.Context) (config string, version string, err error)
+	// String returns the location of the configuration
+	String() string
+	// Close releases the client of the source, if any
+	Close() error
+}
+
+// Engine is an EventSync instance. It serves the EventSync endpoints as an http.Handler
+type Engine struct {
+	configService *services.ConfigService
+	handler       http.Handler
+}
+
+// options are the dependencies of the engine, set by the Option functions
+type options struct {
+	store     Store
+	target    Target
+	logger    *slog.Logger
+	clock     Clock
+	verifiers map[Role][]Verifier
+}
+
+// Option customizes the dependencies of the engine
+type Option func(*options)
+
+// WithStore replaces the default Firestore store, for instance by NewMemoryStore
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithTarget replaces the default Pub/Sub target of the configuration, for instance by NewWriterTarget
+func WithTarget(target Target) Option {
+	return func(o *options) {
+		o.target = target
+	}
+}
+
+// WithLogger sets the logger of the engine, instead of the log/slog default logger. The logs of the engine include the
+// serviceName attribute. The runtime detection, shared by the engines of the process, logs with the default logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithClock replaces the system clock, for instance by a FixedClock in the tests of the host service
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithVerifier adds a verifier of the host service, for instance on its own sessions, to the verifiers of the role built
+// from the auth configuration. The role requires then an authentication, even if it is not defined in the
+// configuration.
+func WithVerifier(role Role, verifier Verifier) Option {
+	return func(o *options) {
+		if o.verifiers == nil {
+			o.verifiers = make(map[Role][]Verifier)
+		}
+		o.verifiers[role] = append(o.verifiers[role], verifier)
+	}
+}
+
+// LoadConfig parses the JSON or YAML configuration, the same as the CONFIG of the standalone service, with the
+// "${VAR}" environment variables references, and checks it. The errors are located in the configuration text. The
+// configuration format, described by the JSON Schema of the configuration, is the stable interface of the engine: a
+// host service which builds its configuration marshals it in JSON.
+func LoadConfig(config string) (cfg *Config, err error) {
+	// The configuration is only checked here, the engine logs its loading with its own logger (see New)
+	configService, err := services.LoadConfig(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		return
+	}
+	if err = configService.CheckConfig(); err != nil {
+		return
+	}
+	return &Config{config: config, serviceName: configService.GetConfig().ServiceName}, nil
+}
+
+// ServiceName returns the serviceName of the configuration
+func (c *Config) ServiceName() string {
+	return c.serviceName
+}
+
+// NewWriterTarget creates the Target which writes the messages as JSON lines on the standard output ("stdout") or
+// appends them to the file path
+func NewWriterTarget(target string) (Target, error) {
+	return services.NewWriterPublisher(target)
+}
+
+// FixedClock returns a Clock which always returns the date
+func FixedClock(date time.Time) Clock {
+	return services.FixedClock(date)
+}
+
+// NewConfigSource creates the ConfigSource of the location: a file path, gs://<bucket>/<object> or
+// firestore://<collection>/<document>
+func NewConfigSource(ctx context.Context, location string) (ConfigSource, error) {
+	return services.NewConfigSource(ctx, location)
+}
+
+// New creates the engine of the config. The default store and target are created on Firestore and Pub/Sub, with the
+// runtime credentials, or on the emulators.
+func New(cfg *Config, opts ...Option) (engine *Engine, err error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var logger *slog.Logger
+	if o.logger != nil {
+		logger = o.logger.With(slog.String("serviceName", cfg.ServiceName()))
+	}
+	configService, err := services.LoadConfig(cfg.config, logger)
+	if err != nil {
+		return
+	}
+	if err = configService.CheckConfig(); err != nil {
+		return
+	}
+	if o.clock != nil {
+		configService.SetClock(o.clock)
+	}
+
+	ctx := context.Background()
+	store := o.store
+	if store == nil {
+		if store, err = services.NewFirestoreStore(ctx, utils.GetFirestoreProjectID(), cfg.ServiceName(), configService.GetLogger()); err != nil {
+			return
+		}
+	}
+	var target services.MessagePublisher = o.target
+	if o.target == nil {
+		if target, err = services.NewPubSubPublisher(ctx, configService); err != nil {
+			return
+		}
+	}
+
+	handler, err := newHandler(ctx, configService, store, target, o.verifiers)
+	if err != nil {
+		return
+	}
+	return &Engine{configService: configService, handler: handler}, nil
+}
+
+// ServeHTTP serves the EventSync endpoints
+func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.handler.ServeHTTP(w, r)
+}
+
+// ReloadConfig replaces the configuration by the new JSON or YAML one, if it is valid. The serviceName can't be
+// changed.
+func (e *Engine) ReloadConfig(config string) error {
+	return e.configService.ReloadConfig(config)
+}
+
+// WatchConfig checks the source every interval and reloads the configuration when its version changes, until the
+// context is done. The source is then closed. The initialVersion is the version of the configuration of the engine.
+func (e *Engine) WatchConfig(ctx context.Context, source ConfigSource, initialVersion string, interval time.Duration) {
+	e.configService.WatchConfig(ctx, source, initialVersion, interval)
+}
+
+// newHandler creates the services on the store and the target, and the handler of all the EventSync endpoints. The
+// requests are measured and traced first. The CORS policy applies before the authentication, to answer the preflight
+// requests without credentials. The verifiers are added to the ones of the auth configuration
+func newHandler(ctx context.Context, configService *services.ConfigService, store services.EventStore, target services.MessagePublisher, verifiers map[Role][]Verifier) (handler http.Handler, err error) {
+	eventService := services.NewEventService(configService, store)
+	historyService := services.NewHistoryService(configService, eventService)
+	triggerService := services.NewTriggerService(configService, eventService, historyService, target)
+
+	adminService := services.NewAdminService(configService, eventService)
+
+	limitService := services.NewLimitService(configService)
+
+	authService, err := services.NewAuthService(ctx, configService)
+	if err != nil {
+		return
+	}
+	for role, roleVerifiers := range verifiers {
+		for _, verifier := range roleVerifiers {
+			authService.AddVerifier(role, verifier)
+		}
+	}
+
+	configHandler := handlers.ConfigHandler{ConfigService: configService}
+	eventHandler := handlers.EventHandler{EventService: eventService, ConfigService: configService, TriggerService: triggerService, LimitService: limitService}
+	resetHandler := handlers.ResetHandler{ConfigService: configService, EventService: eventService}
+	triggerHandler := handlers.TriggerHandler{ConfigService: configService, TriggerService: triggerService, EventService: eventService}
+	historyHandler := handlers.HistoryHandler{HistoryService: historyService, TriggerService: triggerService, ConfigService: configService}
+	eventsHandler := handlers.EventsHandler{EventService: eventService, HistoryService: historyService, ConfigService: configService}
+	adminHandler := handlers.AdminHandler{AdminService: adminService, ConfigService: configService}
+	statusHandler := handlers.StatusHandler{EventService: eventService, ConfigService: configService}
+
+	mux := http.NewServeMux()
+	metrics := eventService.Metrics()
+	wrap := func(path string, role services.Role, handlerFunc http.HandlerFunc) http.HandlerFunc {
+		return metrics.InstrumentHandler(path, handlers.Trace(path, handlers.Cors(configService, handlers.Authenticate(configService, authService, role, handlerFunc))))
+	}
+	handle := func(path string, role services.Role, handlerFunc http.HandlerFunc) {
+		mux.HandleFunc(path, wrap(path, role, handlerFunc))
+	}
+
+	// To accept event, a dedicated endpoints is reserved to this. The stored events are retrieved on the same path
+	// prefix, with the admin role
+	ingestEvent := wrap(services.EventPathPrefix, services.RoleIngestion, eventHandler.Event)
+	getStoredEvent := wrap(handlers.StoredEventRoute, services.RoleAdmin, eventsHandler.StoredEvent)
+	mux.HandleFunc(services.EventPathPrefix, func(w http.ResponseWriter, r *http.Request) {
+		if handlers.IsStoredEventPath(eventService, r.URL.Path) {
+			getStoredEvent(w, r)
+			return
+		}
+		ingestEvent(w, r)
+	})
+	handle("/config", services.RoleAdmin, configHandler.Config)
+	handle("/trigger", services.RoleAdmin, triggerHandler.Trigger)
+	handle("/reset", services.RoleAdmin, resetHandler.Reset)
+	handle(handlers.HistoryPath, services.RoleAdmin, historyHandler.History)
+	handle(handlers.HistoryPathPrefix, services.RoleAdmin, historyHandler.History)
+	handle(handlers.EventsPath, services.RoleAdmin, eventsHandler.Events)
+	handle("/admin/events", services.RoleAdmin, adminHandler.Events)
+	handle("/status", services.RoleAdmin, statusHandler.Status)
+	handle("/metrics", services.RoleMetrics, metrics.Handler().ServeHTTP)
+
+	return mux, nil
+}
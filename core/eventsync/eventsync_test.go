@@ -0,0 +1,174 @@
+package eventsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingStore is a Store of the host service, which counts the stored events and the updates
+type countingStore struct {
+	Store
+	events  int
+	updates int
+}
+
+// AddEvent counts and stores the event
+func (c *countingStore) AddEvent(ctx context.Context, collection string, event models.Event) (string, error) {
+	c.events++
+	return c.Store.AddEvent(ctx, collection, event)
+}
+
+// UpdateEvents counts and applies the update
+func (c *countingStore) UpdateEvents(ctx context.Context, collection string, documentIDs []string, update EventUpdate) error {
+	c.updates++
+	return c.Store.UpdateEvents(ctx, collection, documentIDs, update)
+}
+
+func TestNew_WithStore(t *testing.T) {
+	cfg, err := LoadConfig(`{
+		"serviceName": "embedded",
+		"trigger": {"type": "window", "observationPeriod": 3600, "keepEventAfterTrigger": false},
+		"endpoints": [{"eventKey": "entry1"}, {"eventKey": "entry2"}],
+		"targetPubSub": {"topic": "projects/test/topics/embedded"}
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	target, err := NewWriterTarget(filepath.Join(t.TempDir(), "messages.jsonl"))
+	if err != nil {
+		t.Fatalf("NewWriterTarget() error = %v", err)
+	}
+	store := &countingStore{Store: NewMemoryStore()}
+	engine, err := New(cfg, WithStore(store), WithTarget(target), WithClock(FixedClock(time.Date(2022, 03, 28, 10, 0, 0, 0, time.UTC))))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		eventKey    string
+		wantTrigger models.TriggerOutcomeType
+	}{
+		{eventKey: "entry1", wantTrigger: models.TriggerOutcomeConditionsNotMet},
+		{eventKey: "entry2", wantTrigger: models.TriggerOutcomeTriggered},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/event/"+tt.eventKey, strings.NewReader("test")))
+		response := models.Response{}
+		if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatalf("invalid response with error %v", err)
+		}
+		if response.Trigger == nil || response.Trigger.Status != tt.wantTrigger {
+			t.Errorf("ServeHTTP(%s) trigger = %v, want %s", tt.eventKey, response.Trigger, tt.wantTrigger)
+		}
+	}
+	// The events are exported after the trigger through the store of the host service
+	if store.events != 2 || store.updates == 0 {
+		t.Errorf("store events = %d, updates = %d, want 2 events and their updates", store.events, store.updates)
+	}
+}
+
+func TestNew_WithLogger(t *testing.T) {
+	defaultLogs := &bytes.Buffer{}
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(defaultLogs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	cfg, err := LoadConfig(`{
+		"serviceName": "logged",
+		"trigger": {"type": "none", "observationPeriod": 3600},
+		"endpoints": [{"eventKey": "entry1"}, {"eventKey": "entry2"}],
+		"targetPubSub": {"topic": "projects/test/topics/logged"}
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	target, err := NewWriterTarget(filepath.Join(t.TempDir(), "messages.jsonl"))
+	if err != nil {
+		t.Fatalf("NewWriterTarget() error = %v", err)
+	}
+	engineLogs := &bytes.Buffer{}
+	engine, err := New(cfg, WithStore(NewMemoryStore()), WithTarget(target), WithLogger(slog.New(slog.NewJSONHandler(engineLogs, nil))))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/event/entry1", strings.NewReader("test")))
+
+	if !strings.Contains(engineLogs.String(), `"msg":"event stored"`) || !strings.Contains(engineLogs.String(), `"serviceName":"logged"`) {
+		t.Errorf("engine logs = %s, want the event storage with the serviceName", engineLogs)
+	}
+	if strings.Contains(defaultLogs.String(), "event stored") {
+		t.Errorf("default logs = %s, want no log of the engine", defaultLogs)
+	}
+}
+
+// sessionVerifier is a Verifier of the host service, which accepts the requests with its session header
+type sessionVerifier struct{}
+
+func (s sessionVerifier) Verify(r *http.Request) (string, error) {
+	switch r.Header.Get("X-Session") {
+	case "":
+		return "", ErrNoCredentials
+	case "ops":
+		return "ops", nil
+	default:
+		return "", ErrPermissionDenied
+	}
+}
+
+func TestNew_WithVerifier(t *testing.T) {
+	cfg, err := LoadConfig(`{
+		"serviceName": "verified",
+		"trigger": {"type": "none", "observationPeriod": 3600},
+		"endpoints": [{"eventKey": "entry1"}, {"eventKey": "entry2"}],
+		"targetPubSub": {"topic": "projects/test/topics/verified"}
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	target, err := NewWriterTarget(filepath.Join(t.TempDir(), "messages.jsonl"))
+	if err != nil {
+		t.Fatalf("NewWriterTarget() error = %v", err)
+	}
+	engine, err := New(cfg, WithStore(NewMemoryStore()), WithTarget(target), WithVerifier(RoleAdmin, sessionVerifier{}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		session    string
+		wantStatus int
+	}{
+		{name: "admin without session", path: "/status", wantStatus: http.StatusUnauthorized},
+		{name: "admin with session", path: "/status", session: "ops", wantStatus: http.StatusOK},
+		{name: "admin with denied session", path: "/status", session: "guest", wantStatus: http.StatusForbidden},
+		{name: "ingestion without session", path: "/event/entry1", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader("test"))
+			if tt.path == "/status" {
+				request.Method = http.MethodGet
+			}
+			if tt.session != "" {
+				request.Header.Set("X-Session", tt.session)
+			}
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP(%s) status = %d, want %d", tt.path, recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
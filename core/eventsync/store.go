@@ -0,0 +1,32 @@
+package eventsync
+
+import (
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+)
+
+// ErrEventNotFound must be returned by Store.GetEvent when the event doesn't exist
+var ErrEventNotFound = services.ErrEventNotFound
+
+// ErrHistoryNotFound must be returned by Store.GetHistory when the trigger history record doesn't exist
+var ErrHistoryNotFound = services.ErrHistoryNotFound
+
+// ErrHistoryAlreadyExists must be returned by Store.CreateHistory when a record already exists for the EventID
+var ErrHistoryAlreadyExists = services.ErrHistoryAlreadyExists
+
+// Store persists the events, the trigger history and the administration audit. It is the store interface of the
+// engine services, the methods are documented there.
+type Store = services.EventStore
+
+// StoreFilter selects the stored events. The empty fields don't filter the events
+type StoreFilter = services.EventStoreFilter
+
+// EventCursor is the position of the last read event: its date and its document ID
+type EventCursor = services.EventCursor
+
+// EventUpdate is the change to apply to stored events: deletion, or new exported state and/or eventKey
+type EventUpdate = services.EventUpdate
+
+// NewMemoryStore creates an in-memory Store: the data are lost at the stop of the host service
+func NewMemoryStore() Store {
+	return services.NewMemoryStore()
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"net/http"
+	"strings"
+)
+
+// AdminHandler is the URL request handler for the administration operations on the stored events
+type AdminHandler struct {
+	// AdminService is the service to perform the administration operations
+	AdminService *services.AdminService
+	// ConfigService is the service to manage the configuration of the current instance
+	ConfigService *services.ConfigService
+}
+
+// Events is the function to handle the administration operation request on the events. The operation is provided in
+// JSON body (see models.AdminRequest) and the audit record is returned.
+func (a *AdminHandler) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodPost, "perform an administration operation")
+		return
+	}
+
+	adminRequest := models.AdminRequest{}
+	_, err := decodeJSONBody(r, &adminRequest)
+	if err == nil {
+		adminRequest.Operation = models.AdminOperationType(strings.ToUpper(string(adminRequest.Operation)))
+		err = a.AdminService.CheckAdminRequest(&adminRequest)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidParameters, fmt.Sprintf("invalid administration operation with error %s", err))
+		return
+	}
+
+	auditRecord, err := a.AdminService.PerformAdminOperation(r.Context(), adminRequest, requestOrigin(r))
+	if err != nil {
+		a.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to perform the administration operation", "operation", adminRequest.Operation, "error", err)
+		writeJSON(w, http.StatusInternalServerError, models.Response{
+			Status: models.ResponseStatusError,
+			Error:  &models.ErrorDetail{Code: models.ErrorCodeInternal, Message: fmt.Sprintf("impossible to perform the administration operation with error %s", err)},
+			Data:   auditRecord,
+		})
+		return
+	}
+	writeData(w, auditRecord)
+}
+
+// requestOrigin returns the client address of the request, the first X-Forwarded-For value if set by a proxy. The
+// authenticated principal, if any, is added before the address.
+func requestOrigin(r *http.Request) string {
+	address := r.RemoteAddr
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		address = strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+	}
+	if principal := requestPrincipal(r); principal != "" {
+		return fmt.Sprintf("%s (%s)", principal, address)
+	}
+	return address
+}
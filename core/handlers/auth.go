@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"net/http"
+)
+
+// principalContextKey is the request context key of the authenticated principal
+type principalContextKey struct{}
+
+// Authenticate wraps the handler function with the authentication of the role. The requests without valid
+// credentials are rejected with a 401 status code, the principals not allowed with a 403. The authenticated principal
+// is available in the request context for the handler (see requestPrincipal). The rejections are logged with the
+// logger of the configService.
+func Authenticate(configService *services.ConfigService, authService *services.AuthService, role services.Role, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := authService.Authenticate(r, role)
+		if err != nil {
+			configService.GetLogger().WarnContext(r.Context(), "request rejected by the authentication", "method", r.Method, "path", r.URL.Path, "role", role, "error", err)
+			if errors.Is(err, services.ErrPermissionDenied) {
+				writeError(w, http.StatusForbidden, models.ErrorCodePermissionDenied, err.Error())
+				return
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, models.ErrorCodeUnauthenticated, fmt.Sprintf("valid credentials are required for the %s role: %s", role, err))
+			return
+		}
+		if principal != "" {
+			r = r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal))
+		}
+		handlerFunc(w, r)
+	}
+}
+
+// requestPrincipal returns the authenticated principal of the request, empty if the request is not authenticated
+func requestPrincipal(r *http.Request) string {
+	principal, _ := r.Context().Value(principalContextKey{}).(string)
+	return principal
+}
@@ -1,9 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
-	"eventsync/services"
-	"eventsync/utils"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
 	"net/http"
 )
 
@@ -13,11 +11,7 @@ type ConfigHandler struct {
 	ConfigService *services.ConfigService
 }
 
-// Config is the function to handle the config export request
+// Config is the function to handle the config export request. The secrets are redacted
 func (c *ConfigHandler) Config(w http.ResponseWriter, r *http.Request) {
-	utils.EnableCors(&w)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(c.ConfigService.GetConfig())
+	writeData(w, c.ConfigService.GetRedactedConfig())
 }
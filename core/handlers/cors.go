@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"net/http"
+)
+
+// Cors wraps the handler function with the CORS policy of the configuration. The allowed origins get the CORS
+// response headers, and the preflight requests are answered here, without calling the handler function: they are
+// never stored as events nor authenticated.
+func Cors(configService *services.ConfigService, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// The response depends on the origin, the caches must not share it
+		w.Header().Add("Vary", "Origin")
+		for name, values := range configService.GetCorsHeaders(origin, preflight) {
+			w.Header()[name] = values
+		}
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handlerFunc(w, r)
+	}
+}
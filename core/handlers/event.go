@@ -1,15 +1,28 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	"eventsync/services"
-	"eventsync/utils"
 	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
+// pubSubPushUserAgent is the User-Agent of the Pub/Sub push requests
+const pubSubPushUserAgent = "APIs-Google"
+
+// pubSubSubscriptionHeader is the header of the Pub/Sub push requests of the subscriptions without payload wrapper and
+// with the write metadata option
+const pubSubSubscriptionHeader = "X-Goog-Pubsub-Subscription-Name"
+
 // EventHandler is the URL request handler for the events' acquisition
 type EventHandler struct {
 	// EventService is the service to manage, store and retrieve events
@@ -18,18 +31,22 @@ type EventHandler struct {
 	ConfigService *services.ConfigService
 	// TriggerService is the service to manage the event generation and formatting
 	TriggerService *services.TriggerService
+	// LimitService is the service to enforce the rate and concurrency limits of the events
+	LimitService *services.LimitService
 }
 
-// Event is the function to handle the event acquisition request
+// Event is the function to handle the event acquisition request. The response is a JSON envelope (see
+// models.Response) with the ID of the stored event and the outcome of the trigger evaluation. As soon as the event is
+// stored, a 2xx status code is returned, even if the trigger fails, to prevent the senders (like Pub/Sub push
+// subscriptions) to retry and store the event twice. For the same reason, the permanent rejections of the Pub/Sub push
+// requests are acknowledged (see writeRejection).
 func (e *EventHandler) Event(w http.ResponseWriter, r *http.Request) {
-	utils.EnableCors(&w)
-
 	// extract the eventKey
 	eventKeyValue := services.ExtractEventKey(r.URL.Path)
 
 	if eventKeyValue == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "missing or incorrect eventKey in the path")
+		e.EventService.Metrics().IncEventsRejected("", services.RejectionUnknownEndpoint)
+		e.writeRejection(w, r, http.StatusNotFound, models.ErrorCodeUnknownEventKey, "missing or incorrect eventKey in the path")
 		return
 	}
 
@@ -37,60 +54,155 @@ func (e *EventHandler) Event(w http.ResponseWriter, r *http.Request) {
 	method := strings.ToUpper(r.Method)
 
 	// check if accepted endpoint
-	if err := e.EventService.MatchEndpoint(eventKeyValue, method); err == nil {
-
-		//If the query param match the configuration, store the formatted event
-		event, err := services.FormatEvent(eventKeyValue, r.URL.Query(), r.Header, r.Body, method)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, "incorrect event format")
+	if err := e.EventService.MatchEndpoint(eventKeyValue, method); err != nil {
+		if errors.Is(err, services.ErrMethodNotAccepted) {
+			e.EventService.Metrics().IncEventsRejected(eventKeyValue, services.RejectionMethodNotAllowed)
+			e.writeRejection(w, r, http.StatusMethodNotAllowed, models.ErrorCodeMethodNotAllowed, err.Error())
 			return
 		}
+		// The unknown eventKeys are not labelled, to not create a metric per invalid path
+		e.EventService.Metrics().IncEventsRejected("", services.RejectionUnknownEndpoint)
+		e.writeRejection(w, r, http.StatusNotFound, models.ErrorCodeUnknownEventKey, err.Error())
+		return
+	}
 
-		err = e.EventService.StoreEvent(r.Context(), event)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(w, "impossible to store the event %v in the collection %s, with error %s\n", event, e.ConfigService.GetConfig().ServiceName, err)
+	// The logs of the request processing include the eventKey
+	r = r.WithContext(utils.WithLogAttrs(r.Context(), slog.String("eventKey", eventKeyValue)))
+
+	// The body size is checked before the rate limits, to not consume a token for a rejected event
+	maxBodyBytes := e.LimitService.GetMaxBodyBytes(eventKeyValue)
+	if r.ContentLength > maxBodyBytes {
+		e.writePayloadTooLarge(w, r, eventKeyValue, maxBodyBytes)
+		return
+	}
+
+	release, retryAfter, err := e.LimitService.Acquire(eventKeyValue)
+	if err != nil {
+		e.ConfigService.GetLogger().WarnContext(r.Context(), "event rejected by the limits", "error", err)
+		e.EventService.Metrics().IncEventsRejected(eventKeyValue, services.RejectionRateLimited)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+		writeError(w, http.StatusTooManyRequests, models.ErrorCodeRateLimited, err.Error())
+		return
+	}
+	defer release()
+
+	// The body is read once, for the signature verification and the event. The chunked bodies are also limited
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			e.writePayloadTooLarge(w, r, eventKeyValue, maxBodyBytes)
 			return
 		}
-		fmt.Fprintf(w, "event correct stored to Firestore collection %s\n", e.ConfigService.GetConfig().ServiceName)
-
-		if e.ConfigService.IsAsyncEventTriggerProcessing() {
-			// perform async processing
-			fmt.Printf("post process event performed asynchronouly\n")
-			go e.postProcessEvent(r.Context())
-		} else {
-			fmt.Printf("post process event performed synchronouly\n")
-			err = e.postProcessEvent(r.Context())
-			if err != nil {
-				fmt.Fprintf(w, err.Error())
-			}
-		}
+		e.EventService.Metrics().IncEventsRejected(eventKeyValue, services.RejectionInvalidEvent)
+		e.writeRejection(w, r, http.StatusBadRequest, models.ErrorCodeInvalidEvent, "impossible to read the event body")
+		return
+	}
+	if err = e.EventService.VerifySignature(r.Context(), eventKeyValue, r.Header, body); err != nil {
+		e.writeRejection(w, r, http.StatusUnauthorized, models.ErrorCodeInvalidSignature, err.Error())
+		return
+	}
+
+	//If the query param match the configuration, store the formatted event
+	event, err := services.FormatEvent(eventKeyValue, e.EventService.Now(), r.URL.Query(), r.Header, io.NopCloser(bytes.NewReader(body)), method)
+	if err != nil {
+		e.ConfigService.GetLogger().WarnContext(r.Context(), "impossible to format the event", "error", err)
+		e.EventService.Metrics().IncEventsRejected(eventKeyValue, services.RejectionInvalidEvent)
+		e.writeRejection(w, r, http.StatusBadRequest, models.ErrorCodeInvalidEvent, "incorrect event format")
+		return
+	}
+	// The event sync message span will link to the reception span of the event
+	event.TraceParent = utils.TraceParent(r.Context())
+
+	documentID, err := e.EventService.StoreEvent(r.Context(), event)
+	if err != nil {
+		e.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to store the event", "error", err)
+		// The storage errors are transient, the sender can retry
+		writeError(w, http.StatusServiceUnavailable, models.ErrorCodeStorageUnavailable, fmt.Sprintf("impossible to store the event in the collection %s, with error %s", e.ConfigService.GetConfig().ServiceName, err))
+		return
+	}
+
+	r = r.WithContext(utils.WithLogAttrs(r.Context(), slog.String("documentID", documentID)))
+	response := models.Response{
+		EventKey:   eventKeyValue,
+		DocumentID: documentID,
+	}
+
+	if e.ConfigService.IsAsyncEventTriggerProcessing() {
+		// perform async processing. The request context is cancelled when the response is sent, only its values are kept
+		e.ConfigService.GetLogger().DebugContext(r.Context(), "post process event performed asynchronously")
+		go e.postProcessEvent(context.WithoutCancel(r.Context()))
+		response.Status = models.ResponseStatusAccepted
+		response.Trigger = &models.TriggerOutcome{Status: models.TriggerOutcomePending}
+		writeJSON(w, http.StatusAccepted, response)
 		return
+	}
 
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, err.Error())
+	e.ConfigService.GetLogger().DebugContext(r.Context(), "post process event performed synchronously")
+	triggerOutcome := e.postProcessEvent(r.Context())
+	response.Trigger = &triggerOutcome
+	response.Status = models.ResponseStatusOK
+	if triggerOutcome.Status == models.TriggerOutcomeFailed {
+		response.Status = models.ResponseStatusPartial
 	}
+	writeJSON(w, http.StatusOK, response)
+}
 
+// writePayloadTooLarge counts and writes the 413 error of the events bigger than the maximal body size
+func (e *EventHandler) writePayloadTooLarge(w http.ResponseWriter, r *http.Request, eventKey string, maxBodyBytes int64) {
+	e.EventService.Metrics().IncEventsRejected(eventKey, services.RejectionPayloadTooLarge)
+	e.writeRejection(w, r, http.StatusRequestEntityTooLarge, models.ErrorCodePayloadTooLarge, fmt.Sprintf("the event body exceeds the maximal size of %d bytes", maxBodyBytes))
+}
+
+// writeRejection writes the permanent rejection of the event request. A Pub/Sub push subscription retries all the
+// non-2xx responses until the message expiration, the rejection of a push request is therefore logged and acknowledged
+// with a 200, and the REJECTED status in the envelope. The other requests get the 4xx error.
+func (e *EventHandler) writeRejection(w http.ResponseWriter, r *http.Request, statusCode int, code models.ErrorCodeType, message string) {
+	if !isPubSubPush(r) {
+		writeError(w, statusCode, code, message)
+		return
+	}
+	e.ConfigService.GetLogger().WarnContext(r.Context(), "Pub/Sub push request rejected and acknowledged", "statusCode", statusCode, "code", code, "error", message)
+	writeJSON(w, http.StatusOK, models.Response{
+		Status: models.ResponseStatusRejected,
+		Error:  &models.ErrorDetail{Code: code, Message: message},
+	})
+}
+
+// isPubSubPush returns true if the request is sent by a Pub/Sub push subscription
+func isPubSubPush(r *http.Request) bool {
+	return strings.HasPrefix(r.UserAgent(), pubSubPushUserAgent) || r.Header.Get(pubSubSubscriptionHeader) != ""
 }
 
 // postProcessEvent performs processing after the correct storage of the event, like checking if an event sync has
-// to be generated
-func (e *EventHandler) postProcessEvent(ctx context.Context) (err error) {
+// to be generated. The outcome of the trigger evaluation is counted and returned.
+func (e *EventHandler) postProcessEvent(ctx context.Context) (triggerOutcome models.TriggerOutcome) {
+	defer func() {
+		e.EventService.Metrics().IncTriggerEvaluations(strings.ToLower(string(triggerOutcome.Status)))
+	}()
 
 	events, needTrigger, err := e.EventService.MeetTriggerConditions(ctx)
 	if err != nil {
-		return errors.New(fmt.Sprintf("impossible to check the trigger conditions with error: %s\n", err))
+		return e.failedTriggerOutcome(ctx, fmt.Sprintf("impossible to check the trigger conditions with error: %s", err))
 	}
 
-	if needTrigger {
-		err = e.TriggerService.TriggerEvent(ctx, events)
-		if err != nil {
-			return errors.New(fmt.Sprintf("impossible to perform the trigger with error %s\n", err))
-		}
-	} else {
-		return errors.New(fmt.Sprintf("no trigger done after the event storage\n"))
+	if !needTrigger {
+		e.ConfigService.GetLogger().DebugContext(ctx, "no trigger done after the event storage")
+		return models.TriggerOutcome{Status: models.TriggerOutcomeConditionsNotMet}
+	}
+
+	eventGenerated, err := e.TriggerService.TriggerEvent(ctx, events, e.ConfigService.GetConfig().Trigger.KeepEventAfterTrigger)
+	if err != nil {
+		return e.failedTriggerOutcome(ctx, fmt.Sprintf("impossible to perform the trigger with error %s", err))
+	}
+	return models.TriggerOutcome{Status: models.TriggerOutcomeTriggered, EventID: eventGenerated.EventID}
+}
+
+// failedTriggerOutcome logs the trigger error and returns the corresponding failed outcome
+func (e *EventHandler) failedTriggerOutcome(ctx context.Context, message string) models.TriggerOutcome {
+	e.ConfigService.GetLogger().ErrorContext(ctx, message)
+	return models.TriggerOutcome{
+		Status: models.TriggerOutcomeFailed,
+		Error:  &models.ErrorDetail{Code: models.ErrorCodeTriggerFailed, Message: message},
 	}
-	return
 }
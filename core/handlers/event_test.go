@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/guillaumeblaquiere/eventsync/core/eventsync"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cancelAwareStore is a Store which fails, like Firestore, when the context of the operation is cancelled
+type cancelAwareStore struct {
+	eventsync.Store
+}
+
+// ListEvents returns the context error if any, else the events
+func (c cancelAwareStore) ListEvents(ctx context.Context, collection string, filter eventsync.StoreFilter) ([]models.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Store.ListEvents(ctx, collection, filter)
+}
+
+func TestEventHandler_AsyncPostProcessing(t *testing.T) {
+	t.Setenv(services.ForceAsyncEventTriggerEnvVar, "true")
+	cfg, err := eventsync.LoadConfig(`{
+		"serviceName": "async",
+		"trigger": {"type": "window", "observationPeriod": 3600},
+		"endpoints": [{"eventKey": "entry1"}, {"eventKey": "entry2"}],
+		"targetPubSub": {"topic": "projects/test/topics/async"}
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	messagesPath := filepath.Join(t.TempDir(), "messages.jsonl")
+	target, err := eventsync.NewWriterTarget(messagesPath)
+	if err != nil {
+		t.Fatalf("NewWriterTarget() error = %v", err)
+	}
+	engine, err := eventsync.New(cfg, eventsync.WithStore(cancelAwareStore{eventsync.NewMemoryStore()}), eventsync.WithTarget(target))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	// The server cancels the request context at the end of the response, before the asynchronous post processing
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	for _, eventKey := range []string{"entry1", "entry2"} {
+		response, err := http.Post(server.URL+"/event/"+eventKey, "text/plain", strings.NewReader("test"))
+		if err != nil {
+			t.Fatalf("event %s error = %v", eventKey, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusAccepted {
+			t.Fatalf("event %s status code = %d, want %d", eventKey, response.StatusCode, http.StatusAccepted)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if messages, _ := os.ReadFile(messagesPath); len(messages) > 0 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("no event sync message published after the asynchronous post processing")
+}
+
+func TestEventHandler_PubSubPushRejection(t *testing.T) {
+	cfg, err := eventsync.LoadConfig(`{
+		"serviceName": "push",
+		"trigger": {"type": "none", "observationPeriod": 3600},
+		"endpoints": [{"eventKey": "entry1", "acceptedHttpMethods": ["POST"], "limits": {"maxBodyBytes": 16}}, {"eventKey": "entry2"}],
+		"targetPubSub": {"topic": "projects/test/topics/push"}
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	target, err := eventsync.NewWriterTarget(filepath.Join(t.TempDir(), "messages.jsonl"))
+	if err != nil {
+		t.Fatalf("NewWriterTarget() error = %v", err)
+	}
+	engine, err := eventsync.New(cfg, eventsync.WithStore(eventsync.NewMemoryStore()), eventsync.WithTarget(target))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		header     http.Header
+		wantStatus int
+		wantCode   models.ErrorCodeType
+	}{
+		{name: "push unknown eventKey", method: http.MethodPost, path: "/event/entry3", header: http.Header{"User-Agent": {"APIs-Google; (+https://developers.google.com/webmasters/APIs-Google.html)"}}, wantStatus: http.StatusOK, wantCode: models.ErrorCodeUnknownEventKey},
+		{name: "push method not allowed", method: http.MethodPut, path: "/event/entry1", header: http.Header{"X-Goog-Pubsub-Subscription-Name": {"projects/test/subscriptions/push"}}, wantStatus: http.StatusOK, wantCode: models.ErrorCodeMethodNotAllowed},
+		{name: "push payload too large", method: http.MethodPost, path: "/event/entry1", body: strings.Repeat("x", 32), header: http.Header{"User-Agent": {"APIs-Google"}}, wantStatus: http.StatusOK, wantCode: models.ErrorCodePayloadTooLarge},
+		{name: "other unknown eventKey", method: http.MethodPost, path: "/event/entry3", wantStatus: http.StatusNotFound, wantCode: models.ErrorCodeUnknownEventKey},
+		{name: "push accepted event", method: http.MethodPost, path: "/event/entry1", body: "test", header: http.Header{"User-Agent": {"APIs-Google"}}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			for name, values := range tt.header {
+				request.Header[name] = values
+			}
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("ServeHTTP() status code = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			response := models.Response{}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("invalid response with error %v", err)
+			}
+			if tt.wantCode == "" {
+				if response.Error != nil {
+					t.Errorf("ServeHTTP() error = %v, want none", response.Error)
+				}
+				return
+			}
+			if response.Status != models.ResponseStatusRejected || response.Error == nil || response.Error.Code != tt.wantCode {
+				t.Errorf("ServeHTTP() status = %s, error = %v, want %s %s", response.Status, response.Error, models.ResponseStatusRejected, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EventsPath is the path to query the stored events
+const EventsPath = "/events"
+
+// StoredEventRoute is the route of the stored event retrieval, on the event ingestion path prefix. It requires the
+// admin role, unlike the event ingestion, because the stored events contain the headers and the bodies of the upstream
+// calls (see IsStoredEventPath)
+const StoredEventRoute = services.EventPathPrefix + "{eventKey}/{documentID}"
+
+// EventsHandler is the URL request handler for the read only events query and retrieval
+type EventsHandler struct {
+	// EventService is the service to manage, store and retrieve events
+	EventService *services.EventService
+	// HistoryService is the service to retrieve the event sync messages which include an event
+	HistoryService *services.HistoryService
+	// ConfigService is the service to manage the configuration of the current instance
+	ConfigService *services.ConfigService
+}
+
+// Events is the function to handle the events query request. The filters are provided in query parameters:
+// startDate, endDate, eventKeys, exported, header (repeated, "name:value" format), content, pageSize and pageToken
+func (e *EventsHandler) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet, "query the events")
+		return
+	}
+
+	eventQuery, err := parseEventQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidParameters, fmt.Sprintf("invalid query parameters with error %s", err))
+		return
+	}
+
+	page, err := e.EventService.QueryEvents(r.Context(), eventQuery)
+	if err != nil {
+		e.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to query the events", "error", err)
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to query the events with error %s", err))
+		return
+	}
+	writeData(w, page)
+}
+
+// IsStoredEventPath returns true if the path is a /event/<eventKey>/<documentID> path of a stored event: the eventKey of
+// the configuration followed by a document ID. The configured eventKeys which contain a "/" keep their ingestion path.
+func IsStoredEventPath(eventService *services.EventService, path string) bool {
+	_, _, ok := splitStoredEventPath(eventService, path)
+	return ok
+}
+
+// splitStoredEventPath returns the eventKey and the documentID of the stored event path (see IsStoredEventPath)
+func splitStoredEventPath(eventService *services.EventService, path string) (eventKey string, documentID string, ok bool) {
+	eventKeyValue := services.ExtractEventKey(path)
+	separator := strings.LastIndex(eventKeyValue, "/")
+	if separator <= 0 || separator == len(eventKeyValue)-1 || eventService.HasEndpoint(eventKeyValue) ||
+		!eventService.HasEndpoint(eventKeyValue[:separator]) {
+		return "", "", false
+	}
+	return eventKeyValue[:separator], eventKeyValue[separator+1:], true
+}
+
+// StoredEvent is the function to handle the stored event request on the /event/<eventKey>/<documentID> path. It writes
+// the stored event, its exported state and the EventIDs of the event sync messages which include it
+func (e *EventsHandler) StoredEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet, "get a stored event")
+		return
+	}
+	eventKey, documentID, ok := splitStoredEventPath(e.EventService, r.URL.Path)
+	if !ok {
+		writeError(w, http.StatusNotFound, models.ErrorCodeNotFound, fmt.Sprintf("the path %q must be %s with an eventKey of the configuration", r.URL.Path, StoredEventRoute))
+		return
+	}
+
+	event, err := e.EventService.GetEvent(r.Context(), eventKey, documentID)
+	if err == services.ErrEventNotFound {
+		writeError(w, http.StatusNotFound, models.ErrorCodeNotFound, fmt.Sprintf("no event %q for the eventKey %q", documentID, eventKey))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to get the event with error %s", err))
+		return
+	}
+
+	consumedBy, err := e.HistoryService.ListConsumingEventIDs(r.Context(), documentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to get the event sync messages of the event with error %s", err))
+		return
+	}
+
+	writeData(w, models.EventDetail{
+		DocumentID: event.FirestoreDocumentID,
+		Exported:   event.AlreadyExported,
+		ConsumedBy: consumedBy,
+		Event:      event,
+	})
+}
+
+// parseEventQuery builds the EventQuery from the query parameters
+func parseEventQuery(r *http.Request) (eventQuery models.EventQuery, err error) {
+	query := r.URL.Query()
+	if err = parseEventFilter(query, &eventQuery.EventFilter); err != nil {
+		return
+	}
+	if eventQuery.Exported, err = parseBoolParam(query, "exported"); err != nil {
+		return
+	}
+	for _, header := range query["header"] {
+		name, value, found := strings.Cut(header, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return eventQuery, errors.New(fmt.Sprintf("the header parameter %q must be in \"name:value\" format", header))
+		}
+		if eventQuery.Headers == nil {
+			eventQuery.Headers = make(map[string]string)
+		}
+		eventQuery.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	eventQuery.Content = query.Get("content")
+	if pageSize := query.Get("pageSize"); pageSize != "" {
+		if eventQuery.PageSize, err = strconv.Atoi(pageSize); err != nil {
+			return eventQuery, errors.New(fmt.Sprintf("the pageSize parameter %q must be an integer", pageSize))
+		}
+	}
+	eventQuery.PageToken = query.Get("pageToken")
+	return
+}
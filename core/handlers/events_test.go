@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"github.com/guillaumeblaquiere/eventsync/core/eventsync"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventsHandler_GetEvent(t *testing.T) {
+	cfg, err := eventsync.LoadConfig(`{
+		"serviceName": "handlers",
+		"trigger": {"type": "none", "observationPeriod": 3600},
+		"endpoints": [{"eventKey": "entry1"}, {"eventKey": "entry2"}],
+		"targetPubSub": {"topic": "projects/test/topics/handlers"},
+		"auth": {"ingestion": {"apiKeys": ["ingest-key"]}, "admin": {"apiKeys": ["admin-key"]}}
+	}`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	target, err := eventsync.NewWriterTarget(t.TempDir() + "/messages.jsonl")
+	if err != nil {
+		t.Fatalf("NewWriterTarget() error = %v", err)
+	}
+	engine, err := eventsync.New(cfg, eventsync.WithStore(eventsync.NewMemoryStore()), eventsync.WithTarget(target))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/event/entry1", strings.NewReader("secret body"))
+	request.Header.Set("X-API-Key", "ingest-key")
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	response := models.Response{}
+	if err = json.NewDecoder(recorder.Body).Decode(&response); err != nil || response.DocumentID == "" {
+		t.Fatalf("event ingestion response = %d %v, error %v", recorder.Code, response, err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		apiKey         string
+		wantStatusCode int
+	}{
+		{name: "admin key", method: http.MethodGet, path: "/event/entry1/" + response.DocumentID, apiKey: "admin-key", wantStatusCode: http.StatusOK},
+		{name: "ingestion key", method: http.MethodGet, path: "/event/entry1/" + response.DocumentID, apiKey: "ingest-key", wantStatusCode: http.StatusUnauthorized},
+		{name: "unknown event", method: http.MethodGet, path: "/event/entry1/unknown", apiKey: "admin-key", wantStatusCode: http.StatusNotFound},
+		{name: "unknown eventKey", method: http.MethodGet, path: "/event/entry3/" + response.DocumentID, apiKey: "ingest-key", wantStatusCode: http.StatusNotFound},
+		{name: "ingestion path", method: http.MethodPost, path: "/event/entry1", apiKey: "ingest-key", wantStatusCode: http.StatusOK},
+		{name: "method not allowed", method: http.MethodDelete, path: "/event/entry1/" + response.DocumentID, apiKey: "admin-key", wantStatusCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, nil)
+			request.Header.Set("X-API-Key", tt.apiKey)
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatusCode {
+				t.Fatalf("ServeHTTP() status code = %d, want %d, body %s", recorder.Code, tt.wantStatusCode, recorder.Body)
+			}
+			if tt.wantStatusCode != http.StatusOK || tt.method != http.MethodGet {
+				return
+			}
+			eventDetail := models.EventDetail{}
+			if err := json.NewDecoder(recorder.Body).Decode(&models.Response{Data: &eventDetail}); err != nil {
+				t.Fatalf("invalid event detail with error %v", err)
+			}
+			if eventDetail.DocumentID != response.DocumentID || eventDetail.Event.Content != "secret body" {
+				t.Errorf("ServeHTTP() event detail = %v, want the stored event %s", eventDetail, response.DocumentID)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HistoryPath is the path to list the trigger history
+const HistoryPath = "/history"
+
+// HistoryPathPrefix is the path prefix to get (/history/<EventID>) or replay (/history/<EventID>/replay) a trigger
+// history record
+const HistoryPathPrefix = HistoryPath + "/"
+
+// replayPathSuffix is the suffix of the path to re-publish a past event sync message
+const replayPathSuffix = "/replay"
+
+// HistoryHandler is the URL request handler for the trigger history
+type HistoryHandler struct {
+	// HistoryService is the service to retrieve the trigger history
+	HistoryService *services.HistoryService
+	// TriggerService is the service to manage the event generation and formatting
+	TriggerService *services.TriggerService
+	// ConfigService is the service to manage the configuration of the current instance
+	ConfigService *services.ConfigService
+}
+
+// History is the function to handle the trigger history requests: list the records, get one record by EventID or
+// replay the event sync message of one record
+func (h *HistoryHandler) History(w http.ResponseWriter, r *http.Request) {
+	eventID := strings.TrimPrefix(r.URL.Path, HistoryPathPrefix)
+	switch {
+	case r.URL.Path == HistoryPath || r.URL.Path == HistoryPathPrefix:
+		h.list(w, r)
+	case strings.HasSuffix(eventID, replayPathSuffix):
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, http.MethodPost, "replay an event sync message")
+			return
+		}
+		h.replay(w, r, strings.TrimSuffix(eventID, replayPathSuffix))
+	default:
+		h.get(w, r, eventID)
+	}
+}
+
+// list writes the trigger history records that match the startDate, endDate, status and limit query parameters
+func (h *HistoryHandler) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.HistoryFilter{
+		Status: models.DeliveryStatusType(strings.ToUpper(query.Get("status"))),
+	}
+
+	var err error
+	if filter.StartDate, err = parseTimeParam(query, "startDate"); err == nil {
+		filter.EndDate, err = parseTimeParam(query, "endDate")
+	}
+	if err == nil && query.Get("limit") != "" {
+		if filter.Limit, err = strconv.Atoi(query.Get("limit")); err != nil {
+			err = errors.New(fmt.Sprintf("the limit parameter %q must be an integer", query.Get("limit")))
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidParameters, fmt.Sprintf("invalid history parameters with error %s", err))
+		return
+	}
+
+	histories, err := h.HistoryService.ListHistory(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to list the trigger history with error %s", err))
+		return
+	}
+	writeData(w, histories)
+}
+
+// get writes the trigger history record of the eventID
+func (h *HistoryHandler) get(w http.ResponseWriter, r *http.Request, eventID string) {
+	history, err := h.HistoryService.GetHistory(r.Context(), eventID)
+	if err == services.ErrHistoryNotFound {
+		writeError(w, http.StatusNotFound, models.ErrorCodeNotFound, fmt.Sprintf("no trigger history for the EventID %q", eventID))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to get the trigger history with error %s", err))
+		return
+	}
+	writeData(w, history)
+}
+
+// replay re-publishes the event sync message of the eventID and writes the delivery
+func (h *HistoryHandler) replay(w http.ResponseWriter, r *http.Request, eventID string) {
+	delivery, err := h.TriggerService.ReplayEvent(r.Context(), eventID)
+	if err == services.ErrHistoryNotFound {
+		writeError(w, http.StatusNotFound, models.ErrorCodeNotFound, fmt.Sprintf("no trigger history for the EventID %q", eventID))
+		return
+	}
+	if err == services.ErrHistoryUnavailable {
+		writeError(w, http.StatusServiceUnavailable, models.ErrorCodeTriggerFailed, fmt.Sprintf("impossible to replay the EventID %s without trigger history", eventID))
+		return
+	}
+	if err != nil {
+		h.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to replay the event sync message", "eventID", eventID, "error", err)
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeTriggerFailed, fmt.Sprintf("impossible to replay the EventID %s with error %s", eventID, err))
+		return
+	}
+	writeData(w, delivery)
+}
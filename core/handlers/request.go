@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeJSONBody decodes the JSON body of the request in the target, if the body is not empty. Return true if a body
+// has been decoded.
+func decodeJSONBody(r *http.Request, target interface{}) (decoded bool, err error) {
+	if r.Body == nil {
+		return false, nil
+	}
+	defer r.Body.Close()
+
+	err = json.NewDecoder(r.Body).Decode(target)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("invalid JSON body with error %s", err))
+	}
+	return true, nil
+}
+
+// parseEventFilter extracts the startDate, endDate (RFC3339 format) and eventKeys (comma separated or repeated) query
+// parameters in the filter. Missing parameters are left unset.
+func parseEventFilter(query url.Values, filter *models.EventFilter) (err error) {
+	if filter.StartDate, err = parseTimeParam(query, "startDate"); err != nil {
+		return
+	}
+	if filter.EndDate, err = parseTimeParam(query, "endDate"); err != nil {
+		return
+	}
+	for _, value := range query["eventKeys"] {
+		for _, eventKey := range strings.Split(value, ",") {
+			if eventKey = strings.TrimSpace(eventKey); eventKey != "" {
+				filter.EventKeys = append(filter.EventKeys, eventKey)
+			}
+		}
+	}
+	return
+}
+
+// parseTimeParam returns the RFC3339 time value of the query parameter name, or nil if it is not set
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("the %s parameter %q must be in RFC3339 format", name, value))
+	}
+	return &t, nil
+}
+
+// parseBoolParam returns the boolean value of the query parameter name, or nil if it is not set
+func parseBoolParam(query url.Values, name string) (*bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("the %s parameter %q must be a boolean", name, value))
+	}
+	return &b, nil
+}
+
+// parseTriggerRequest builds the TriggerRequest from the JSON body of the request or, if there is no body, from the
+// query parameters
+func parseTriggerRequest(r *http.Request) (triggerRequest models.TriggerRequest, err error) {
+	decoded, err := decodeJSONBody(r, &triggerRequest)
+	if err != nil || decoded {
+		return
+	}
+
+	query := r.URL.Query()
+	if err = parseEventFilter(query, &triggerRequest.EventFilter); err != nil {
+		return
+	}
+	respectConditions, err := parseBoolParam(query, "respectConditions")
+	if err != nil {
+		return
+	}
+	if respectConditions != nil {
+		triggerRequest.RespectConditions = *respectConditions
+	}
+	triggerRequest.KeepEventAfterTrigger, err = parseBoolParam(query, "keepEventAfterTrigger")
+	return
+}
+
+// parseResetRequest builds the ResetRequest from the JSON body of the request or, if there is no body, from the
+// query parameters
+func parseResetRequest(r *http.Request) (resetRequest models.ResetRequest, err error) {
+	decoded, err := decodeJSONBody(r, &resetRequest)
+	if err != nil || decoded {
+		return
+	}
+	err = parseEventFilter(r.URL.Query(), &resetRequest.EventFilter)
+	return
+}
+
+// writeJSON writes the value in JSON format in the response, with the provided HTTP status code
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeData writes the result of a successful request in the data of the JSON envelope of the responses
+func writeData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, models.Response{
+		Status: models.ResponseStatusOK,
+		Data:   data,
+	})
+}
+
+// writeError writes the error in the JSON envelope of the responses, with the provided HTTP status code. The 4xx
+// errors are rejections that the clients should not retry as is, the 5xx errors can be retried. Pub/Sub push
+// subscriptions retry all the non-2xx responses, the 4xx included: the event rejections acknowledge them instead (see
+// EventHandler.writeRejection).
+func writeError(w http.ResponseWriter, statusCode int, code models.ErrorCodeType, message string) {
+	status := models.ResponseStatusType(models.ResponseStatusError)
+	if statusCode < http.StatusInternalServerError {
+		status = models.ResponseStatusRejected
+	}
+	writeJSON(w, statusCode, models.Response{
+		Status: status,
+		Error:  &models.ErrorDetail{Code: code, Message: message},
+	})
+}
+
+// writeMethodNotAllowed writes the error of a request with a not accepted HTTP method
+func writeMethodNotAllowed(w http.ResponseWriter, allowedMethod string, action string) {
+	w.Header().Set("Allow", allowedMethod)
+	writeError(w, http.StatusMethodNotAllowed, models.ErrorCodeMethodNotAllowed, fmt.Sprintf("only %s method is accepted to %s", allowedMethod, action))
+}
+
+// countEvents returns the number of events per eventKey
+func countEvents(events map[string][]models.Event) (nbOfEvents map[string]int) {
+	nbOfEvents = make(map[string]int, len(events))
+	for eventKey, eventGroup := range events {
+		nbOfEvents[eventKey] = len(eventGroup)
+	}
+	return
+}
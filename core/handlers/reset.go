@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"eventsync/services"
-	"eventsync/utils"
 	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
 	"net/http"
 )
 
@@ -16,19 +16,29 @@ type ResetHandler struct {
 }
 
 // Reset is the function to handle the reset request, to cancel all the previous event over the configured observation
-// period
+// period. The time range and the subset of eventKeys can be provided in JSON body or in query parameters. The
+// response describes what has been reset.
 func (rh *ResetHandler) Reset(w http.ResponseWriter, r *http.Request) {
-	utils.EnableCors(&w)
-
-	events, err := rh.EventService.GetEventsOverAPeriod(r.Context(), rh.ConfigService.GetConfig().Trigger.ObservationPeriod)
+	resetRequest, err := parseResetRequest(r)
+	if err == nil {
+		err = rh.EventService.NormalizeEventFilter(&resetRequest.EventFilter)
+	}
 	if err != nil {
-		fmt.Printf("impossible to get the events to reset with error %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "impossible to get the events to reset with error %s\n", err)
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidParameters, fmt.Sprintf("invalid reset parameters with error %s", err))
 		return
 	}
 
-	rh.EventService.ResetEvents(r.Context(), events)
+	events, err := rh.EventService.GetEventsOverARange(r.Context(), resetRequest.EventFilter)
+	if err != nil {
+		rh.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to get the events to reset", "error", err)
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to get the events to reset with error %s", err))
+		return
+	}
 
-	fmt.Fprintf(w, "the events have been correctly reset.\n")
+	writeData(w, models.ResetResponse{
+		StartDate:           *resetRequest.StartDate,
+		EndDate:             *resetRequest.EndDate,
+		EventKeys:           resetRequest.EventKeys,
+		NumberOfEventsReset: rh.EventService.ResetEvents(r.Context(), events),
+	})
 }
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
+	"net/http"
+)
+
+// StatusHandler is the URL request handler for the sync readiness status
+type StatusHandler struct {
+	// EventService is the service to manage, store and retrieve events
+	EventService *services.EventService
+	// ConfigService is the service to manage the configuration of the current instance
+	ConfigService *services.ConfigService
+}
+
+// Status is the function to handle the sync readiness status request. The status is evaluated now, or as of the
+// asOf query parameter date (RFC3339 format), for the incident analysis.
+func (s *StatusHandler) Status(w http.ResponseWriter, r *http.Request) {
+	asOf, err := parseTimeParam(r.URL.Query(), "asOf")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidParameters, fmt.Sprintf("invalid status parameters with error %s", err))
+		return
+	}
+
+	var syncStatus models.SyncStatus
+	if asOf != nil {
+		syncStatus, err = s.EventService.GetSyncStatusAsOf(r.Context(), *asOf)
+	} else {
+		syncStatus, err = s.EventService.GetSyncStatus(r.Context())
+	}
+	if err != nil {
+		s.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to get the sync status", "error", err)
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to get the sync status with error %s", err))
+		return
+	}
+	writeData(w, syncStatus)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// Trace wraps the handler function in a server span of the route, child of the span of the request headers if any,
+// and correlates the logs of the request with that span in Cloud Logging
+func Trace(route string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer(utils.TracerName).Start(utils.ExtractTraceContext(r.Context(), r), r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.HTTPRoute(route)))
+		defer span.End()
+
+		if spanContext := span.SpanContext(); spanContext.IsValid() {
+			ctx = utils.WithTrace(ctx, utils.Trace{
+				TraceID: spanContext.TraceID().String(),
+				SpanID:  spanContext.SpanID().String(),
+				Sampled: spanContext.IsSampled(),
+			})
+		} else if requestTrace, ok := utils.GetRequestTrace(r); ok {
+			ctx = utils.WithTrace(ctx, requestTrace)
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handlerFunc(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	}
+}
+
+// statusRecorder keeps the status code of the response, for the span
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
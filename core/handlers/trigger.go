@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"eventsync/services"
-	"eventsync/utils"
 	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/services"
 	"net/http"
 )
 
@@ -17,25 +17,52 @@ type TriggerHandler struct {
 	EventService *services.EventService
 }
 
-// Trigger is the function to force the trigger by API request.
+// Trigger is the function to force the trigger by API request. The time range, the subset of eventKeys, the respect
+// of the endpoints conditions and the KeepEventAfterTrigger override can be provided in JSON body or in query
+// parameters. The response describes what has been sent.
 func (t *TriggerHandler) Trigger(w http.ResponseWriter, r *http.Request) {
-	utils.EnableCors(&w)
+	triggerRequest, err := parseTriggerRequest(r)
+	if err == nil {
+		err = t.EventService.NormalizeEventFilter(&triggerRequest.EventFilter)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidParameters, fmt.Sprintf("invalid trigger parameters with error %s", err))
+		return
+	}
 
-	eventList, err := t.EventService.GetEventsOverAPeriod(r.Context(), t.ConfigService.GetConfig().Trigger.ObservationPeriod)
+	eventList, err := t.EventService.GetEventsOverARange(r.Context(), triggerRequest.EventFilter)
 	if err != nil {
-		fmt.Printf("impossible to retrive the list of events with error %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "impossible to retrive the list of events with error %s\n", err)
+		t.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to retrieve the list of events", "error", err)
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, fmt.Sprintf("impossible to retrive the list of events with error %s", err))
+		return
+	}
+
+	triggerResponse := models.TriggerResponse{
+		StartDate:             *triggerRequest.StartDate,
+		EndDate:               *triggerRequest.EndDate,
+		EventKeys:             triggerRequest.EventKeys,
+		RespectConditions:     triggerRequest.RespectConditions,
+		KeepEventAfterTrigger: t.ConfigService.GetConfig().Trigger.KeepEventAfterTrigger,
+		NumberOfEvents:        countEvents(eventList),
+	}
+	if triggerRequest.KeepEventAfterTrigger != nil {
+		triggerResponse.KeepEventAfterTrigger = *triggerRequest.KeepEventAfterTrigger
+	}
+
+	if triggerRequest.RespectConditions && !t.EventService.CheckTriggerConditions(eventList, triggerRequest.EventKeys) {
+		triggerResponse.Reason = "the endpoints conditions are not met"
+		writeData(w, triggerResponse)
 		return
 	}
 
-	err = t.TriggerService.TriggerEvent(r.Context(), eventList)
+	eventGenerated, err := t.TriggerService.TriggerEvent(r.Context(), eventList, triggerResponse.KeepEventAfterTrigger)
 	if err != nil {
-		fmt.Printf("impossible to trigger the events with error %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "impossible to trigger the events with error %s\n", err)
+		t.ConfigService.GetLogger().ErrorContext(r.Context(), "impossible to trigger the events", "error", err)
+		writeError(w, http.StatusInternalServerError, models.ErrorCodeTriggerFailed, fmt.Sprintf("impossible to trigger the events with error %s", err))
 		return
 	}
 
-	fmt.Fprintf(w, "trigger correctly performed.")
+	triggerResponse.Triggered = true
+	triggerResponse.EventID = eventGenerated.EventID
+	writeData(w, triggerResponse)
 }
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+// AdminOperationType is the type of administration operation on the stored events
+type AdminOperationType string
+
+const (
+	// AdminOperationTypeDelete hard deletes the events from Firestore
+	AdminOperationTypeDelete AdminOperationType = "DELETE"
+	// AdminOperationTypeUnexport sets the already exported events back to not exported, so they count again for the
+	// trigger conditions
+	AdminOperationTypeUnexport = "UNEXPORT"
+	// AdminOperationTypeRelabel moves the events to another eventKey
+	AdminOperationTypeRelabel = "RELABEL"
+)
+
+// AdminRequest is the definition of an administration operation on stored events. The events are selected either by
+// their DocumentIDs, or by a time range (StartDate and EndDate are mandatory) and, optionally, a subset of EventKeys.
+type AdminRequest struct {
+	// Operation is the type of operation to perform
+	Operation AdminOperationType `json:"operation"`
+	// DocumentIDs is the list of Firestore document IDs of the events to process
+	DocumentIDs []string `json:"documentIDs,omitempty"`
+	// EventFilter is the range of the events to process, if no DocumentIDs are provided. Only the already exported
+	// events are selected for the AdminOperationTypeUnexport operation
+	EventFilter
+	// TargetEventKey is the new eventKey of the events for the AdminOperationTypeRelabel operation. It must be defined
+	// in the configuration
+	TargetEventKey string `json:"targetEventKey,omitempty"`
+}
+
+// AuditRecord is the persistent record of an administration operation, also returned as the operation response
+type AuditRecord struct {
+	// Date is the timestamp of the operation
+	Date time.Time `json:"date"`
+	// Origin is the requester of the operation
+	Origin string `json:"origin"`
+	// Request is the requested operation
+	Request AdminRequest `json:"request"`
+	// NumberOfEvents is the number of events correctly processed
+	NumberOfEvents int `json:"numberOfEvents"`
+	// NumberOfEventsPerEventKey is the number of events correctly processed per eventKey (the original one for the
+	// AdminOperationTypeRelabel operation). The document IDs aren't recorded to keep the record size bounded
+	NumberOfEventsPerEventKey map[string]int `json:"numberOfEventsPerEventKey"`
+	// NotFoundDocumentIDs is the list of the requested Firestore document IDs that don't exist
+	NotFoundDocumentIDs []string `json:"notFoundDocumentIDs,omitempty"`
+	// Errors is the list of the batch errors, if any
+	Errors []string `json:"errors,omitempty"`
+}
@@ -25,8 +25,11 @@ type EventList struct {
 
 // EventGenerated is the structure of message published in the Target configuration
 type EventGenerated struct {
-	// EventID is the unique identifier of the ID based on a MD5 hash of all the messages in Events
+	// EventID is the unique identifier of the event sync message, based on a SHA-256 hash of the ServiceName, the time
+	// window and the FirestoreDocumentIDs of all the messages in Events
 	EventID string `json:"eventID"`
+	// IDVersion is the version of the EventID generation scheme
+	IDVersion int `json:"idVersion"`
 	// Date is the timestamp of the generated event
 	Date time.Time `json:"date"`
 	// ServiceName is the name of the current service name configuration
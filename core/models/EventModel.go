@@ -23,4 +23,19 @@ type Event struct {
 	Content interface{} `json:"content,omitempty"`
 	// Method is the HTTP method of the event HTTP request
 	Method HttpMethodType `json:"httpMethod"`
+	// TraceParent is the W3C traceparent of the span of the event reception, in the trace of the sender request if any
+	TraceParent string `json:"traceParent,omitempty"`
+}
+
+// EventDetail is a stored event with its export state, to trace a single received event
+type EventDetail struct {
+	// DocumentID is the Firestore document ID of the event
+	DocumentID string `json:"documentID"`
+	// Exported is true if the event has been exported in an event sync message and doesn't count anymore for the
+	// trigger conditions
+	Exported bool `json:"exported"`
+	// ConsumedBy is the list of the EventIDs of the event sync messages which include the event, the oldest first
+	ConsumedBy []string `json:"consumedBy"`
+	// Event is the stored event
+	Event Event `json:"event"`
 }
@@ -0,0 +1,33 @@
+package models
+
+// EventQuery is the set of filters to search the stored events
+type EventQuery struct {
+	// EventFilter is the time range and the eventKeys of the events. Unlike the manual operations, there are no default
+	// values: the range is open and all the eventKeys are used if omitted
+	EventFilter
+	// Exported is the AlreadyExported state of the events. Both states if omitted
+	Exported *bool `json:"exported,omitempty"`
+	// Headers is the map of header name and value that the events must have
+	Headers map[string]string `json:"headers,omitempty"`
+	// Content is a string that the event content must contain
+	Content string `json:"content,omitempty"`
+	// PageSize is the maximal number of events to return
+	PageSize int `json:"pageSize,omitempty"`
+	// PageToken is the cursor returned by the previous page, to get the next one
+	PageToken string `json:"pageToken,omitempty"`
+}
+
+// EventItem is an event of an EventPage with its Firestore document ID
+type EventItem struct {
+	// DocumentID is the Firestore document ID of the event
+	DocumentID string `json:"documentID"`
+	Event
+}
+
+// EventPage is a page of events returned by an EventQuery
+type EventPage struct {
+	// Events is the list of events of the page with their document ID, the oldest first
+	Events []EventItem `json:"events"`
+	// NextPageToken is the cursor to provide in the EventQuery to get the next page. Empty if it is the last page
+	NextPageToken string `json:"nextPageToken,omitempty"`
+}
@@ -55,6 +55,64 @@ type Endpoint struct {
 	// The value must be > 0. If it is omitted or set to 0, it is set to 1 by default.
 	MinNbOfOccurrence int `json:"minNbOfOccurrence"`
 	// TODO is optional?
+
+	// Verification is the signature verification of the events. The events without valid signature are rejected
+	// before the storage. Optional
+	Verification *Verification `json:"verification,omitempty"`
+	// Redaction is the redaction of the events of the endpoint before the storage, in addition to the global one.
+	// Optional
+	Redaction *Redaction `json:"redaction,omitempty"`
+	// Limits is the rate, size and concurrency limits of the endpoint events, in addition to the global ones. Optional
+	Limits *Limits `json:"limits,omitempty"`
+}
+
+// VerificationType is the type of signature verification of the events
+type VerificationType string
+
+const (
+	// VerificationTypeHmacSha256 verifies a generic HMAC-SHA256 signature of the body, or of the timestamp and the body
+	// if a TimestampHeader is set
+	VerificationTypeHmacSha256 VerificationType = "hmac-sha256"
+	// VerificationTypeGithub verifies the GitHub webhooks signature, in the X-Hub-Signature-256 header
+	VerificationTypeGithub = "github"
+	// VerificationTypeStripe verifies the Stripe webhooks signature, in the Stripe-Signature header
+	VerificationTypeStripe = "stripe"
+)
+
+// SignatureEncodingType is the encoding of the signature in the header
+type SignatureEncodingType string
+
+const (
+	// SignatureEncodingHex is the hexadecimal encoding of the signature
+	SignatureEncodingHex SignatureEncodingType = "hex"
+	// SignatureEncodingBase64 is the standard base64 encoding of the signature
+	SignatureEncodingBase64 = "base64"
+)
+
+// Verification is the signature verification configuration of an endpoint
+type Verification struct {
+	// Type is the type of verification: "hmac-sha256", "github" or "stripe"
+	Type VerificationType `json:"type"`
+	// Header is the header of the signature. Required for the "hmac-sha256" type, preset for the others
+	Header string `json:"header,omitempty"`
+	// Prefix is the prefix of the signature value in the header, removed before the comparison. For instance
+	// "sha256=". Only for the "hmac-sha256" type
+	Prefix string `json:"prefix,omitempty"`
+	// Encoding is the encoding of the signature: "hex" (default) or "base64". Only for the "hmac-sha256" type
+	Encoding SignatureEncodingType `json:"encoding,omitempty"`
+	// Secret is the HMAC secret. Use a "${VAR}" reference to not store it in the configuration. Exclusive with
+	// SecretFile
+	Secret string `json:"secret,omitempty"`
+	// SecretFile is the path of the file which contains the HMAC secret, for instance a mounted secret. It is read at
+	// each verification to follow the rotations. Exclusive with Secret
+	SecretFile string `json:"secretFile,omitempty"`
+	// TimestampHeader is the header of the Unix timestamp (in seconds) of the signature, for the replay protection.
+	// When set, the signed payload is "<timestamp>.<body>". Only for the "hmac-sha256" type, the "stripe" type
+	// provides the timestamp in its signature header
+	TimestampHeader string `json:"timestampHeader,omitempty"`
+	// ToleranceSeconds is the maximal difference between the signature timestamp and the reception date. Default is
+	// 300 seconds
+	ToleranceSeconds int64 `json:"toleranceSeconds,omitempty"`
 }
 
 /*------------------*/
@@ -92,6 +150,93 @@ type TargetPubSub struct {
 
 /*------------------*/
 
+// Redaction is the list of rules to remove or mask the sensitive values of the events before their storage. The
+// Authorization, Proxy-Authorization, Cookie and X-API-Key headers are always redacted, unless explicitly allowed.
+type Redaction struct {
+	// AllowedHeaders is the list of headers to keep, the others are removed. All the headers are kept if omitted. An
+	// endpoint list replaces the global one
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+	// DeniedHeaders is the list of headers to redact, in addition to the default ones. The value is replaced by
+	// "REDACTED"
+	DeniedHeaders []string `json:"deniedHeaders,omitempty"`
+	// MaskedJSONPaths is the list of the JSON body values to redact, in dot notation with optional "$." prefix and
+	// "[index]" or "[*]" for the arrays. For instance "$.card.number" or "items[*].token"
+	MaskedJSONPaths []string `json:"maskedJsonPaths,omitempty"`
+	// RemovedQueryParams is the list of query parameters to remove
+	RemovedQueryParams []string `json:"removedQueryParams,omitempty"`
+}
+
+/*------------------*/
+
+// AuthConfig is the authentication of the requests, per role. A role without definition is not authenticated by the
+// application, only by the runtime (Cloud Run IAM for instance)
+type AuthConfig struct {
+	// Ingestion is the authentication of the events endpoints (/event/...)
+	Ingestion *RoleAuth `json:"ingestion,omitempty"`
+	// Admin is the authentication of the administration endpoints (/config, /trigger, /reset, /history, /events,
+	// /admin/events, /status)
+	Admin *RoleAuth `json:"admin,omitempty"`
+	// Metrics is the authentication of the metrics endpoint (/metrics), for the Prometheus scrapers. The Admin
+	// authentication applies if it is not defined
+	Metrics *RoleAuth `json:"metrics,omitempty"`
+}
+
+// RoleAuth is the list of accepted credentials for a role. A request is authenticated if one of them is valid
+type RoleAuth struct {
+	// GoogleIDToken accepts the Google-signed ID tokens provided in the "Authorization: Bearer" header
+	GoogleIDToken *GoogleIDToken `json:"googleIdToken,omitempty"`
+	// APIKeys is the list of static keys accepted in the "X-API-Key" header. Use "${VAR}" references to not store
+	// them in the configuration
+	APIKeys []string `json:"apiKeys,omitempty"`
+}
+
+// GoogleIDToken is the Google-signed ID token verification configuration
+type GoogleIDToken struct {
+	// Audiences is the list of accepted token audiences, typically the URL of the service. At least one is required
+	Audiences []string `json:"audiences"`
+	// Principals is the list of accepted token emails (user or service account) or subjects. All the valid tokens
+	// are accepted if omitted
+	Principals []string `json:"principals,omitempty"`
+}
+
+/*------------------*/
+
+// Limits is the protection of the ingestion path against the misbehaving upstreams. The limits are per instance
+type Limits struct {
+	// RequestsPerSecond is the token bucket refill rate of the accepted events. No rate limit if omitted
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	// Burst is the token bucket size, the number of events accepted at once. The rounded up RequestsPerSecond by
+	// default
+	Burst int `json:"burst,omitempty"`
+	// MaxBodyBytes is the maximal size of the event bodies. 1 MiB (the Firestore document limit) by default
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+	// MaxConcurrentRequests is the maximal number of events processed at the same time. No limit if omitted
+	MaxConcurrentRequests int `json:"maxConcurrentRequests,omitempty"`
+}
+
+/*------------------*/
+
+// Cors is the Cross-Origin Resource Sharing policy of the browser requests. The preflight requests are answered
+// without storage
+type Cors struct {
+	// AllowedOrigins is the list of accepted origins, like "https://app.example.com". "*" accepts all the origins and
+	// "https://*.example.com" all the subdomains
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// AllowedMethods is the list of accepted methods. GET, HEAD and POST by default
+	AllowedMethods []HttpMethodType `json:"allowedMethods,omitempty"`
+	// AllowedHeaders is the list of accepted request headers. Content-Type, Authorization and X-API-Key by default
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+	// ExposedHeaders is the list of response headers readable by the browser
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+	// AllowCredentials accepts the cookies and the Authorization header of the browser. Not compatible with the "*"
+	// origin
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+	// MaxAgeSeconds is the duration of the preflight response in the browser cache. Not sent if omitted
+	MaxAgeSeconds int `json:"maxAgeSeconds,omitempty"`
+}
+
+/*------------------*/
+
 // EventSyncConfig is the configuration representation of the current service
 type EventSyncConfig struct {
 	// ServiceName is the name of the service, also use to create the Firestore collection
@@ -102,4 +247,12 @@ type EventSyncConfig struct {
 	Trigger *Trigger `json:"trigger"`
 	// TargetPubSub is the PubSub configuration to publish the new event in.
 	TargetPubSub *TargetPubSub `json:"targetPubSub"`
+	// Auth is the authentication of the requests per role. Optional
+	Auth *AuthConfig `json:"auth,omitempty"`
+	// Redaction is the redaction of the events of all the endpoints before the storage. Optional
+	Redaction *Redaction `json:"redaction,omitempty"`
+	// Cors is the CORS policy of the browser requests. The cross-origin requests are not allowed if omitted
+	Cors *Cors `json:"cors,omitempty"`
+	// Limits is the rate, size and concurrency limits of all the events together. Optional
+	Limits *Limits `json:"limits,omitempty"`
 }
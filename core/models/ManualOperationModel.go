@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// EventFilter is the selection of events on which a manual operation (trigger, reset) is performed
+type EventFilter struct {
+	// StartDate is the beginning (excluded) of the time range. If omitted, it is set to EndDate minus the Trigger's
+	// ObservationPeriod
+	StartDate *time.Time `json:"startDate,omitempty"`
+	// EndDate is the end (included) of the time range. If omitted, it is set to now
+	EndDate *time.Time `json:"endDate,omitempty"`
+	// EventKeys is the subset of endpoints eventKey to take into account. If omitted, all the endpoints are used
+	EventKeys []string `json:"eventKeys,omitempty"`
+}
+
+// TriggerRequest is the set of parameters of a manual trigger
+type TriggerRequest struct {
+	EventFilter
+	// RespectConditions indicates if the endpoints conditions (MinNbOfOccurrence) must be met to perform the trigger.
+	// By default, the trigger is forced
+	RespectConditions bool `json:"respectConditions"`
+	// KeepEventAfterTrigger overrides, for this call only, the Trigger's KeepEventAfterTrigger configuration value
+	KeepEventAfterTrigger *bool `json:"keepEventAfterTrigger,omitempty"`
+}
+
+// ResetRequest is the set of parameters of a manual reset
+type ResetRequest struct {
+	EventFilter
+}
+
+// TriggerResponse describes the result of a manual trigger
+type TriggerResponse struct {
+	// Triggered is true if an event sync message has been generated and sent
+	Triggered bool `json:"triggered"`
+	// Reason explains why the event sync message hasn't been generated, if any
+	Reason string `json:"reason,omitempty"`
+	// StartDate is the beginning (excluded) of the time range used to select the events
+	StartDate time.Time `json:"startDate"`
+	// EndDate is the end (included) of the time range used to select the events
+	EndDate time.Time `json:"endDate"`
+	// EventKeys is the list of endpoints eventKey taken into account
+	EventKeys []string `json:"eventKeys"`
+	// RespectConditions is the value used to evaluate the endpoints conditions before the trigger
+	RespectConditions bool `json:"respectConditions"`
+	// KeepEventAfterTrigger is the value applied to this trigger
+	KeepEventAfterTrigger bool `json:"keepEventAfterTrigger"`
+	// EventID is the identifier of the generated event sync message, if any
+	EventID string `json:"eventID,omitempty"`
+	// NumberOfEvents is the number of events selected per eventKey
+	NumberOfEvents map[string]int `json:"numberOfEvents"`
+}
+
+// ResetResponse describes the result of a manual reset
+type ResetResponse struct {
+	// StartDate is the beginning (excluded) of the time range used to select the events
+	StartDate time.Time `json:"startDate"`
+	// EndDate is the end (included) of the time range used to select the events
+	EndDate time.Time `json:"endDate"`
+	// EventKeys is the list of endpoints eventKey taken into account
+	EventKeys []string `json:"eventKeys"`
+	// NumberOfEventsReset is the number of events set to already exported per eventKey
+	NumberOfEventsReset map[string]int `json:"numberOfEventsReset"`
+}
@@ -0,0 +1,100 @@
+package models
+
+// ResponseStatusType is the outcome of a request, in the JSON envelope of the responses
+type ResponseStatusType string
+
+const (
+	// ResponseStatusOK indicates that the request has been fully processed
+	ResponseStatusOK ResponseStatusType = "OK"
+	// ResponseStatusAccepted indicates that the event has been stored and the trigger is evaluated asynchronously
+	ResponseStatusAccepted = "ACCEPTED"
+	// ResponseStatusPartial indicates that the event has been stored but the trigger failed. The event must not be
+	// sent again
+	ResponseStatusPartial = "PARTIAL"
+	// ResponseStatusRejected indicates that the request is invalid. It should not be retried as is
+	ResponseStatusRejected = "REJECTED"
+	// ResponseStatusError indicates a server side error. The request can be retried
+	ResponseStatusError = "ERROR"
+)
+
+// ErrorCodeType is the machine-readable code of an error
+type ErrorCodeType string
+
+const (
+	// ErrorCodeUnknownEventKey indicates that the eventKey in the path is missing or not defined in the configuration
+	ErrorCodeUnknownEventKey ErrorCodeType = "UNKNOWN_EVENT_KEY"
+	// ErrorCodeMethodNotAllowed indicates that the HTTP method is not accepted on the path
+	ErrorCodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	// ErrorCodeInvalidEvent indicates that the event can't be read
+	ErrorCodeInvalidEvent = "INVALID_EVENT"
+	// ErrorCodeInvalidParameters indicates that the query parameters or the JSON body are invalid
+	ErrorCodeInvalidParameters = "INVALID_PARAMETERS"
+	// ErrorCodeUnauthenticated indicates that the request has no valid credentials
+	ErrorCodeUnauthenticated = "UNAUTHENTICATED"
+	// ErrorCodeInvalidSignature indicates that the event signature is missing, invalid or expired
+	ErrorCodeInvalidSignature = "INVALID_SIGNATURE"
+	// ErrorCodePermissionDenied indicates that the authenticated principal is not allowed
+	ErrorCodePermissionDenied = "PERMISSION_DENIED"
+	// ErrorCodeNotFound indicates that the requested resource doesn't exist
+	ErrorCodeNotFound = "NOT_FOUND"
+	// ErrorCodeRateLimited indicates that the rate or the concurrency limits are reached. The request can be retried
+	// after the Retry-After delay
+	ErrorCodeRateLimited = "RATE_LIMITED"
+	// ErrorCodePayloadTooLarge indicates that the event body exceeds the maximal size
+	ErrorCodePayloadTooLarge = "PAYLOAD_TOO_LARGE"
+	// ErrorCodeStorageUnavailable indicates that the event can't be stored. The request can be retried
+	ErrorCodeStorageUnavailable = "STORAGE_UNAVAILABLE"
+	// ErrorCodeTriggerFailed indicates that the trigger conditions can't be evaluated or the event sync message can't
+	// be sent
+	ErrorCodeTriggerFailed = "TRIGGER_FAILED"
+	// ErrorCodeInternal indicates any other server side error
+	ErrorCodeInternal = "INTERNAL"
+)
+
+// ErrorDetail is the description of an error
+type ErrorDetail struct {
+	// Code is the machine-readable code of the error
+	Code ErrorCodeType `json:"code"`
+	// Message is the human-readable description of the error
+	Message string `json:"message"`
+}
+
+// TriggerOutcomeType is the result of the trigger evaluation after an event storage
+type TriggerOutcomeType string
+
+const (
+	// TriggerOutcomeTriggered indicates that an event sync message has been generated and sent
+	TriggerOutcomeTriggered TriggerOutcomeType = "TRIGGERED"
+	// TriggerOutcomeConditionsNotMet indicates that the trigger conditions are not met, nothing has been sent
+	TriggerOutcomeConditionsNotMet = "CONDITIONS_NOT_MET"
+	// TriggerOutcomePending indicates that the trigger conditions are evaluated asynchronously
+	TriggerOutcomePending = "PENDING"
+	// TriggerOutcomeFailed indicates that the trigger conditions evaluation or the event sync message sending failed
+	TriggerOutcomeFailed = "FAILED"
+)
+
+// TriggerOutcome describes the trigger evaluation performed after an event storage
+type TriggerOutcome struct {
+	// Status is the result of the trigger evaluation
+	Status TriggerOutcomeType `json:"status"`
+	// EventID is the identifier of the generated event sync message, if any
+	EventID string `json:"eventID,omitempty"`
+	// Error is the description of the trigger failure, if any
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+// Response is the JSON envelope of the responses of all the endpoints, except the Prometheus /metrics
+type Response struct {
+	// Status is the outcome of the request
+	Status ResponseStatusType `json:"status"`
+	// Error is the description of the error, if any
+	Error *ErrorDetail `json:"error,omitempty"`
+	// EventKey is the eventKey of the received event, if any
+	EventKey string `json:"eventKey,omitempty"`
+	// DocumentID is the Firestore document ID of the stored event, if any
+	DocumentID string `json:"documentID,omitempty"`
+	// Trigger is the outcome of the trigger evaluation after the event storage, if any
+	Trigger *TriggerOutcome `json:"trigger,omitempty"`
+	// Data is the result of the configuration and administration endpoints (TriggerResponse, SyncStatus,...), if any
+	Data interface{} `json:"data,omitempty"`
+}
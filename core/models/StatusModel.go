@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// EndpointStatus is the readiness of an endpoint over the Trigger's observation period
+type EndpointStatus struct {
+	// EventKey is the eventKey of the endpoint
+	EventKey string `json:"eventKey"`
+	// NumberOfEvents is the number of not exported events in the observation period
+	NumberOfEvents int `json:"numberOfEvents"`
+	// MinNbOfOccurrence is the value set in the configuration to consider the endpoint valid
+	MinNbOfOccurrence int `json:"minNbOfOccurrence"`
+	// Ready is true if the NumberOfEvents satisfies the MinNbOfOccurrence
+	Ready bool `json:"ready"`
+	// FirstEventDate is the arrival date of the oldest not exported event in the observation period
+	FirstEventDate *time.Time `json:"firstEventDate,omitempty"`
+	// LastEventDate is the arrival date of the youngest not exported event in the observation period
+	LastEventDate *time.Time `json:"lastEventDate,omitempty"`
+	// SecondsSinceLastEvent is the number of seconds elapsed since the LastEventDate
+	SecondsSinceLastEvent *int64 `json:"secondsSinceLastEvent,omitempty"`
+	// SignatureFailures is the number of events rejected by the signature verification since the instance start
+	SignatureFailures int64 `json:"signatureFailures"`
+}
+
+// SyncStatus is the readiness of the event sync over the Trigger's observation period
+type SyncStatus struct {
+	// Date is the date of the status evaluation
+	Date time.Time `json:"date"`
+	// ServiceName is the name of the current service name configuration
+	ServiceName string `json:"serviceName"`
+	// TriggerType is the type of trigger (TriggerTypeWindow or TriggerTypeNone)
+	TriggerType triggerType `json:"triggerType"`
+	// ObservationPeriod is the Trigger's observation period, in seconds
+	ObservationPeriod int64 `json:"observationPeriod"`
+	// ConditionsMet is true if all the endpoints are ready
+	ConditionsMet bool `json:"conditionsMet"`
+	// WouldTrigger is true if an event sync message would be generated automatically now, i.e. the conditions are met
+	// and the TriggerType is TriggerTypeWindow
+	WouldTrigger bool `json:"wouldTrigger"`
+	// Endpoints is the readiness of each configured endpoint
+	Endpoints []EndpointStatus `json:"endpoints"`
+}
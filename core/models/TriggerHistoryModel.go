@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// DeliveryStatusType is the outcome of the delivery of an event sync message to a target
+type DeliveryStatusType string
+
+const (
+	// DeliveryStatusTypeSuccess indicates that the event sync message has been accepted by the target
+	DeliveryStatusTypeSuccess DeliveryStatusType = "SUCCESS"
+	// DeliveryStatusTypeFailure indicates that the event sync message hasn't been accepted by the target
+	DeliveryStatusTypeFailure = "FAILURE"
+)
+
+// Delivery is the result of one publication of an event sync message to a target
+type Delivery struct {
+	// Date is the timestamp of the publication
+	Date time.Time `json:"date"`
+	// Target is the target on which the event sync message has been published (the PubSub topic)
+	Target string `json:"target"`
+	// Status is the outcome of the publication
+	Status DeliveryStatusType `json:"status"`
+	// MessageID is the identifier of the message returned by the target, if any
+	MessageID string `json:"messageID,omitempty"`
+	// Error is the error message of a failed publication
+	Error string `json:"error,omitempty"`
+	// Replay is true if the publication has been requested by API after the initial trigger
+	Replay bool `json:"replay"`
+}
+
+// TriggerHistory is the persistent record of a generated event sync message
+type TriggerHistory struct {
+	// EventID is the EventID of the generated event sync message. It is also the Firestore document ID
+	EventID string `json:"eventID"`
+	// Date is the timestamp of the generated event
+	Date time.Time `json:"date"`
+	// Status is the outcome of the latest delivery
+	Status DeliveryStatusType `json:"status"`
+	// DocumentIDs is the list of the Firestore document IDs of the events that contributed to the event sync message
+	DocumentIDs []string `json:"documentIDs"`
+	// Deliveries is the list of publications of the event sync message, the initial one and the replays
+	Deliveries []Delivery `json:"deliveries"`
+	// EventGenerated is the event sync message, without the events to keep the record under the Firestore document
+	// size limit. The events are read in the event collection with the EventDocumentIDs
+	EventGenerated EventGenerated `json:"eventGenerated"`
+	// EventDocumentIDs is the list of the Firestore document IDs of the events included in the event sync message, per
+	// eventKey, to rebuild it
+	EventDocumentIDs map[string][]string `json:"eventDocumentIDs,omitempty"`
+}
+
+// HistoryFilter is the selection of trigger history records to list
+type HistoryFilter struct {
+	// StartDate is the beginning (included) of the time range. No lower limit if omitted
+	StartDate *time.Time `json:"startDate,omitempty"`
+	// EndDate is the end (included) of the time range. No upper limit if omitted
+	EndDate *time.Time `json:"endDate,omitempty"`
+	// Status is the outcome of the latest delivery. All the records if omitted
+	Status DeliveryStatusType `json:"status,omitempty"`
+	// Limit is the maximal number of records to return, the most recent first
+	Limit int `json:"limit,omitempty"`
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+)
+
+// AuditCollectionSuffix is the suffix added to the config serviceName value to name the collection of the
+// administration operations audit
+const AuditCollectionSuffix = "-audit"
+
+// maxBatchSize is the maximal number of writes in a Firestore batch
+const maxBatchSize = 500
+
+// AdminService performs the administration operations on the stored events (delete, unexport, relabel). The writes
+// are batched and each operation is recorded in the audit collection. It reuses the store of the EventService.
+type AdminService struct {
+	store         EventStore
+	configService *ConfigService
+	eventService  *EventService
+}
+
+// NewAdminService creates the Admin service.
+func NewAdminService(configService *ConfigService, eventService *EventService) *AdminService {
+	return &AdminService{
+		store:         eventService.store,
+		configService: configService,
+		eventService:  eventService,
+	}
+}
+
+// auditCollectionName returns the name of the collection of the administration operations audit
+func (a *AdminService) auditCollectionName() string {
+	return a.configService.GetConfig().ServiceName + AuditCollectionSuffix
+}
+
+// CheckAdminRequest validates the administration request and sets the default eventKeys of a range selection. The
+// operation and the relabel TargetEventKey must be valid, and the events selected by DocumentIDs or by an explicit
+// time range.
+func (a *AdminService) CheckAdminRequest(adminRequest *models.AdminRequest) (err error) {
+	switch adminRequest.Operation {
+	case models.AdminOperationTypeDelete, models.AdminOperationTypeUnexport:
+	case models.AdminOperationTypeRelabel:
+		if a.eventService.getEndpoint(adminRequest.TargetEventKey) == nil {
+			return errors.New(fmt.Sprintf("the targetEventKey %q is not defined in the configuration", adminRequest.TargetEventKey))
+		}
+	default:
+		return errors.New(fmt.Sprintf("the operation %q is not valid. Accepted values are: %s, %s, %s", adminRequest.Operation, models.AdminOperationTypeDelete, models.AdminOperationTypeUnexport, models.AdminOperationTypeRelabel))
+	}
+
+	if len(adminRequest.DocumentIDs) > 0 {
+		if adminRequest.StartDate != nil || adminRequest.EndDate != nil || len(adminRequest.EventKeys) > 0 {
+			return errors.New("the events must be selected by documentIDs or by range, not both")
+		}
+		return nil
+	}
+	if adminRequest.StartDate == nil || adminRequest.EndDate == nil {
+		return errors.New("the events must be selected by documentIDs or by range with explicit startDate and endDate")
+	}
+	return a.eventService.NormalizeEventFilter(&adminRequest.EventFilter)
+}
+
+// PerformAdminOperation selects the events of the (checked) administration request, applies the operation by batches
+// and records the operation in the audit collection. The audit record is returned, even in case of partial failure.
+func (a *AdminService) PerformAdminOperation(ctx context.Context, adminRequest models.AdminRequest, origin string) (auditRecord models.AuditRecord, err error) {
+	auditRecord = models.AuditRecord{
+		Date:                      a.eventService.Now(),
+		Origin:                    origin,
+		Request:                   adminRequest,
+		NumberOfEventsPerEventKey: make(map[string]int),
+	}
+
+	var documentIDs map[string][]string
+	if len(adminRequest.DocumentIDs) > 0 {
+		documentIDs, auditRecord.NotFoundDocumentIDs, err = a.store.GetExistingEventIDs(ctx, a.configService.GetConfig().ServiceName, adminRequest.DocumentIDs)
+		if err != nil {
+			a.configService.GetLogger().ErrorContext(ctx, "error during the documents retrieval", "error", err)
+		}
+	} else {
+		documentIDs, err = a.selectDocumentIDs(ctx, adminRequest)
+	}
+	if err != nil {
+		return
+	}
+
+	var update EventUpdate
+	switch adminRequest.Operation {
+	case models.AdminOperationTypeDelete:
+		update.Delete = true
+	case models.AdminOperationTypeUnexport:
+		exported := false
+		update.AlreadyExported = &exported
+	case models.AdminOperationTypeRelabel:
+		update.EventKey = adminRequest.TargetEventKey
+	}
+
+	for eventKey, eventKeyIDs := range documentIDs {
+		for start := 0; start < len(eventKeyIDs); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(eventKeyIDs) {
+				end = len(eventKeyIDs)
+			}
+
+			if batchErr := a.store.UpdateEvents(ctx, a.configService.GetConfig().ServiceName, eventKeyIDs[start:end], update); batchErr != nil {
+				a.configService.GetLogger().ErrorContext(ctx, "impossible to perform the operation on a batch of events", "operation", adminRequest.Operation, "eventKey", eventKey, "nbOfEvents", end-start, "error", batchErr)
+				auditRecord.Errors = append(auditRecord.Errors, batchErr.Error())
+				continue
+			}
+			auditRecord.NumberOfEventsPerEventKey[eventKey] += end - start
+			auditRecord.NumberOfEvents += end - start
+		}
+	}
+	a.configService.GetLogger().InfoContext(ctx, "administration operation performed", "operation", adminRequest.Operation, "nbOfEvents", auditRecord.NumberOfEvents)
+
+	err = a.store.AddAuditRecord(ctx, a.auditCollectionName(), auditRecord)
+	if err != nil {
+		a.configService.GetLogger().ErrorContext(ctx, "impossible to store the audit record", "operation", adminRequest.Operation, "error", err)
+		return
+	}
+	if len(auditRecord.Errors) > 0 {
+		err = errors.New(fmt.Sprintf("%d batches of the %s operation failed", len(auditRecord.Errors), adminRequest.Operation))
+	}
+	return
+}
+
+// selectDocumentIDs returns the document IDs of the events in the request range, grouped by eventKey. Only the already
+// exported events are selected for the AdminOperationTypeUnexport operation.
+func (a *AdminService) selectDocumentIDs(ctx context.Context, adminRequest models.AdminRequest) (documentIDs map[string][]string, err error) {
+	filter := EventStoreFilter{
+		StartDate: adminRequest.StartDate,
+		EndDate:   adminRequest.EndDate,
+	}
+	if adminRequest.Operation == models.AdminOperationTypeUnexport {
+		exported := true
+		filter.Exported = &exported
+	}
+
+	documentIDs = make(map[string][]string)
+	for _, eventKey := range adminRequest.EventKeys {
+		filter.EventKeys = []string{eventKey}
+		var eventKeyIDs []string
+		eventKeyIDs, err = a.store.ListEventIDs(ctx, a.configService.GetConfig().ServiceName, filter)
+		if err != nil {
+			a.configService.GetLogger().ErrorContext(ctx, "error during the document retrieval", "eventKey", eventKey, "error", err)
+			return
+		}
+		if len(eventKeyIDs) > 0 {
+			documentIDs[eventKey] = eventKeyIDs
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdminService_CheckAdminRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		adminRequest  models.AdminRequest
+		wantEventKeys []string
+		wantErr       bool
+	}{
+		{
+			name: "ok delete by documentIDs",
+			adminRequest: models.AdminRequest{
+				Operation:   models.AdminOperationTypeDelete,
+				DocumentIDs: []string{"id1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ok unexport by range",
+			adminRequest: models.AdminRequest{
+				Operation:   models.AdminOperationTypeUnexport,
+				EventFilter: models.EventFilter{StartDate: &before, EndDate: &after},
+			},
+			wantEventKeys: []string{"entry1", "entry2"},
+			wantErr:       false,
+		},
+		{
+			name: "ok relabel",
+			adminRequest: models.AdminRequest{
+				Operation:      models.AdminOperationTypeRelabel,
+				EventFilter:    models.EventFilter{StartDate: &before, EndDate: &after, EventKeys: []string{"entry1"}},
+				TargetEventKey: "entry2",
+			},
+			wantEventKeys: []string{"entry1"},
+			wantErr:       false,
+		},
+		{
+			name: "ko invalid operation",
+			adminRequest: models.AdminRequest{
+				Operation:   "PURGE",
+				DocumentIDs: []string{"id1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ko relabel to unknown eventKey",
+			adminRequest: models.AdminRequest{
+				Operation:      models.AdminOperationTypeRelabel,
+				DocumentIDs:    []string{"id1"},
+				TargetEventKey: "entry3",
+			},
+			wantErr: true,
+		},
+		{
+			name: "ko no selection",
+			adminRequest: models.AdminRequest{
+				Operation: models.AdminOperationTypeDelete,
+			},
+			wantErr: true,
+		},
+		{
+			name: "ko implicit range",
+			adminRequest: models.AdminRequest{
+				Operation:   models.AdminOperationTypeDelete,
+				EventFilter: models.EventFilter{StartDate: &before},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ko documentIDs and range",
+			adminRequest: models.AdminRequest{
+				Operation:   models.AdminOperationTypeDelete,
+				DocumentIDs: []string{"id1"},
+				EventFilter: models.EventFilter{StartDate: &before, EndDate: &after},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configService := &ConfigService{
+				eventSyncConfig: generateValidConfig(),
+			}
+			a := &AdminService{
+				configService: configService,
+				eventService:  &EventService{configService: configService},
+			}
+			err := a.CheckAdminRequest(&tt.adminRequest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckAdminRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(tt.adminRequest.EventKeys, tt.wantEventKeys) {
+				t.Errorf("CheckAdminRequest() EventKeys = %v, want %v", tt.adminRequest.EventKeys, tt.wantEventKeys)
+			}
+		})
+	}
+}
+
+func TestAdminService_PerformAdminOperation(t *testing.T) {
+	tests := []struct {
+		name                          string
+		adminRequest                  models.AdminRequest
+		wantNumberOfEventsPerEventKey map[string]int
+		wantNotFound                  int
+	}{
+		{
+			name: "delete by documentIDs",
+			adminRequest: models.AdminRequest{
+				Operation: models.AdminOperationTypeDelete,
+			},
+			wantNumberOfEventsPerEventKey: map[string]int{"entry1": 2, "entry2": 1},
+			wantNotFound:                  1,
+		},
+		{
+			name: "relabel by range",
+			adminRequest: models.AdminRequest{
+				Operation:      models.AdminOperationTypeRelabel,
+				EventFilter:    models.EventFilter{StartDate: &before, EndDate: &after, EventKeys: []string{"entry1", "entry2"}},
+				TargetEventKey: "entry2",
+			},
+			wantNumberOfEventsPerEventKey: map[string]int{"entry1": 2, "entry2": 1},
+		},
+		{
+			name: "unexport by range",
+			adminRequest: models.AdminRequest{
+				Operation:   models.AdminOperationTypeUnexport,
+				EventFilter: models.EventFilter{StartDate: &before, EndDate: &after, EventKeys: []string{"entry1", "entry2"}},
+			},
+			wantNumberOfEventsPerEventKey: map[string]int{"entry2": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := generateValidConfig()
+			configService := &ConfigService{eventSyncConfig: config, clock: FixedClock(now)}
+			store, documentIDs := addMemoryEvents(t, config.ServiceName, []models.Event{
+				{EventKey: "entry1", Datetime: now},
+				{EventKey: "entry1", Datetime: now.Add(-time.Minute)},
+				{EventKey: "entry2", Datetime: now, AlreadyExported: true},
+			})
+			if tt.adminRequest.Operation == models.AdminOperationTypeDelete {
+				tt.adminRequest.DocumentIDs = append(documentIDs, "unknown")
+			}
+			a := NewAdminService(configService, NewEventService(configService, store))
+
+			gotAuditRecord, err := a.PerformAdminOperation(context.Background(), tt.adminRequest, "test")
+			if err != nil {
+				t.Fatalf("PerformAdminOperation() error = %v", err)
+			}
+			if !reflect.DeepEqual(gotAuditRecord.NumberOfEventsPerEventKey, tt.wantNumberOfEventsPerEventKey) {
+				t.Errorf("PerformAdminOperation() NumberOfEventsPerEventKey = %v, want %v", gotAuditRecord.NumberOfEventsPerEventKey, tt.wantNumberOfEventsPerEventKey)
+			}
+			wantNumberOfEvents := 0
+			for _, count := range tt.wantNumberOfEventsPerEventKey {
+				wantNumberOfEvents += count
+			}
+			if gotAuditRecord.NumberOfEvents != wantNumberOfEvents {
+				t.Errorf("PerformAdminOperation() NumberOfEvents = %d, want %d", gotAuditRecord.NumberOfEvents, wantNumberOfEvents)
+			}
+			if len(gotAuditRecord.NotFoundDocumentIDs) != tt.wantNotFound {
+				t.Errorf("PerformAdminOperation() NotFoundDocumentIDs = %v, want %d", gotAuditRecord.NotFoundDocumentIDs, tt.wantNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Role is the set of endpoints protected by the same authentication
+type Role string
+
+const (
+	// RoleIngestion is the role of the events endpoints
+	RoleIngestion Role = "ingestion"
+	// RoleAdmin is the role of the administration endpoints
+	RoleAdmin Role = "admin"
+	// RoleMetrics is the role of the metrics endpoint. It falls back to the admin authentication if not configured
+	RoleMetrics Role = "metrics"
+)
+
+// APIKeyHeader is the header of the static API keys
+const APIKeyHeader = "X-API-Key"
+
+// googleIssuers are the accepted issuers of the Google-signed ID tokens
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// ErrNoCredentials is returned by an AuthVerifier when the request doesn't contain its type of credentials
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrPermissionDenied is returned by an AuthVerifier when the credentials are valid but the principal is not allowed
+var ErrPermissionDenied = errors.New("permission denied")
+
+// AuthVerifier verifies the credentials of a request and returns the authenticated principal. ErrNoCredentials must
+// be returned if the request doesn't contain the credentials handled by the verifier, to let the other verifiers of
+// the role check the request.
+type AuthVerifier interface {
+	Verify(r *http.Request) (principal string, err error)
+}
+
+// AuthService authenticates the requests per role. The verifiers of each role are built from the configuration, and
+// rebuilt after each configuration reload, plus the custom verifiers added with AddVerifier.
+type AuthService struct {
+	configService    *ConfigService
+	idTokenValidator *idtoken.Validator
+	// mu protects the verifiers swap during a configuration reload
+	mu              sync.RWMutex
+	configVerifiers map[Role][]AuthVerifier
+	customVerifiers map[Role][]AuthVerifier
+}
+
+// NewAuthService creates the Auth service and builds the verifiers of the configuration. The context is required to
+// create the Google ID token validator.
+func NewAuthService(ctx context.Context, configService *ConfigService) (authService *AuthService, err error) {
+	// The Google public certificates are public, no credentials are required to get them
+	idTokenValidator, err := idtoken.NewValidator(ctx, option.WithHTTPClient(http.DefaultClient))
+	if err != nil {
+		return
+	}
+
+	authService = &AuthService{
+		configService:    configService,
+		idTokenValidator: idTokenValidator,
+		customVerifiers:  make(map[Role][]AuthVerifier),
+	}
+	authService.setConfigVerifiers(configService.GetConfig().Auth)
+
+	configService.OnConfigChange(func(previousConfig *models.EventSyncConfig, newConfig *models.EventSyncConfig) {
+		authService.setConfigVerifiers(newConfig.Auth)
+	})
+	return
+}
+
+// AddVerifier adds a custom verifier to the role. The role requires then an authentication, even if it is not
+// defined in the configuration.
+func (a *AuthService) AddVerifier(role Role, verifier AuthVerifier) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.customVerifiers[role] = append(a.customVerifiers[role], verifier)
+}
+
+// setConfigVerifiers builds and replaces the verifiers of the auth configuration
+func (a *AuthService) setConfigVerifiers(auth *models.AuthConfig) {
+	configVerifiers := make(map[Role][]AuthVerifier)
+	if auth != nil {
+		configVerifiers[RoleIngestion] = a.newRoleVerifiers(auth.Ingestion)
+		configVerifiers[RoleAdmin] = a.newRoleVerifiers(auth.Admin)
+		if auth.Metrics != nil {
+			configVerifiers[RoleMetrics] = a.newRoleVerifiers(auth.Metrics)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configVerifiers = configVerifiers
+}
+
+// newRoleVerifiers builds the verifiers of the role authentication configuration
+func (a *AuthService) newRoleVerifiers(roleAuth *models.RoleAuth) (verifiers []AuthVerifier) {
+	if roleAuth == nil {
+		return nil
+	}
+	if roleAuth.GoogleIDToken != nil {
+		verifiers = append(verifiers, &GoogleIDTokenVerifier{
+			validator:  a.idTokenValidator,
+			audiences:  roleAuth.GoogleIDToken.Audiences,
+			principals: roleAuth.GoogleIDToken.Principals,
+		})
+	}
+	if len(roleAuth.APIKeys) > 0 {
+		verifiers = append(verifiers, &APIKeyVerifier{apiKeys: roleAuth.APIKeys})
+	}
+	return
+}
+
+// IsAuthRequired checks if the role has at least one verifier
+func (a *AuthService) IsAuthRequired(role Role) bool {
+	return len(a.getVerifiers(role)) > 0
+}
+
+// getVerifiers returns the configuration and the custom verifiers of the role. The metrics role without verifiers uses
+// the ones of the admin role
+func (a *AuthService) getVerifiers(role Role) []AuthVerifier {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if _, found := a.configVerifiers[role]; role == RoleMetrics && !found && len(a.customVerifiers[role]) == 0 {
+		role = RoleAdmin
+	}
+	verifiers := make([]AuthVerifier, 0, len(a.configVerifiers[role])+len(a.customVerifiers[role]))
+	verifiers = append(verifiers, a.configVerifiers[role]...)
+	return append(verifiers, a.customVerifiers[role]...)
+}
+
+// Authenticate checks the request credentials with the verifiers of the role, and returns the principal of the first
+// valid one. If the role has no verifier, the request is accepted with an empty principal. ErrNoCredentials is
+// returned if the request has no credentials accepted by the role, ErrPermissionDenied (wrapped) if the principal is
+// not allowed.
+func (a *AuthService) Authenticate(r *http.Request, role Role) (principal string, err error) {
+	verifiers := a.getVerifiers(role)
+	if len(verifiers) == 0 {
+		return "", nil
+	}
+
+	err = ErrNoCredentials
+	for _, verifier := range verifiers {
+		var verifyErr error
+		principal, verifyErr = verifier.Verify(r)
+		if verifyErr == nil {
+			return principal, nil
+		}
+		// Keep the most meaningful error: a rejected credential rather than a missing one
+		if !errors.Is(verifyErr, ErrNoCredentials) {
+			err = verifyErr
+		}
+	}
+	return "", err
+}
+
+// GoogleIDTokenVerifier verifies the Google-signed ID tokens of the "Authorization: Bearer" header: the signature,
+// the expiration, the issuer, the audience and, if any, the principal (email or subject).
+type GoogleIDTokenVerifier struct {
+	validator  *idtoken.Validator
+	audiences  []string
+	principals []string
+}
+
+func (g *GoogleIDTokenVerifier) Verify(r *http.Request) (principal string, err error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", ErrNoCredentials
+	}
+
+	// The audience is checked after the validation, to accept several audiences
+	payload, err := g.validator.Validate(r.Context(), token, "")
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("invalid ID token: %s", err))
+	}
+	return g.checkPayload(payload)
+}
+
+// checkPayload checks the issuer, the audience and the principal of the validated ID token payload
+func (g *GoogleIDTokenVerifier) checkPayload(payload *idtoken.Payload) (principal string, err error) {
+	if !containsString(googleIssuers, payload.Issuer) {
+		return "", errors.New(fmt.Sprintf("invalid ID token issuer %q", payload.Issuer))
+	}
+	if !containsString(g.audiences, payload.Audience) {
+		return "", errors.New(fmt.Sprintf("invalid ID token audience %q", payload.Audience))
+	}
+
+	principal = payload.Subject
+	if email, ok := payload.Claims["email"].(string); ok && email != "" {
+		// An unverified email can't be trusted
+		if verified, _ := payload.Claims["email_verified"].(bool); verified {
+			principal = email
+		}
+	}
+	if len(g.principals) > 0 && !containsString(g.principals, principal) && !containsString(g.principals, payload.Subject) {
+		return "", fmt.Errorf("%w: the principal %q is not allowed", ErrPermissionDenied, principal)
+	}
+	return
+}
+
+// APIKeyVerifier verifies the static API keys of the X-API-Key header
+type APIKeyVerifier struct {
+	apiKeys []string
+}
+
+func (a *APIKeyVerifier) Verify(r *http.Request) (principal string, err error) {
+	apiKey := r.Header.Get(APIKeyHeader)
+	if apiKey == "" {
+		return "", ErrNoCredentials
+	}
+	for i, validKey := range a.apiKeys {
+		// Constant time comparison, to not leak the keys through the response time
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(validKey)) == 1 {
+			return fmt.Sprintf("apiKey[%d]", i), nil
+		}
+	}
+	return "", errors.New("invalid API key")
+}
+
+// containsString checks if the value is in the list
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"google.golang.org/api/idtoken"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// staticVerifier is a custom verifier accepting the requests with the "X-Test" header
+type staticVerifier struct{}
+
+func (s *staticVerifier) Verify(r *http.Request) (principal string, err error) {
+	if r.Header.Get("X-Test") == "" {
+		return "", ErrNoCredentials
+	}
+	return "test", nil
+}
+
+func TestAuthService_Authenticate(t *testing.T) {
+	a := &AuthService{customVerifiers: make(map[Role][]AuthVerifier)}
+	a.setConfigVerifiers(&models.AuthConfig{
+		Admin: &models.RoleAuth{APIKeys: []string{"key1", "key2"}},
+	})
+	a.AddVerifier(RoleAdmin, &staticVerifier{})
+
+	tests := []struct {
+		name          string
+		role          Role
+		headers       map[string]string
+		wantPrincipal string
+		wantErr       error
+	}{
+		{
+			name:          "no auth for the ingestion role",
+			role:          RoleIngestion,
+			wantPrincipal: "",
+		},
+		{
+			name:    "no credentials",
+			role:    RoleAdmin,
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name:          "valid API key",
+			role:          RoleAdmin,
+			headers:       map[string]string{APIKeyHeader: "key2"},
+			wantPrincipal: "apiKey[1]",
+		},
+		{
+			name:    "invalid API key",
+			role:    RoleAdmin,
+			headers: map[string]string{APIKeyHeader: "key3"},
+			wantErr: errors.New("invalid API key"),
+		},
+		{
+			name:          "custom verifier",
+			role:          RoleAdmin,
+			headers:       map[string]string{"X-Test": "true"},
+			wantPrincipal: "test",
+		},
+		{
+			name:          "invalid API key but valid custom credentials",
+			role:          RoleAdmin,
+			headers:       map[string]string{APIKeyHeader: "key3", "X-Test": "true"},
+			wantPrincipal: "test",
+		},
+		{
+			name:          "admin API key for the metrics role without auth",
+			role:          RoleMetrics,
+			headers:       map[string]string{APIKeyHeader: "key1"},
+			wantPrincipal: "apiKey[0]",
+		},
+		{
+			name:    "no credentials for the metrics role without auth",
+			role:    RoleMetrics,
+			wantErr: ErrNoCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/config", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			principal, err := a.Authenticate(r, tt.role)
+			if (err != nil) != (tt.wantErr != nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if principal != tt.wantPrincipal {
+				t.Errorf("Authenticate() principal = %q, want %q", principal, tt.wantPrincipal)
+			}
+		})
+	}
+
+	if !a.IsAuthRequired(RoleAdmin) || a.IsAuthRequired(RoleIngestion) {
+		t.Errorf("IsAuthRequired() must be true for the admin role only")
+	}
+
+	// A dedicated metrics authentication replaces the admin one
+	a.setConfigVerifiers(&models.AuthConfig{
+		Admin:   &models.RoleAuth{APIKeys: []string{"key1"}},
+		Metrics: &models.RoleAuth{APIKeys: []string{"scraper"}},
+	})
+	if principal, err := a.Authenticate(withHeader(APIKeyHeader, "scraper"), RoleMetrics); err != nil || principal != "apiKey[0]" {
+		t.Errorf("Authenticate() metrics principal = %q, error = %v, want apiKey[0]", principal, err)
+	}
+	if _, err := a.Authenticate(withHeader(APIKeyHeader, "key1"), RoleMetrics); err == nil {
+		t.Errorf("Authenticate() must reject the admin API key for the metrics role")
+	}
+}
+
+// withHeader returns a request with the header
+func withHeader(name string, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set(name, value)
+	return r
+}
+
+func TestGoogleIDTokenVerifier_checkPayload(t *testing.T) {
+	g := &GoogleIDTokenVerifier{
+		audiences:  []string{"https://eventsync.example.com", "https://other.example.com"},
+		principals: []string{"sa@project.iam.gserviceaccount.com", "1234"},
+	}
+	newPayload := func(issuer string, audience string, subject string, email string, verified bool) *idtoken.Payload {
+		return &idtoken.Payload{
+			Issuer:   issuer,
+			Audience: audience,
+			Subject:  subject,
+			Claims:   map[string]interface{}{"email": email, "email_verified": verified},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		payload       *idtoken.Payload
+		wantPrincipal string
+		wantErr       bool
+		wantDenied    bool
+	}{
+		{
+			name:          "allowed email",
+			payload:       newPayload("https://accounts.google.com", "https://other.example.com", "9999", "sa@project.iam.gserviceaccount.com", true),
+			wantPrincipal: "sa@project.iam.gserviceaccount.com",
+		},
+		{
+			name:          "allowed subject",
+			payload:       newPayload("accounts.google.com", "https://eventsync.example.com", "1234", "", false),
+			wantPrincipal: "1234",
+		},
+		{
+			name:       "unverified email",
+			payload:    newPayload("https://accounts.google.com", "https://eventsync.example.com", "9999", "sa@project.iam.gserviceaccount.com", false),
+			wantErr:    true,
+			wantDenied: true,
+		},
+		{
+			name:       "not allowed principal",
+			payload:    newPayload("https://accounts.google.com", "https://eventsync.example.com", "9999", "user@example.com", true),
+			wantErr:    true,
+			wantDenied: true,
+		},
+		{
+			name:    "invalid audience",
+			payload: newPayload("https://accounts.google.com", "https://unknown.example.com", "1234", "", false),
+			wantErr: true,
+		},
+		{
+			name:    "invalid issuer",
+			payload: newPayload("https://issuer.example.com", "https://eventsync.example.com", "1234", "", false),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := g.checkPayload(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkPayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrPermissionDenied) != tt.wantDenied {
+				t.Errorf("checkPayload() error = %v, wantDenied %v", err, tt.wantDenied)
+			}
+			if principal != tt.wantPrincipal {
+				t.Errorf("checkPayload() principal = %q, want %q", principal, tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+func TestConfigService_checkConfigAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    *models.AuthConfig
+		wantErr bool
+	}{
+		{
+			name: "no auth",
+		},
+		{
+			name: "valid auth",
+			auth: &models.AuthConfig{
+				Ingestion: &models.RoleAuth{APIKeys: []string{"key"}},
+				Admin:     &models.RoleAuth{GoogleIDToken: &models.GoogleIDToken{Audiences: []string{"https://eventsync.example.com"}}},
+			},
+		},
+		{
+			name: "valid metrics auth",
+			auth: &models.AuthConfig{Metrics: &models.RoleAuth{APIKeys: []string{"scraper"}}},
+		},
+		{
+			name:    "empty role",
+			auth:    &models.AuthConfig{Admin: &models.RoleAuth{}},
+			wantErr: true,
+		},
+		{
+			name:    "empty metrics role",
+			auth:    &models.AuthConfig{Metrics: &models.RoleAuth{}},
+			wantErr: true,
+		},
+		{
+			name:    "no audience",
+			auth:    &models.AuthConfig{Admin: &models.RoleAuth{GoogleIDToken: &models.GoogleIDToken{}}},
+			wantErr: true,
+		},
+		{
+			name:    "empty API key",
+			auth:    &models.AuthConfig{Ingestion: &models.RoleAuth{APIKeys: []string{""}}},
+			wantErr: true,
+		},
+		{
+			name:    "undefined environment variable API key",
+			auth:    &models.AuthConfig{Ingestion: &models.RoleAuth{APIKeys: []string{"${INGESTION_API_KEY}"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := generateValidConfig()
+			config.Auth = tt.auth
+			c := &ConfigService{eventSyncConfig: config}
+			if got, _ := c.checkConfigAuth("", ""); (got != "") != tt.wantErr {
+				t.Errorf("checkConfigAuth() got = %v, wantErr %v", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigService_GetRedactedConfig(t *testing.T) {
+	config := generateValidConfig()
+	config.Auth = &models.AuthConfig{Ingestion: &models.RoleAuth{APIKeys: []string{"secret1", "secret2"}}}
+	c := &ConfigService{eventSyncConfig: config}
+
+	redacted := c.GetRedactedConfig()
+	if got := strings.Join(redacted.Auth.Ingestion.APIKeys, ","); got != "REDACTED,REDACTED" {
+		t.Errorf("GetRedactedConfig() apiKeys = %s", got)
+	}
+	if got := strings.Join(c.GetConfig().Auth.Ingestion.APIKeys, ","); got != "secret1,secret2" {
+		t.Errorf("GetRedactedConfig() must not change the configuration, apiKeys = %s", got)
+	}
+}
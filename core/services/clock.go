@@ -0,0 +1,30 @@
+package services
+
+import "time"
+
+// Clock provides the current time to the services: the event dates, the observation windows and the sync message
+// dates. The system clock is used by default (see ConfigService.SetClock).
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+}
+
+// systemClock is the Clock of the system time
+type systemClock struct{}
+
+// Now returns time.Now()
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the default Clock of the services
+var SystemClock Clock = systemClock{}
+
+// FixedClock is a Clock which always returns the same time, to evaluate the state of the service as of a date or for
+// the deterministic tests
+type FixedClock time.Time
+
+// Now returns the fixed time
+func (f FixedClock) Now() time.Time {
+	return time.Time(f)
+}
@@ -1,13 +1,15 @@
 package services
 
 import (
-	"encoding/json"
 	"errors"
-	"eventsync/models"
-	"eventsync/utils"
 	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"log/slog"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 )
 
 // ConfigService in the configuration of EventSync instance. It contains the detail of the loaded configuration
@@ -16,30 +18,102 @@ type ConfigService struct {
 	eventSyncConfig *models.EventSyncConfig
 	// isAsyncEventTriggerMode is the asynchronous processing mode set in the application.
 	isAsyncEventTrigger bool
+	// mu protects the eventSyncConfig swap during a configuration reload
+	mu sync.RWMutex
+	// listeners are notified after each configuration reload
+	listeners []ConfigChangeListener
+	// positions are the positions of the values in the configuration text, to locate the validation errors
+	positions configPositions
+	// clock is the time source of the services, SystemClock if not set
+	clock Clock
+	// logger is the logger of the services, the log/slog default logger if not set
+	logger *slog.Logger
 }
 
+// ConfigChangeListener is notified with the previous and the new configuration after a configuration reload
+type ConfigChangeListener func(previousConfig *models.EventSyncConfig, newConfig *models.EventSyncConfig)
+
 const ConfigEnvVar = "CONFIG"
+
+// ForceAsyncEventTriggerEnvVar forces the post event processing mode: true (asynchronous) or false (synchronous).
+// The runtime default is used if not set
 const ForceAsyncEventTriggerEnvVar = "ASYNC_EVENT_TRIGGER"
 
-// LoadConfig creates a ConfigService based on the JSON config in parameter.
-func LoadConfig(config string) (conf *ConfigService, err error) {
+// LoadConfig creates a ConfigService based on the JSON or YAML config in parameter. The "${VAR}" references are
+// replaced by the environment variables values (see parseConfig). The services log with the logger, the log/slog
+// default logger if nil.
+func LoadConfig(config string, logger *slog.Logger) (conf *ConfigService, err error) {
+
+	conf = &ConfigService{logger: logger}
+	conf.isAsyncEventTrigger = isAsyncEventTriggerMode(utils.GetRuntime(), conf.GetLogger())
+	conf.eventSyncConfig, conf.positions, err = parseConfig(config)
+	return
+}
+
+// ValidateConfig parses and checks the JSON or YAML config in parameter, as at the service start, but offline: the
+// runtime is not inspected and no GCP credentials are required.
+func ValidateConfig(config string) (err error) {
+	conf := &ConfigService{}
+	conf.eventSyncConfig, conf.positions, err = parseConfig(config)
+	if err != nil {
+		return
+	}
+	return conf.CheckConfig()
+}
+
+// ReloadConfig parses and checks the new JSON or YAML config in parameter and, if it is correct, swaps atomically the current
+// configuration with the new one and notifies the listeners. If the new config is invalid, the error is returned and
+// the current configuration stays active. The serviceName can't be changed without a restart: the Firestore
+// collections and indexes depend on it.
+func (c *ConfigService) ReloadConfig(config string) (err error) {
+	newConfig, positions, err := parseConfig(config)
+	if err != nil {
+		return errors.New(fmt.Sprintf("impossible to load the new configuration with error: %s", err))
+	}
+
+	err = (&ConfigService{eventSyncConfig: newConfig, positions: positions, logger: c.logger}).CheckConfig()
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	previousConfig := c.eventSyncConfig
+	if previousConfig.ServiceName != newConfig.ServiceName {
+		c.mu.Unlock()
+		return errors.New(fmt.Sprintf("the serviceName can't be changed from %q to %q without a restart", previousConfig.ServiceName, newConfig.ServiceName))
+	}
+	c.eventSyncConfig = newConfig
+	c.positions = positions
+	listeners := c.listeners
+	c.mu.Unlock()
 
-	conf = &ConfigService{
-		eventSyncConfig:     &models.EventSyncConfig{},
-		isAsyncEventTrigger: isAsyncEventTriggerMode(),
+	for _, listener := range listeners {
+		listener(previousConfig, newConfig)
 	}
-	err = json.Unmarshal([]byte(config), conf.eventSyncConfig)
 	return
 }
 
-func isAsyncEventTriggerMode() bool {
-	forceAsyncEventTriggerConfig := strings.ToLower(os.Getenv(ForceAsyncEventTriggerEnvVar)) == "true"
-	cloudRunCPUThrottledConfig := utils.IsCloudRunCPUThrottled()
+// OnConfigChange registers a listener notified after each configuration reload
+func (c *ConfigService) OnConfigChange(listener ConfigChangeListener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
 
-	if cloudRunCPUThrottledConfig && forceAsyncEventTriggerConfig {
-		fmt.Printf("the environment variable %q is set to TRUE to process asynchronuously the event to trigger, but the current runtime configuration can have issues with multi processing. Be aware of that possible flaws if you have delay or issues on event generation\n", ForceAsyncEventTriggerEnvVar)
+// isAsyncEventTriggerMode returns the post event processing mode forced by the ForceAsyncEventTriggerEnvVar
+// environment variable, else the default mode of the runtime. The runtime is not inspected when the mode is forced.
+func isAsyncEventTriggerMode(runtime utils.Runtime, logger *slog.Logger) bool {
+	switch forceAsyncEventTriggerConfig := strings.ToLower(os.Getenv(ForceAsyncEventTriggerEnvVar)); forceAsyncEventTriggerConfig {
+	case "true", "false":
+		logger.Info("post event processing mode forced", "envVar", ForceAsyncEventTriggerEnvVar, "async", forceAsyncEventTriggerConfig == "true")
+		return forceAsyncEventTriggerConfig == "true"
+	case "":
+	default:
+		logger.Warn("invalid post event processing mode, the runtime default is used", "envVar", ForceAsyncEventTriggerEnvVar, "value", forceAsyncEventTriggerConfig)
 	}
-	return forceAsyncEventTriggerConfig || !cloudRunCPUThrottledConfig
+	async := runtime.DefaultAsyncEventTrigger()
+	logger.Info("post event processing mode of the runtime", "runtime", runtime.Name(), "async", async)
+	return async
 }
 
 // CheckConfig verifies if the provided JSON configuration is operationally correct. A description of the configuration
@@ -57,10 +131,18 @@ func (c *ConfigService) CheckConfig() (err error) {
 
 	logKO, logOK = c.checkConfigTrigger(logKO, logOK)
 
+	logKO, logOK = c.checkConfigAuth(logKO, logOK)
+
+	logKO, logOK = c.checkConfigRedaction(logKO, logOK, "redaction", c.eventSyncConfig.Redaction)
+
+	logKO, logOK = c.checkConfigCors(logKO, logOK)
+
+	logKO, logOK = c.checkConfigLimits(logKO, logOK, "limits", c.eventSyncConfig.Limits)
+
 	if logKO != "" {
 		return errors.New("The configuration contains one or several blocking errors. Here the list:\n" + logKO)
 	}
-	fmt.Println(logOK)
+	c.GetLogger().Info(logOK)
 	return
 }
 
@@ -69,13 +151,13 @@ func (c *ConfigService) checkConfigTrigger(logKO string, logOK string) (string,
 
 	// A trigger must exist
 	if c.eventSyncConfig.Trigger == nil {
-		logKO += fmt.Sprintf("The trigger conditions and type must be set\n")
+		logKO += fmt.Sprintf("The trigger conditions and type must be set%s\n", c.positions.at("trigger"))
 	} else {
 		logOK += fmt.Sprintf("The trigger conditions are:\n")
 
 		// ObservationPeriod must be above 0
 		if c.eventSyncConfig.Trigger.ObservationPeriod <= 0 {
-			logKO += fmt.Sprintf("The ObservationPeriod of the trigger must be > 0\n")
+			logKO += fmt.Sprintf("The ObservationPeriod of the trigger must be > 0%s\n", c.positions.at("trigger.observationPeriod"))
 		} else {
 			logOK += fmt.Sprintf("  - The ObservationPeriod is set to %d seconds\n", c.eventSyncConfig.Trigger.ObservationPeriod)
 		}
@@ -83,7 +165,7 @@ func (c *ConfigService) checkConfigTrigger(logKO string, logOK string) (string,
 		// The trigger type must be this one accepted
 		if c.eventSyncConfig.Trigger.Type != models.TriggerTypeNone &&
 			c.eventSyncConfig.Trigger.Type != models.TriggerTypeWindow {
-			logKO += fmt.Sprintf("The type of the trigger must be %q (based on the observation period and the list of endpoints) or %q (only manual/by API trigger)\n", models.TriggerTypeWindow, models.TriggerTypeNone)
+			logKO += fmt.Sprintf("The type of the trigger must be %q (based on the observation period and the list of endpoints) or %q (only manual/by API trigger)%s\n", models.TriggerTypeWindow, models.TriggerTypeNone, c.positions.at("trigger.type"))
 		} else {
 			logOK += fmt.Sprintf("  - The type of the trigger is %q\n", c.eventSyncConfig.Trigger.Type)
 		}
@@ -105,20 +187,20 @@ func (c *ConfigService) checkConfigEndpoints(logKO string, logOK string) (string
 
 	// At least 2 endpoints must be defined
 	if c.eventSyncConfig.Endpoints == nil || len(c.eventSyncConfig.Endpoints) < 2 {
-		logKO += fmt.Sprintf("The endpoints definition must contains at least 2 entries\n")
+		logKO += fmt.Sprintf("The endpoints definition must contains at least 2 entries%s\n", c.positions.at("endpoints"))
 	} else {
 		logOK += fmt.Sprintf("The defined endpoints are:\n")
 		for i, endpoint := range c.eventSyncConfig.Endpoints {
 			logOK += fmt.Sprintf("  %d. The eventKey is %q. The path to reach for using it is %s%s\n", i+1, endpoint.EventKey, EventPathPrefix, endpoint.EventKey)
 
 			if endpoint.EventKey == "" {
-				logKO += fmt.Sprintf("The endpoint eventKey must not be empty at index %d\n", i)
+				logKO += fmt.Sprintf("The endpoint eventKey must not be empty at index %d%s\n", i, c.positions.at(fmt.Sprintf("endpoints[%d].eventKey", i)))
 			}
 
 			// Check the endpoint eventKey unicity
 			for j := 0; j < i; j++ {
 				if endpoint.EventKey == c.eventSyncConfig.Endpoints[j].EventKey {
-					logKO += fmt.Sprintf("The endpoints eventKeys must be unique. the eventKey %q is duplicated at index %d and %q\n", endpoint.EventKey, j, i)
+					logKO += fmt.Sprintf("The endpoints eventKeys must be unique. the eventKey %q is duplicated at index %d and %d%s\n", endpoint.EventKey, j, i, c.positions.at(fmt.Sprintf("endpoints[%d].eventKey", i)))
 				}
 			}
 
@@ -130,7 +212,7 @@ func (c *ConfigService) checkConfigEndpoints(logKO string, logOK string) (string
 					// Update with the upper case value
 					endpoint.AcceptedHttpMethods[j] = m
 				default:
-					logKO += fmt.Sprintf("The accepted method %q is not valid for tne endpoint eventKey %q\n", method, endpoint.EventKey)
+					logKO += fmt.Sprintf("The accepted method %q is not valid for tne endpoint eventKey %q%s\n", method, endpoint.EventKey, c.positions.at(fmt.Sprintf("endpoints[%d].acceptedHttpMethods[%d]", i, j)))
 				}
 			}
 			if len(endpoint.AcceptedHttpMethods) == 0 {
@@ -155,38 +237,191 @@ func (c *ConfigService) checkConfigEndpoints(logKO string, logOK string) (string
 				case models.EventToSendTypeLast:
 					logOK += fmt.Sprintf("     only the latest event in the observation period will be included in the generated event sync message\n")
 				default:
-					logKO += fmt.Sprintf("The event to send value %q is not valid for tne endpoint eventKey %q. Accepted values are: ALL, FIRST, LAST, BOUNDARIES\n", endpoint.EventToSend, endpoint.EventKey)
+					logKO += fmt.Sprintf("The event to send value %q is not valid for tne endpoint eventKey %q. Accepted values are: ALL, FIRST, LAST, BOUNDARIES%s\n", endpoint.EventToSend, endpoint.EventKey, c.positions.at(fmt.Sprintf("endpoints[%d].eventToSend", i)))
 				}
 			}
 
 			// Check the min occurrence
 			if endpoint.MinNbOfOccurrence < 0 {
-				logKO += fmt.Sprintf("The minimal number of required event must be strictly positive for tne endpoint eventKey %q\n", endpoint.EventKey)
+				logKO += fmt.Sprintf("The minimal number of required event must be strictly positive for tne endpoint eventKey %q%s\n", endpoint.EventKey, c.positions.at(fmt.Sprintf("endpoints[%d].minNbOfOccurrence", i)))
 			}
 			if endpoint.MinNbOfOccurrence == 0 {
 				// Set one by default
 				endpoint.MinNbOfOccurrence = 1
 			}
 			logOK += fmt.Sprintf("     The minimal number of required event is set to %d\n", endpoint.MinNbOfOccurrence)
+
+			logKO, logOK = c.checkConfigVerification(logKO, logOK, i, endpoint)
+
+			logKO, logOK = c.checkConfigRedaction(logKO, logOK, fmt.Sprintf("endpoints[%d].redaction", i), endpoint.Redaction)
+
+			if endpoint.Limits != nil {
+				logKO, logOK = c.checkConfigLimits(logKO, logOK, fmt.Sprintf("endpoints[%d].limits", i), endpoint.Limits)
+			}
 		}
 	}
 	return logKO, logOK
 }
 
+// checkConfigVerification checks if the provided verification of the endpoint at the index is correct and return the
+// corresponding log strings
+func (c *ConfigService) checkConfigVerification(logKO string, logOK string, index int, endpoint *models.Endpoint) (string, string) {
+	verification := endpoint.Verification
+	if verification == nil {
+		return logKO, logOK
+	}
+	path := fmt.Sprintf("endpoints[%d].verification", index)
+
+	switch verification.Type {
+	case models.VerificationTypeHmacSha256:
+		if verification.Header == "" {
+			logKO += fmt.Sprintf("The verification header must be set for the %q type on the endpoint eventKey %q%s\n", verification.Type, endpoint.EventKey, c.positions.at(path))
+		}
+		switch verification.Encoding {
+		case "":
+			verification.Encoding = models.SignatureEncodingHex
+		case models.SignatureEncodingHex, models.SignatureEncodingBase64:
+		default:
+			logKO += fmt.Sprintf("The verification encoding %q is not valid for the endpoint eventKey %q. Accepted values are: hex, base64%s\n", verification.Encoding, endpoint.EventKey, c.positions.at(path+".encoding"))
+		}
+	case models.VerificationTypeGithub, models.VerificationTypeStripe:
+		if verification.Header != "" || verification.Prefix != "" || verification.Encoding != "" || verification.TimestampHeader != "" {
+			logKO += fmt.Sprintf("The verification header, prefix, encoding and timestampHeader are preset for the %q type on the endpoint eventKey %q%s\n", verification.Type, endpoint.EventKey, c.positions.at(path))
+		}
+	default:
+		logKO += fmt.Sprintf("The verification type %q is not valid for the endpoint eventKey %q. Accepted values are: %s, %s, %s%s\n", verification.Type, endpoint.EventKey, models.VerificationTypeHmacSha256, models.VerificationTypeGithub, models.VerificationTypeStripe, c.positions.at(path+".type"))
+	}
+
+	if (verification.Secret == "") == (verification.SecretFile == "") {
+		logKO += fmt.Sprintf("The verification of the endpoint eventKey %q must define a secret or a secretFile, not both%s\n", endpoint.EventKey, c.positions.at(path))
+	} else if envVarReference.MatchString(verification.Secret) {
+		// An undefined environment variable is kept as is: the HMAC key would be the reference itself
+		logKO += fmt.Sprintf("The verification secret of the endpoint eventKey %q must not reference an undefined environment variable%s\n", endpoint.EventKey, c.positions.at(path+".secret"))
+	}
+	if verification.ToleranceSeconds < 0 {
+		logKO += fmt.Sprintf("The verification toleranceSeconds must be positive for the endpoint eventKey %q%s\n", endpoint.EventKey, c.positions.at(path+".toleranceSeconds"))
+	}
+	logOK += fmt.Sprintf("     the events signature is verified (%s)\n", verification.Type)
+	return logKO, logOK
+}
+
+// checkConfigRedaction checks if the provided redaction rules, at the path, are correct and return the corresponding
+// log strings
+func (c *ConfigService) checkConfigRedaction(logKO string, logOK string, path string, redaction *models.Redaction) (string, string) {
+	if redaction == nil {
+		return logKO, logOK
+	}
+	for i, jsonPath := range redaction.MaskedJSONPaths {
+		if _, err := parseJSONPath(jsonPath); err != nil {
+			logKO += fmt.Sprintf("The %s maskedJsonPaths are invalid: %s%s\n", path, err, c.positions.at(fmt.Sprintf("%s.maskedJsonPaths[%d]", path, i)))
+		}
+	}
+	for i, header := range redaction.AllowedHeaders {
+		if header == "" {
+			logKO += fmt.Sprintf("The %s allowedHeaders must not be empty at index %d%s\n", path, i, c.positions.at(fmt.Sprintf("%s.allowedHeaders[%d]", path, i)))
+		}
+	}
+	logOK += fmt.Sprintf("  The %s rules are: allowed headers %v, redacted headers %v, masked JSON paths %v, removed query parameters %v\n",
+		path, redaction.AllowedHeaders, redaction.DeniedHeaders, redaction.MaskedJSONPaths, redaction.RemovedQueryParams)
+	return logKO, logOK
+}
+
+// checkConfigLimits checks if the provided limits, at the path, are correct and return the corresponding log strings.
+// The global limits are always reported, with the default values.
+func (c *ConfigService) checkConfigLimits(logKO string, logOK string, path string, limits *models.Limits) (string, string) {
+	if limits == nil {
+		logOK += fmt.Sprintf("The event bodies are limited to %d bytes, without rate and concurrency limits\n", defaultMaxBodyBytes)
+		return logKO, logOK
+	}
+	if limits.RequestsPerSecond < 0 {
+		logKO += fmt.Sprintf("The %s requestsPerSecond must be >= 0, here %v%s\n", path, limits.RequestsPerSecond, c.positions.at(path+".requestsPerSecond"))
+	}
+	if limits.Burst < 0 {
+		logKO += fmt.Sprintf("The %s burst must be >= 0, here %d%s\n", path, limits.Burst, c.positions.at(path+".burst"))
+	}
+	if limits.MaxBodyBytes < 0 {
+		logKO += fmt.Sprintf("The %s maxBodyBytes must be >= 0, here %d%s\n", path, limits.MaxBodyBytes, c.positions.at(path+".maxBodyBytes"))
+	}
+	if limits.MaxConcurrentRequests < 0 {
+		logKO += fmt.Sprintf("The %s maxConcurrentRequests must be >= 0, here %d%s\n", path, limits.MaxConcurrentRequests, c.positions.at(path+".maxConcurrentRequests"))
+	}
+
+	logOK += fmt.Sprintf("The %s are:\n", path)
+	if limits.RequestsPerSecond > 0 {
+		logOK += fmt.Sprintf("  - %v events per second, with a burst of %d\n", limits.RequestsPerSecond, getBurst(limits))
+	} else {
+		logOK += fmt.Sprintf("  - no rate limit\n")
+	}
+	if limits.MaxBodyBytes > 0 {
+		logOK += fmt.Sprintf("  - event bodies of %d bytes maximum\n", limits.MaxBodyBytes)
+	} else if path == "limits" {
+		logOK += fmt.Sprintf("  - event bodies of %d bytes maximum (default)\n", defaultMaxBodyBytes)
+	}
+	if limits.MaxConcurrentRequests > 0 {
+		logOK += fmt.Sprintf("  - %d concurrent events maximum\n", limits.MaxConcurrentRequests)
+	} else {
+		logOK += fmt.Sprintf("  - no concurrency limit\n")
+	}
+	return logKO, logOK
+}
+
+// checkConfigCors checks if the provided cors configuration is correct and return the corresponding log strings
+func (c *ConfigService) checkConfigCors(logKO string, logOK string) (string, string) {
+	cors := c.eventSyncConfig.Cors
+	if cors == nil {
+		if os.Getenv(DisableCorsEnvVar) != "" {
+			logOK += fmt.Sprintf("All the origins are allowed by the deprecated %s environment variable\n", DisableCorsEnvVar)
+		} else {
+			logOK += fmt.Sprintf("No cross-origin request allowed\n")
+		}
+		return logKO, logOK
+	}
+
+	if len(cors.AllowedOrigins) == 0 {
+		logKO += fmt.Sprintf("The cors allowedOrigins must define at least one origin%s\n", c.positions.at("cors"))
+	}
+	for i, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			if cors.AllowCredentials {
+				logKO += fmt.Sprintf("The cors allowedOrigins \"*\" is not compatible with allowCredentials%s\n", c.positions.at(fmt.Sprintf("cors.allowedOrigins[%d]", i)))
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			logKO += fmt.Sprintf("The cors allowedOrigins %q must be \"*\" or like \"https://app.example.com\"%s\n", origin, c.positions.at(fmt.Sprintf("cors.allowedOrigins[%d]", i)))
+		}
+	}
+	for i, method := range cors.AllowedMethods {
+		m := models.HttpMethodType(strings.ToUpper(string(method)))
+		switch m {
+		case models.HttpMethodTypePut, models.HttpMethodTypeConnect, models.HttpMethodTypeDelete, models.HttpMethodTypeOptions, models.HttpMethodTypeHead, models.HttpMethodTypePost, models.HttpMethodTypeTrace, models.HttpMethodTypeGet:
+			cors.AllowedMethods[i] = m
+		default:
+			logKO += fmt.Sprintf("The cors allowedMethods %q is not valid%s\n", method, c.positions.at(fmt.Sprintf("cors.allowedMethods[%d]", i)))
+		}
+	}
+	if cors.MaxAgeSeconds < 0 {
+		logKO += fmt.Sprintf("The cors maxAgeSeconds must be >= 0, here %d%s\n", cors.MaxAgeSeconds, c.positions.at("cors.maxAgeSeconds"))
+	}
+	logOK += fmt.Sprintf("The cross-origin requests are allowed for the origins %v (credentials %t)\n", cors.AllowedOrigins, cors.AllowCredentials)
+	return logKO, logOK
+}
+
 // checkConfigTargetPubSub checks if the provided targetPubSub configuration is correct and return the corresponding
 // log strings
 func (c *ConfigService) checkConfigTargetPubSub(logKO string, logOK string) (string, string) {
 
 	// The PubSUb target must exist
 	if c.eventSyncConfig.TargetPubSub == nil {
-		logKO += fmt.Sprintf("The targetPubSub definition must be set\n")
+		logKO += fmt.Sprintf("The targetPubSub definition must be set%s\n", c.positions.at("targetPubSub"))
 	} else {
 		logOK += fmt.Sprintf("The triggered event will be sent to PubSub:\n")
 
 		// The topic format is the fully qualified name projects/<ProjectID>/topics/<TopicName>
 		topicSplit := strings.Split(c.eventSyncConfig.TargetPubSub.Topic, "/")
 		if len(topicSplit) != 4 {
-			logKO += fmt.Sprintf("The topic format of must be \"projects/<ProjectID>/topics/<TopicName>\", here %q", c.eventSyncConfig.TargetPubSub.Topic)
+			logKO += fmt.Sprintf("The topic format of must be \"projects/<ProjectID>/topics/<TopicName>\", here %q%s\n", c.eventSyncConfig.TargetPubSub.Topic, c.positions.at("targetPubSub.topic"))
 		} else {
 			logOK += fmt.Sprintf("  - The project of the topic is %q\n", topicSplit[1])
 			logOK += fmt.Sprintf("  - The topic name is %q\n", topicSplit[3])
@@ -195,10 +430,66 @@ func (c *ConfigService) checkConfigTargetPubSub(logKO string, logOK string) (str
 	return logKO, logOK
 }
 
+// checkConfigAuth checks if the provided auth configuration is correct and return the corresponding log strings
+func (c *ConfigService) checkConfigAuth(logKO string, logOK string) (string, string) {
+	auth := c.eventSyncConfig.Auth
+	if auth == nil {
+		logOK += fmt.Sprintf("No authentication performed by the application\n")
+		return logKO, logOK
+	}
+
+	roles := []struct {
+		name     string
+		roleAuth *models.RoleAuth
+	}{
+		{name: string(RoleIngestion), roleAuth: auth.Ingestion},
+		{name: string(RoleAdmin), roleAuth: auth.Admin},
+		{name: string(RoleMetrics), roleAuth: auth.Metrics},
+	}
+	for _, role := range roles {
+		if role.roleAuth == nil && role.name == string(RoleMetrics) && auth.Admin != nil {
+			logOK += fmt.Sprintf("The %s role uses the %s authentication\n", RoleMetrics, RoleAdmin)
+			continue
+		}
+		if role.roleAuth == nil {
+			logOK += fmt.Sprintf("No authentication performed by the application for the %s role\n", role.name)
+			continue
+		}
+		path := "auth." + role.name
+		if role.roleAuth.GoogleIDToken == nil && len(role.roleAuth.APIKeys) == 0 {
+			logKO += fmt.Sprintf("The %s authentication must define a googleIdToken or apiKeys%s\n", role.name, c.positions.at(path))
+			continue
+		}
+		logOK += fmt.Sprintf("The %s role accepts:\n", role.name)
+		if role.roleAuth.GoogleIDToken != nil {
+			if len(role.roleAuth.GoogleIDToken.Audiences) == 0 {
+				logKO += fmt.Sprintf("The %s googleIdToken must define at least one audience%s\n", role.name, c.positions.at(path+".googleIdToken"))
+			}
+			if len(role.roleAuth.GoogleIDToken.Principals) == 0 {
+				logOK += fmt.Sprintf("  - the Google ID tokens of the audiences %v, for all the principals\n", role.roleAuth.GoogleIDToken.Audiences)
+			} else {
+				logOK += fmt.Sprintf("  - the Google ID tokens of the audiences %v, for the principals %v\n", role.roleAuth.GoogleIDToken.Audiences, role.roleAuth.GoogleIDToken.Principals)
+			}
+		}
+		for i, apiKey := range role.roleAuth.APIKeys {
+			if apiKey == "" {
+				logKO += fmt.Sprintf("The %s apiKeys must not be empty at index %d%s\n", role.name, i, c.positions.at(fmt.Sprintf("%s.apiKeys[%d]", path, i)))
+			} else if envVarReference.MatchString(apiKey) {
+				// An undefined environment variable is kept as is: the key would be the reference itself
+				logKO += fmt.Sprintf("The %s apiKeys must not reference an undefined environment variable at index %d%s\n", role.name, i, c.positions.at(fmt.Sprintf("%s.apiKeys[%d]", path, i)))
+			}
+		}
+		if len(role.roleAuth.APIKeys) > 0 {
+			logOK += fmt.Sprintf("  - %d API keys\n", len(role.roleAuth.APIKeys))
+		}
+	}
+	return logKO, logOK
+}
+
 // checkConfigRootValues checks if the provided root configuration is correct and return the corresponding log strings
 func (c *ConfigService) checkConfigRootValues(logKO string, logOK string) (string, string) {
 	if c.eventSyncConfig.ServiceName == "" {
-		logKO += fmt.Sprintf("The ServiceName must be set\n")
+		logKO += fmt.Sprintf("The ServiceName must be set%s\n", c.positions.at("serviceName"))
 	} else {
 		logOK += fmt.Sprintf("The service name set is %q, it will be use as collection in Firestore.\n", c.eventSyncConfig.ServiceName)
 	}
@@ -207,9 +498,77 @@ func (c *ConfigService) checkConfigRootValues(logKO string, logOK string) (strin
 
 // GetConfig returns the stored configuration of the service.
 func (c *ConfigService) GetConfig() (eventSyncConfig *models.EventSyncConfig) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.eventSyncConfig
 }
 
+// SetClock replaces the time source of the services sharing the configuration. To call before the services start
+func (c *ConfigService) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+// GetClock returns the time source of the services, SystemClock by default
+func (c *ConfigService) GetClock() Clock {
+	if c.clock == nil {
+		return SystemClock
+	}
+	return c.clock
+}
+
+// SetLogger replaces the logger of the services sharing the configuration. To call before the services start
+func (c *ConfigService) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+// GetLogger returns the logger of the services, the log/slog default logger by default
+func (c *ConfigService) GetLogger() *slog.Logger {
+	if c.logger == nil {
+		return slog.Default()
+	}
+	return c.logger
+}
+
+// redactedValue replaces the secrets in the exposed configuration
+const redactedValue = "REDACTED"
+
+// GetRedactedConfig returns a copy of the stored configuration without the secrets, to be exposed
+func (c *ConfigService) GetRedactedConfig() (eventSyncConfig *models.EventSyncConfig) {
+	config := *c.GetConfig()
+	config.Endpoints = make([]*models.Endpoint, 0, len(c.GetConfig().Endpoints))
+	for _, endpoint := range c.GetConfig().Endpoints {
+		if endpoint.Verification != nil && endpoint.Verification.Secret != "" {
+			redactedEndpoint := *endpoint
+			verification := *endpoint.Verification
+			verification.Secret = redactedValue
+			redactedEndpoint.Verification = &verification
+			endpoint = &redactedEndpoint
+		}
+		config.Endpoints = append(config.Endpoints, endpoint)
+	}
+	if config.Auth != nil {
+		auth := *config.Auth
+		auth.Ingestion = redactRoleAuth(auth.Ingestion)
+		auth.Admin = redactRoleAuth(auth.Admin)
+		auth.Metrics = redactRoleAuth(auth.Metrics)
+		config.Auth = &auth
+	}
+	return &config
+}
+
+// redactRoleAuth returns a copy of the role authentication with the API keys masked
+func redactRoleAuth(roleAuth *models.RoleAuth) *models.RoleAuth {
+	if roleAuth == nil {
+		return nil
+	}
+	redacted := *roleAuth
+	redacted.APIKeys = make([]string, len(roleAuth.APIKeys))
+	for i := range redacted.APIKeys {
+		redacted.APIKeys[i] = redactedValue
+	}
+	return &redacted
+}
+
 // IsAsyncEventTriggerProcessing returns true is the event trigger computation and event sync message generation has
 // to be done asynchronously (after the request response sent)
 func (c *ConfigService) IsAsyncEventTriggerProcessing() bool {
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// envVarReference matches the escaped "$${" sequence, or a "${VAR}" or "${VAR:-default}" environment variable
+// reference
+var envVarReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// configPositions maps the path of each configuration value (for instance "endpoints[1].eventKey") to its position
+// in the configuration text
+type configPositions map[string]string
+
+// at returns the position of the path, or of its nearest parent, in " (line X, column Y)" format. Empty string if
+// unknown.
+func (p configPositions) at(path string) string {
+	for path != "" {
+		if position, ok := p[path]; ok {
+			return " (" + position + ")"
+		}
+		lastSeparator := strings.LastIndexAny(path, ".[")
+		if lastSeparator < 0 {
+			break
+		}
+		path = path[:lastSeparator]
+	}
+	return ""
+}
+
+// parseConfig unmarshals the JSON or YAML config in parameter and interpolates the environment variables references in
+// its values. The format is detected automatically: a config starting with "{" is JSON, else YAML. The errors include
+// the line and the column of the invalid value in the config text. The position of each value is returned to locate
+// the validation errors.
+func parseConfig(config string) (eventSyncConfig *models.EventSyncConfig, positions configPositions, err error) {
+	eventSyncConfig = &models.EventSyncConfig{}
+	positions = configPositions{}
+
+	// JSON is a subset of YAML, the positions are extracted for both formats. Some JSON escapes, like "\/", are not
+	// valid in YAML: the JSON values are read with the JSON parser
+	root := &yaml.Node{}
+	yamlErr := yaml.Unmarshal([]byte(config), root)
+	if yamlErr == nil && len(root.Content) > 0 {
+		collectPositions(root.Content[0], "", positions)
+	}
+
+	var generic interface{}
+	if strings.HasPrefix(strings.TrimSpace(config), "{") {
+		if err = json.Unmarshal([]byte(config), &generic); err != nil {
+			return eventSyncConfig, positions, locateJSONError(config, err)
+		}
+		generic = interpolateValue(generic)
+	} else {
+		if yamlErr != nil {
+			return eventSyncConfig, positions, errors.New(fmt.Sprintf("invalid YAML configuration: %s", strings.TrimPrefix(yamlErr.Error(), "yaml: ")))
+		}
+		if len(root.Content) == 0 {
+			return eventSyncConfig, positions, errors.New("the configuration is empty")
+		}
+		interpolateNode(root.Content[0])
+		if err = root.Decode(&generic); err != nil {
+			return
+		}
+	}
+
+	// Convert the values to JSON to reuse the JSON field names of the models
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return eventSyncConfig, positions, errors.New(fmt.Sprintf("invalid configuration: %s", err))
+	}
+	err = json.Unmarshal(data, eventSyncConfig)
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok && len(root.Content) > 0 {
+		if node := findMismatchedNode(root.Content[0], strings.Split(typeErr.Field, "."), typeErr.Type); node != nil {
+			err = errors.New(fmt.Sprintf("line %d, column %d: cannot use %q as %s for the field %q", node.Line, node.Column, node.Value, typeErr.Type, typeErr.Field))
+		}
+	}
+	return
+}
+
+// interpolateNode interpolates the environment variables references in the scalar values of the node tree. The keys
+// are not interpolated. The type of the unquoted values is resolved again after the interpolation, for instance to
+// read "${PERIOD}" as an int.
+func interpolateNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			interpolateNode(node.Content[i])
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			interpolateNode(child)
+		}
+	case yaml.ScalarNode:
+		value := interpolateEnvVars(node.Value)
+		if value == node.Value {
+			return
+		}
+		node.Value = value
+		if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Tag = ""
+			node.Tag = node.ShortTag()
+		}
+	}
+}
+
+// interpolateValue interpolates the environment variables references in the string values of the JSON value. The keys
+// are not interpolated.
+func interpolateValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typedValue {
+			typedValue[key] = interpolateValue(child)
+		}
+	case []interface{}:
+		for i, child := range typedValue {
+			typedValue[i] = interpolateValue(child)
+		}
+	case string:
+		return interpolateEnvVars(typedValue)
+	}
+	return value
+}
+
+// interpolateEnvVars replaces the "${VAR}" references with the value of the VAR environment variable, and the
+// "${VAR:-default}" references with the default value if VAR is not set or empty. "$${" is replaced by a literal "${".
+// The references to undefined environment variables, without default value, are kept as is.
+func interpolateEnvVars(value string) string {
+	return envVarReference.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+		match := envVarReference.FindStringSubmatch(reference)
+		envValue, defined := os.LookupEnv(match[1])
+		if envValue == "" && match[2] != "" {
+			return match[3]
+		}
+		if !defined {
+			return reference
+		}
+		return envValue
+	})
+}
+
+// locateJSONError adds the line and the column of the JSON syntax and type errors
+func locateJSONError(config string, err error) error {
+	var offset int64
+	switch jsonErr := err.(type) {
+	case *json.SyntaxError:
+		offset = jsonErr.Offset
+	case *json.UnmarshalTypeError:
+		offset = jsonErr.Offset
+	default:
+		return err
+	}
+	line, column := offsetToPosition(config, int(offset))
+	return errors.New(fmt.Sprintf("line %d, column %d: %s", line, column, strings.TrimPrefix(err.Error(), "json: ")))
+}
+
+// offsetToPosition converts a byte offset in the text to a line and column, both starting at 1
+func offsetToPosition(text string, offset int) (line int, column int) {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	before := text[:offset]
+	line = strings.Count(before, "\n") + 1
+	column = offset - strings.LastIndex(before, "\n")
+	return
+}
+
+// collectPositions walks the YAML node tree and records the position of each value under its path
+func collectPositions(node *yaml.Node, path string, positions configPositions) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if path != "" {
+		positions[path] = fmt.Sprintf("line %d, column %d", node.Line, node.Column)
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			collectPositions(node.Content[i+1], key, positions)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			collectPositions(child, fmt.Sprintf("%s[%d]", path, i), positions)
+		}
+	}
+}
+
+// findMismatchedNode returns the first node at the path (as provided by the JSON type errors, with or without the
+// sequence indexes) which can't be converted to the expected type. Nil if not found.
+func findMismatchedNode(node *yaml.Node, path []string, expected reflect.Type) *yaml.Node {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.SequenceNode && len(path) > 0 {
+		// Recent Go versions provide the sequence index in the field path
+		if index, err := strconv.Atoi(path[0]); err == nil {
+			if index >= len(node.Content) {
+				return nil
+			}
+			return findMismatchedNode(node.Content[index], path[1:], expected)
+		}
+	}
+	if node.Kind == yaml.SequenceNode && (len(path) > 0 || expected.Kind() != reflect.Slice) {
+		for _, child := range node.Content {
+			if found := findMismatchedNode(child, path, expected); found != nil {
+				return found
+			}
+		}
+		if len(path) == 0 {
+			// A sequence instead of a single value
+			return node
+		}
+		return nil
+	}
+	if len(path) == 0 || (len(path) == 1 && path[0] == "") {
+		if isMismatched(node, expected) {
+			return node
+		}
+		return nil
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == path[0] {
+				return findMismatchedNode(node.Content[i+1], path[1:], expected)
+			}
+		}
+	}
+	return nil
+}
+
+// isMismatched checks if the YAML node can't be converted to the expected type
+func isMismatched(node *yaml.Node, expected reflect.Type) bool {
+	for expected.Kind() == reflect.Ptr {
+		expected = expected.Elem()
+	}
+	switch expected.Kind() {
+	case reflect.String:
+		return node.Kind != yaml.ScalarNode || node.Tag != "!!str"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return node.Kind != yaml.ScalarNode || node.Tag != "!!int"
+	case reflect.Float32, reflect.Float64:
+		return node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float")
+	case reflect.Bool:
+		return node.Kind != yaml.ScalarNode || node.Tag != "!!bool"
+	case reflect.Struct, reflect.Map:
+		return node.Kind != yaml.MappingNode
+	case reflect.Slice, reflect.Array:
+		return node.Kind != yaml.SequenceNode
+	}
+	return true
+}
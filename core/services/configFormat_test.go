@@ -0,0 +1,197 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const yamlConfig = `serviceName: myTest
+endpoints:
+  - eventKey: entry1
+    acceptedHttpMethods: [GET]
+    eventToSend: ALL
+    minNbOfOccurrence: 1
+  - eventKey: entry2
+    eventToSend: ALL
+    minNbOfOccurrence: 1
+    acceptedHttpMethods:
+      - GET
+      - PUT
+      - POST
+      - TRACE
+      - OPTIONS
+      - HEAD
+      - DELETE
+      - CONNECT
+trigger:
+  type: window
+  observationPeriod: 3600
+  keepEventAfterTrigger: false
+targetPubSub:
+  topic: projects/${TEST_PROJECT}/topics/${TEST_TOPIC:-eventsync}
+`
+
+const jsonConfig = `{
+  "serviceName": "myTest",
+  "endpoints": [
+    {"eventKey": "entry1", "acceptedHttpMethods": ["GET"], "eventToSend": "ALL", "minNbOfOccurrence": 1},
+    {"eventKey": "entry2", "eventToSend": "ALL", "minNbOfOccurrence": 1,
+      "acceptedHttpMethods": ["GET", "PUT", "POST", "TRACE", "OPTIONS", "HEAD", "DELETE", "CONNECT"]}
+  ],
+  "trigger": {"type": "window", "observationPeriod": 3600, "keepEventAfterTrigger": false},
+  "targetPubSub": {"topic": "projects/${TEST_PROJECT}/topics/eventsync"}
+}`
+
+func TestConfigService_parseConfig(t *testing.T) {
+	t.Setenv("TEST_PROJECT", "project123")
+	t.Setenv("TEST_PERIOD", "3600")
+	t.Setenv("TEST_TRIGGER_TYPE", "window")
+
+	tests := []struct {
+		name       string
+		config     string
+		wantErr    string
+		wantConfig bool
+		wantTopic  string
+	}{
+		{
+			name:       "YAML config",
+			config:     yamlConfig,
+			wantConfig: true,
+		},
+		{
+			name:       "JSON config",
+			config:     jsonConfig,
+			wantConfig: true,
+		},
+		{
+			name:    "YAML type error",
+			config:  strings.Replace(yamlConfig, "observationPeriod: 3600", "observationPeriod: one hour", 1),
+			wantErr: "line 21, column 22",
+		},
+		{
+			name:    "YAML type error in sequence",
+			config:  strings.Replace(yamlConfig, "minNbOfOccurrence: 1\n  - eventKey", "minNbOfOccurrence: [1]\n  - eventKey", 1),
+			wantErr: "line 6, column 24",
+		},
+		{
+			name:    "JSON type error",
+			config:  strings.Replace(jsonConfig, `"observationPeriod": 3600`, `"observationPeriod": "3600"`, 1),
+			wantErr: "line 8, column 54",
+		},
+		{
+			name:    "JSON syntax error",
+			config:  strings.Replace(jsonConfig, `"myTest",`, `"myTest"`, 1),
+			wantErr: "line 3, column 4",
+		},
+		{
+			name:      "undefined environment variable kept as is",
+			config:    strings.Replace(yamlConfig, "${TEST_PROJECT}", "${TEST_UNDEFINED}", 1),
+			wantTopic: "projects/${TEST_UNDEFINED}/topics/eventsync",
+		},
+		{
+			name:       "YAML int value interpolated",
+			config:     strings.Replace(yamlConfig, "observationPeriod: 3600", "observationPeriod: ${TEST_PERIOD}", 1),
+			wantConfig: true,
+		},
+		{
+			name: "JSON type error positioned in the file",
+			config: strings.Replace(jsonConfig, `"type": "window", "observationPeriod": 3600`,
+				`"type": "${TEST_TRIGGER_TYPE}", "observationPeriod": "3600"`, 1),
+			wantErr: "line 8, column 68",
+		},
+		{
+			name:    "YAML syntax error positioned in the file",
+			config:  strings.Replace(yamlConfig, "serviceName: myTest", "serviceName: ${TEST_PROJECT}\n  bad: indent", 1),
+			wantErr: "line 2",
+		},
+		{
+			name:    "empty config",
+			config:  "",
+			wantErr: "the configuration is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := parseConfig(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseConfig() error = %v", err)
+			}
+			if tt.wantConfig && !reflect.DeepEqual(got, generateValidConfig()) {
+				t.Errorf("parseConfig() got = %+v, want %+v", got, generateValidConfig())
+			}
+			if tt.wantTopic != "" && got.TargetPubSub.Topic != tt.wantTopic {
+				t.Errorf("parseConfig() topic = %q, want %q", got.TargetPubSub.Topic, tt.wantTopic)
+			}
+		})
+	}
+}
+
+func TestConfigService_interpolateEnvVars(t *testing.T) {
+	t.Setenv("TEST_SET", "value")
+	t.Setenv("TEST_EMPTY", "")
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{
+			name:  "no reference",
+			value: "myTest",
+			want:  "myTest",
+		},
+		{
+			name:  "set variable",
+			value: "${TEST_SET}-${TEST_SET}",
+			want:  "value-value",
+		},
+		{
+			name:  "empty variable",
+			value: "${TEST_EMPTY}",
+			want:  "",
+		},
+		{
+			name:  "default value",
+			value: "${TEST_UNDEFINED:-default}, ${TEST_EMPTY:-default}, ${TEST_SET:-default}",
+			want:  "default, default, value",
+		},
+		{
+			name:  "escaped reference",
+			value: "$${TEST_SET}",
+			want:  "${TEST_SET}",
+		},
+		{
+			name:  "undefined variable",
+			value: "$.body.${TEST_UNDEFINED}",
+			want:  "$.body.${TEST_UNDEFINED}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := interpolateEnvVars(tt.value)
+			if got != tt.want {
+				t.Errorf("interpolateEnvVars() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigService_CheckConfigPositions(t *testing.T) {
+	t.Setenv("TEST_PROJECT", "project123")
+	c, err := LoadConfig(strings.Replace(yamlConfig, "eventKey: entry2", "eventKey: entry1", 1), nil)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	err = c.CheckConfig()
+	if err == nil || !strings.Contains(err.Error(), "(line 7, column 15)") {
+		t.Errorf("CheckConfig() error = %v, want the duplicated eventKey position", err)
+	}
+}
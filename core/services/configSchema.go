@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigSchemaFile is the name of the published JSON Schema of the configuration, at the root of the module. It is
+// generated with the "schema" command (see GenerateConfigSchema)
+const ConfigSchemaFile = "config.schema.json"
+
+const configSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// configSchemaEnums are the accepted values of the enumerated types of the configuration
+var configSchemaEnums = map[reflect.Type][]string{
+	// The HTTP methods are case-insensitive, see checkConfigEndpoints
+	reflect.TypeOf(models.HttpMethodTypeGet): {
+		string(models.HttpMethodTypeGet), models.HttpMethodTypePost, models.HttpMethodTypeOptions, models.HttpMethodTypeHead,
+		models.HttpMethodTypePut, models.HttpMethodTypeDelete, models.HttpMethodTypeTrace, models.HttpMethodTypeConnect,
+		"get", "post", "options", "head", "put", "delete", "trace", "connect",
+	},
+	reflect.TypeOf(models.EventToSendTypeAll): {
+		string(models.EventToSendTypeAll), models.EventToSendTypeFirst, models.EventToSendTypeLast, models.EventToSendTypeBoundaries,
+	},
+	reflect.TypeOf(models.TriggerTypeWindow): {
+		string(models.TriggerTypeWindow), models.TriggerTypeNone,
+	},
+	reflect.TypeOf(models.VerificationTypeHmacSha256): {
+		string(models.VerificationTypeHmacSha256), models.VerificationTypeGithub, models.VerificationTypeStripe,
+	},
+	reflect.TypeOf(models.SignatureEncodingHex): {
+		string(models.SignatureEncodingHex), models.SignatureEncodingBase64,
+	},
+}
+
+// configSchemaRules are the additional JSON Schema keywords of the configuration values, by path, mirroring the
+// CheckConfig rules. The "[]" path element is a sequence item.
+var configSchemaRules = map[string]map[string]interface{}{
+	"":                              {"required": []string{"serviceName", "endpoints", "trigger", "targetPubSub"}},
+	"serviceName":                   {"minLength": 1},
+	"endpoints":                     {"minItems": 2},
+	"endpoints[]":                   {"required": []string{"eventKey"}},
+	"endpoints[].eventKey":          {"minLength": 1},
+	"endpoints[].minNbOfOccurrence": {"minimum": 0},
+	"trigger":                       {"required": []string{"type", "observationPeriod"}},
+	"trigger.observationPeriod":     {"minimum": 1},
+	"targetPubSub":                  {"required": []string{"topic"}},
+	"endpoints[].verification":      {"required": []string{"type"}},
+	"endpoints[].verification.toleranceSeconds": {"minimum": 0},
+	"targetPubSub.topic":                        {"pattern": "^projects/[^/]+/topics/[^/]+$"},
+	"auth.ingestion.googleIdToken.audiences":    {"minItems": 1},
+	"auth.ingestion.apiKeys[]":                  {"minLength": 1},
+	"auth.admin.googleIdToken.audiences":        {"minItems": 1},
+	"auth.admin.apiKeys[]":                      {"minLength": 1},
+	"auth.metrics.googleIdToken.audiences":      {"minItems": 1},
+	"auth.metrics.apiKeys[]":                    {"minLength": 1},
+	"cors":                                      {"required": []string{"allowedOrigins"}},
+	"cors.allowedOrigins":                       {"minItems": 1},
+	"cors.maxAgeSeconds":                        {"minimum": 0},
+	"limits.requestsPerSecond":                  {"minimum": 0},
+	"limits.burst":                              {"minimum": 0},
+	"limits.maxBodyBytes":                       {"minimum": 0},
+	"limits.maxConcurrentRequests":              {"minimum": 0},
+	"endpoints[].limits.requestsPerSecond":      {"minimum": 0},
+	"endpoints[].limits.burst":                  {"minimum": 0},
+	"endpoints[].limits.maxBodyBytes":           {"minimum": 0},
+	"endpoints[].limits.maxConcurrentRequests":  {"minimum": 0},
+}
+
+// GenerateConfigSchema generates the JSON Schema of the EventSyncConfig from the models JSON tags, the enumerated
+// types values and the CheckConfig rules. The schema validates both the JSON and the YAML configurations, before the
+// environment variables interpolation.
+func GenerateConfigSchema() (schema []byte, err error) {
+	usedRules := make(map[string]bool)
+	root := typeSchema(reflect.TypeOf(models.EventSyncConfig{}), "", usedRules)
+	root["$schema"] = configSchemaDraft
+	root["title"] = "EventSync configuration"
+
+	// Detect the rules not applied, after a model change
+	unused := make([]string, 0)
+	for path := range configSchemaRules {
+		if !usedRules[path] {
+			unused = append(unused, fmt.Sprintf("%q", path))
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return nil, errors.New(fmt.Sprintf("the schema rules %s don't match any configuration value", strings.Join(unused, ", ")))
+	}
+
+	schema, err = json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return
+	}
+	return append(schema, '\n'), nil
+}
+
+// typeSchema returns the JSON Schema of the type at the path
+func typeSchema(t reflect.Type, path string, usedRules map[string]bool) (schema map[string]interface{}) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	schema = make(map[string]interface{})
+	if values, ok := configSchemaEnums[t]; ok {
+		schema["type"] = "string"
+		schema["enum"] = values
+	} else {
+		switch t.Kind() {
+		case reflect.String:
+			schema["type"] = "string"
+		case reflect.Bool:
+			schema["type"] = "boolean"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			schema["type"] = "integer"
+		case reflect.Float32, reflect.Float64:
+			schema["type"] = "number"
+		case reflect.Slice, reflect.Array:
+			schema["type"] = "array"
+			schema["items"] = typeSchema(t.Elem(), path+"[]", usedRules)
+		case reflect.Map:
+			schema["type"] = "object"
+			schema["additionalProperties"] = typeSchema(t.Elem(), path+"[]", usedRules)
+		case reflect.Struct:
+			schema["type"] = "object"
+			properties := make(map[string]interface{})
+			addStructProperties(t, path, properties, usedRules)
+			schema["properties"] = properties
+			// Catch the typos in the field names, ignored by the parsing
+			schema["additionalProperties"] = false
+		}
+	}
+
+	if rules, ok := configSchemaRules[path]; ok {
+		usedRules[path] = true
+		for keyword, value := range rules {
+			schema[keyword] = value
+		}
+	}
+	return
+}
+
+// addStructProperties adds the schema of the JSON fields of the struct, including the embedded structs fields, to the
+// properties
+func addStructProperties(t reflect.Type, path string, properties map[string]interface{}, usedRules map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			addStructProperties(fieldType, path, properties, usedRules)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		properties[name] = typeSchema(field.Type, fieldPath, usedRules)
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateConfigSchema(t *testing.T) {
+	schema, err := GenerateConfigSchema()
+	if err != nil {
+		t.Fatalf("GenerateConfigSchema() error = %v", err)
+	}
+
+	published, err := os.ReadFile(filepath.Join("..", ConfigSchemaFile))
+	if err != nil {
+		t.Fatalf("impossible to read the published schema: %v", err)
+	}
+	if string(published) != string(schema) {
+		t.Errorf("the published %s is outdated, run \"go generate\" in the module root", ConfigSchemaFile)
+	}
+
+	root := map[string]interface{}{}
+	if err = json.Unmarshal(schema, &root); err != nil {
+		t.Fatalf("invalid JSON schema: %v", err)
+	}
+	properties := root["properties"].(map[string]interface{})
+	for _, name := range []string{"serviceName", "endpoints", "trigger", "targetPubSub"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("the property %q is missing in the schema", name)
+		}
+	}
+	trigger := properties["trigger"].(map[string]interface{})["properties"].(map[string]interface{})
+	wantEnum := []interface{}{"window", "none"}
+	if got := trigger["type"].(map[string]interface{})["enum"]; !reflect.DeepEqual(got, wantEnum) {
+		t.Errorf("trigger.type enum = %v, want %v", got, wantEnum)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Setenv("TEST_PROJECT", "project123")
+
+	if err := ValidateConfig(yamlConfig); err != nil {
+		t.Errorf("ValidateConfig() error = %v", err)
+	}
+	if err := ValidateConfig("serviceName: myTest"); err == nil {
+		t.Errorf("ValidateConfig() of an incomplete config must fail")
+	}
+	if err := ValidateConfig("serviceName: [myTest]"); err == nil {
+		t.Errorf("ValidateConfig() of an invalid config must fail")
+	}
+}
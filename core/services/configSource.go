@@ -0,0 +1,219 @@
+package services
+
+import (
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigSourceEnvVar is the environment variable of the configuration location. If it is not set, the configuration
+// is read in the ConfigEnvVar environment variable, without reload
+const ConfigSourceEnvVar = "CONFIG_SOURCE"
+
+// ConfigWatchIntervalEnvVar is the environment variable of the number of seconds between 2 checks of the
+// configuration source
+const ConfigWatchIntervalEnvVar = "CONFIG_WATCH_INTERVAL"
+
+// defaultConfigWatchInterval is the interval between 2 checks of the configuration source if not set
+const defaultConfigWatchInterval = 30 * time.Second
+
+const (
+	fileConfigSourcePrefix      = "file://"
+	gcsConfigSourcePrefix       = "gs://"
+	firestoreConfigSourcePrefix = "firestore://"
+)
+
+// ConfigSource is a location of the configuration which can be watched for changes
+type ConfigSource interface {
+	// Read returns the configuration content and its version. The version changes when the content changes
+	Read(ctx context.Context) (config string, version string, err error)
+	// String returns the location of the configuration
+	String() string
+	// Close releases the client of the source, if any
+	Close() error
+}
+
+// NewConfigSource creates the ConfigSource of the location. The accepted formats are
+//   - file://<path> or <path> for a local file
+//   - gs://<bucket>/<object> for a Cloud Storage object
+//   - firestore://<collection>/<document> for a Firestore document, in the current project
+func NewConfigSource(ctx context.Context, location string) (source ConfigSource, err error) {
+	switch {
+	case strings.HasPrefix(location, gcsConfigSourcePrefix):
+		bucket, object, found := strings.Cut(strings.TrimPrefix(location, gcsConfigSourcePrefix), "/")
+		if !found || bucket == "" || object == "" {
+			return nil, errors.New(fmt.Sprintf("the Cloud Storage config source must be in \"gs://<bucket>/<object>\" format, here %q", location))
+		}
+		client, err := storage.NewClient(ctx, utils.GetClientOptions()...)
+		if err != nil {
+			return nil, err
+		}
+		return &gcsConfigSource{client: client, object: client.Bucket(bucket).Object(object), location: location}, nil
+	case strings.HasPrefix(location, firestoreConfigSourcePrefix):
+		collection, document, found := strings.Cut(strings.TrimPrefix(location, firestoreConfigSourcePrefix), "/")
+		if !found || collection == "" || document == "" || strings.Contains(document, "/") {
+			return nil, errors.New(fmt.Sprintf("the Firestore config source must be in \"firestore://<collection>/<document>\" format, here %q", location))
+		}
+		client, err := firestore.NewClient(ctx, utils.GetFirestoreProjectID(), utils.GetFirestoreClientOptions()...)
+		if err != nil {
+			return nil, err
+		}
+		return &firestoreConfigSource{client: client, document: client.Collection(collection).Doc(document), location: location}, nil
+	default:
+		return &fileConfigSource{path: strings.TrimPrefix(location, fileConfigSourcePrefix)}, nil
+	}
+}
+
+// GetConfigWatchInterval returns the interval between 2 checks of the configuration source, set in the
+// ConfigWatchIntervalEnvVar environment variable
+func GetConfigWatchInterval() (interval time.Duration, err error) {
+	value := os.Getenv(ConfigWatchIntervalEnvVar)
+	if value == "" {
+		return defaultConfigWatchInterval, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0, errors.New(fmt.Sprintf("the %s value %q must be a strictly positive number of seconds", ConfigWatchIntervalEnvVar, value))
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// WatchConfig checks the source every interval until the context is done. When the version changes, the new
+// configuration is reloaded (see ReloadConfig). An invalid configuration is rejected and logged, the current one stays
+// active. The initialVersion is the version of the currently loaded configuration. The source is closed when the
+// context is done.
+func (c *ConfigService) WatchConfig(ctx context.Context, source ConfigSource, initialVersion string, interval time.Duration) {
+	currentVersion := initialVersion
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer func() {
+		if err := source.Close(); err != nil {
+			c.GetLogger().WarnContext(ctx, "impossible to close the configuration source", "source", source.String(), "error", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		config, version, err := source.Read(ctx)
+		if err != nil {
+			c.GetLogger().ErrorContext(ctx, "impossible to read the configuration source", "source", source.String(), "error", err)
+			continue
+		}
+		if version == currentVersion {
+			continue
+		}
+
+		// The version is kept even if rejected, to not log the same error at each check
+		currentVersion = version
+		c.GetLogger().InfoContext(ctx, "new configuration version detected", "source", source.String(), "version", version)
+		err = c.ReloadConfig(config)
+		if err != nil {
+			c.GetLogger().ErrorContext(ctx, "new configuration rejected, the previous one stays active", "version", version, "error", err)
+			continue
+		}
+		c.GetLogger().InfoContext(ctx, "new configuration version active", "version", version)
+	}
+}
+
+// contentVersion returns the SHA-256 hash of the content, used as version when the source has no native versioning
+func contentVersion(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// fileConfigSource reads the configuration in a local file
+type fileConfigSource struct {
+	path string
+}
+
+func (f *fileConfigSource) Read(ctx context.Context) (config string, version string, err error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return
+	}
+	return string(data), contentVersion(data), nil
+}
+
+func (f *fileConfigSource) String() string {
+	return fileConfigSourcePrefix + f.path
+}
+
+func (f *fileConfigSource) Close() error {
+	return nil
+}
+
+// gcsConfigSource reads the configuration in a Cloud Storage object. The object generation is the version
+type gcsConfigSource struct {
+	client   *storage.Client
+	object   *storage.ObjectHandle
+	location string
+}
+
+func (g *gcsConfigSource) Read(ctx context.Context) (config string, version string, err error) {
+	attrs, err := g.object.Attrs(ctx)
+	if err != nil {
+		return
+	}
+	// Read the generation checked above, even if a new one is uploaded in the meantime
+	reader, err := g.object.Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	return string(data), fmt.Sprintf("%d", attrs.Generation), nil
+}
+
+func (g *gcsConfigSource) String() string {
+	return g.location
+}
+
+func (g *gcsConfigSource) Close() error {
+	return g.client.Close()
+}
+
+// firestoreConfigSource reads the configuration in a Firestore document. The document fields are the configuration
+// fields, and the document update time is the version
+type firestoreConfigSource struct {
+	client   *firestore.Client
+	document *firestore.DocumentRef
+	location string
+}
+
+func (f *firestoreConfigSource) Read(ctx context.Context) (config string, version string, err error) {
+	doc, err := f.document.Get(ctx)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(doc.Data())
+	if err != nil {
+		return
+	}
+	return string(data), doc.UpdateTime.Format(time.RFC3339Nano), nil
+}
+
+func (f *firestoreConfigSource) String() string {
+	return f.location
+}
+
+func (f *firestoreConfigSource) Close() error {
+	return f.client.Close()
+}
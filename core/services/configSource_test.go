@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigService_WatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(observationPeriod int64) {
+		config := generateValidConfig()
+		config.Trigger.ObservationPeriod = observationPeriod
+		data, _ := json.Marshal(config)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("impossible to write the config file with error %s", err)
+		}
+	}
+	writeConfig(3600)
+
+	source, err := NewConfigSource(context.Background(), fileConfigSourcePrefix+path)
+	if err != nil {
+		t.Fatalf("NewConfigSource() error = %v", err)
+	}
+	config, version, err := source.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	c := &ConfigService{}
+	c.eventSyncConfig, _, _ = parseConfig(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.WatchConfig(ctx, source, version, 10*time.Millisecond)
+
+	waitObservationPeriod := func(want int64) {
+		deadline := time.Now().Add(2 * time.Second)
+		for c.GetConfig().Trigger.ObservationPeriod != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("WatchConfig() ObservationPeriod = %d, want %d", c.GetConfig().Trigger.ObservationPeriod, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A valid change is applied
+	writeConfig(60)
+	waitObservationPeriod(60)
+
+	// An invalid change is rejected, the previous config stays active
+	writeConfig(0)
+	time.Sleep(100 * time.Millisecond)
+	waitObservationPeriod(60)
+}
+
+func TestNewConfigSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		wantErr  bool
+	}{
+		{
+			name:     "ok file",
+			location: "/etc/eventsync/config.json",
+			wantErr:  false,
+		},
+		{
+			name:     "ko gcs without object",
+			location: "gs://bucket",
+			wantErr:  true,
+		},
+		{
+			name:     "ko firestore without document",
+			location: "firestore://collection",
+			wantErr:  true,
+		},
+		{
+			name:     "ko firestore sub collection",
+			location: "firestore://collection/document/sub/document",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewConfigSource(context.Background(), tt.location); (err != nil) != tt.wantErr {
+				t.Errorf("NewConfigSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package services
 
 import (
-	"eventsync/models"
+	"encoding/json"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"log/slog"
 	"reflect"
 	"testing"
 )
@@ -70,6 +73,17 @@ func TestConfigService_CheckConfig(t *testing.T) {
 			wantErr:    false,
 			wantConfig: generateValidConfig(),
 		},
+		{
+			name: "undefined environment variable verification secret",
+			fields: fields{
+				eventSyncConfig: func() *models.EventSyncConfig {
+					e := generateValidConfig()
+					e.Endpoints[0].Verification = &models.Verification{Type: models.VerificationTypeGithub, Secret: "${TEST_UNDEFINED}"}
+					return e
+				}(),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -506,3 +520,111 @@ func TestConfigService_checkConfigTrigger(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigService_ReloadConfig(t *testing.T) {
+	toJSON := func(config *models.EventSyncConfig) string {
+		data, _ := json.Marshal(config)
+		return string(data)
+	}
+	tests := []struct {
+		name            string
+		newConfig       string
+		wantErr         bool
+		wantObservation int64
+	}{
+		{
+			name: "ok",
+			newConfig: toJSON(func() *models.EventSyncConfig {
+				e := generateValidConfig()
+				e.Trigger.ObservationPeriod = 60
+				return e
+			}()),
+			wantErr:         false,
+			wantObservation: 60,
+		},
+		{
+			name:            "ko invalid JSON",
+			newConfig:       "{",
+			wantErr:         true,
+			wantObservation: 3600,
+		},
+		{
+			name: "ko invalid config",
+			newConfig: toJSON(func() *models.EventSyncConfig {
+				e := generateValidConfig()
+				e.Trigger.ObservationPeriod = 0
+				return e
+			}()),
+			wantErr:         true,
+			wantObservation: 3600,
+		},
+		{
+			name: "ko serviceName change",
+			newConfig: toJSON(func() *models.EventSyncConfig {
+				e := generateValidConfig()
+				e.ServiceName = "otherTest"
+				e.Trigger.ObservationPeriod = 60
+				return e
+			}()),
+			wantErr:         true,
+			wantObservation: 3600,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ConfigService{
+				eventSyncConfig: generateValidConfig(),
+			}
+			notified := false
+			c.OnConfigChange(func(previousConfig *models.EventSyncConfig, newConfig *models.EventSyncConfig) {
+				notified = previousConfig.Trigger.ObservationPeriod == 3600 && newConfig.Trigger.ObservationPeriod == 60
+			})
+			if err := c.ReloadConfig(tt.newConfig); (err != nil) != tt.wantErr {
+				t.Errorf("ReloadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if c.GetConfig().Trigger.ObservationPeriod != tt.wantObservation {
+				t.Errorf("ReloadConfig() ObservationPeriod = %d, want %d", c.GetConfig().Trigger.ObservationPeriod, tt.wantObservation)
+			}
+			if notified == tt.wantErr {
+				t.Errorf("ReloadConfig() listener notified = %v, wantErr %v", notified, tt.wantErr)
+			}
+		})
+	}
+}
+
+// stubRuntime is a runtime with a fixed default post event processing mode
+type stubRuntime struct {
+	utils.Runtime
+	defaultAsync bool
+}
+
+func (s stubRuntime) Name() string {
+	return "stub"
+}
+
+func (s stubRuntime) DefaultAsyncEventTrigger() bool {
+	return s.defaultAsync
+}
+
+func Test_isAsyncEventTriggerMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		defaultAsync bool
+		want         bool
+	}{
+		{name: "runtime default async", envValue: "", defaultAsync: true, want: true},
+		{name: "runtime default sync", envValue: "", defaultAsync: false, want: false},
+		{name: "forced async", envValue: "True", defaultAsync: false, want: true},
+		{name: "forced sync", envValue: "FALSE", defaultAsync: true, want: false},
+		{name: "invalid value uses the runtime default", envValue: "yes", defaultAsync: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ForceAsyncEventTriggerEnvVar, tt.envValue)
+			if got := isAsyncEventTriggerMode(stubRuntime{defaultAsync: tt.defaultAsync}, slog.Default()); got != tt.want {
+				t.Errorf("isAsyncEventTriggerMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
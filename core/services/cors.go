@@ -0,0 +1,93 @@
+package services
+
+import (
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DisableCorsEnvVar is the legacy environment variable to allow all the origins, when the configuration has no cors
+// section. Deprecated: use the cors configuration section
+const DisableCorsEnvVar = "DISABLE_CORS"
+
+// defaultCorsMethods are the accepted methods of the cross-origin requests if not configured
+var defaultCorsMethods = []models.HttpMethodType{models.HttpMethodTypeGet, models.HttpMethodTypeHead, models.HttpMethodTypePost}
+
+// defaultCorsHeaders are the accepted request headers of the cross-origin requests if not configured
+var defaultCorsHeaders = []string{"Content-Type", "Authorization", APIKeyHeader}
+
+// GetCorsPolicy returns the CORS policy of the configuration, or a policy allowing all the origins if the legacy
+// DISABLE_CORS environment variable is set. Nil if the cross-origin requests are not allowed.
+func (c *ConfigService) GetCorsPolicy() *models.Cors {
+	if cors := c.GetConfig().Cors; cors != nil {
+		return cors
+	}
+	if os.Getenv(DisableCorsEnvVar) != "" {
+		return &models.Cors{AllowedOrigins: []string{"*"}}
+	}
+	return nil
+}
+
+// GetCorsHeaders returns the CORS response headers for the request origin, empty if the origin is not allowed. The
+// preflight responses also get the accepted methods, headers and max age.
+func (c *ConfigService) GetCorsHeaders(origin string, preflight bool) http.Header {
+	headers := http.Header{}
+	cors := c.GetCorsPolicy()
+	if cors == nil || origin == "" || !IsCorsOriginAllowed(cors, origin) {
+		return headers
+	}
+
+	if containsString(cors.AllowedOrigins, "*") && !cors.AllowCredentials {
+		headers.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		headers.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cors.AllowCredentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if len(cors.ExposedHeaders) > 0 {
+			headers.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+		}
+		return headers
+	}
+
+	methods := cors.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCorsMethods
+	}
+	methodNames := make([]string, len(methods))
+	for i, method := range methods {
+		methodNames[i] = string(method)
+	}
+	headers.Set("Access-Control-Allow-Methods", strings.Join(methodNames, ", "))
+
+	allowedHeaders := cors.AllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = defaultCorsHeaders
+	}
+	headers.Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+
+	if cors.MaxAgeSeconds > 0 {
+		headers.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAgeSeconds))
+	}
+	return headers
+}
+
+// IsCorsOriginAllowed checks if the origin matches one of the allowed origins of the policy: "*", the exact origin or
+// a "https://*.example.com" subdomain wildcard
+func IsCorsOriginAllowed(cors *models.Cors, origin string) bool {
+	for _, allowedOrigin := range cors.AllowedOrigins {
+		if allowedOrigin == "*" || strings.EqualFold(allowedOrigin, origin) {
+			return true
+		}
+		scheme, domain, found := strings.Cut(allowedOrigin, "://*.")
+		if found && strings.HasPrefix(strings.ToLower(origin), strings.ToLower(scheme)+"://") &&
+			strings.HasSuffix(strings.ToLower(origin), "."+strings.ToLower(domain)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"testing"
+)
+
+func TestIsCorsOriginAllowed(t *testing.T) {
+	cors := &models.Cors{AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"}}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "https://app.example.com", want: true},
+		{origin: "https://APP.example.com", want: true},
+		{origin: "https://pr-12.preview.example.com", want: true},
+		{origin: "http://pr-12.preview.example.com", want: false},
+		{origin: "https://preview.example.com", want: false},
+		{origin: "https://evil-preview.example.com", want: false},
+		{origin: "https://app.example.com.evil.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := IsCorsOriginAllowed(cors, tt.origin); got != tt.want {
+				t.Errorf("IsCorsOriginAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigService_GetCorsHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		cors      *models.Cors
+		disable   string
+		origin    string
+		preflight bool
+		want      map[string]string
+	}{
+		{
+			name:   "no policy",
+			origin: "https://app.example.com",
+			want:   map[string]string{},
+		},
+		{
+			name:    "legacy DISABLE_CORS",
+			disable: "true",
+			origin:  "https://app.example.com",
+			want:    map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:   "origin not allowed",
+			cors:   &models.Cors{AllowedOrigins: []string{"https://app.example.com"}},
+			origin: "https://other.example.com",
+			want:   map[string]string{},
+		},
+		{
+			name:   "allowed origin with credentials",
+			cors:   &models.Cors{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true, ExposedHeaders: []string{"WWW-Authenticate"}},
+			origin: "https://app.example.com",
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "WWW-Authenticate",
+			},
+		},
+		{
+			name:      "preflight with defaults",
+			cors:      &models.Cors{AllowedOrigins: []string{"*"}},
+			origin:    "https://app.example.com",
+			preflight: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST",
+				"Access-Control-Allow-Headers": "Content-Type, Authorization, X-API-Key",
+			},
+		},
+		{
+			name:      "preflight",
+			cors:      &models.Cors{AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []models.HttpMethodType{models.HttpMethodTypePut}, AllowedHeaders: []string{"X-Custom"}, MaxAgeSeconds: 600},
+			origin:    "https://app.example.com",
+			preflight: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "PUT",
+				"Access-Control-Allow-Headers": "X-Custom",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(DisableCorsEnvVar, tt.disable)
+			config := generateValidConfig()
+			config.Cors = tt.cors
+			c := &ConfigService{eventSyncConfig: config}
+
+			got := c.GetCorsHeaders(tt.origin, tt.preflight)
+			if len(got) != len(tt.want) {
+				t.Errorf("GetCorsHeaders() = %v, want %v", got, tt.want)
+			}
+			for name, value := range tt.want {
+				if got.Get(name) != value {
+					t.Errorf("GetCorsHeaders() %s = %q, want %q", name, got.Get(name), value)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigService_checkConfigCors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cors    *models.Cors
+		wantErr bool
+	}{
+		{
+			name: "no cors",
+		},
+		{
+			name: "valid cors",
+			cors: &models.Cors{AllowedOrigins: []string{"https://app.example.com", "https://*.example.com", "http://localhost:3000"}, AllowedMethods: []models.HttpMethodType{"post"}, AllowCredentials: true},
+		},
+		{
+			name:    "no origin",
+			cors:    &models.Cors{},
+			wantErr: true,
+		},
+		{
+			name:    "origin with path",
+			cors:    &models.Cors{AllowedOrigins: []string{"https://app.example.com/index.html"}},
+			wantErr: true,
+		},
+		{
+			name:    "origin without scheme",
+			cors:    &models.Cors{AllowedOrigins: []string{"app.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "all origins with credentials",
+			cors:    &models.Cors{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			wantErr: true,
+		},
+		{
+			name:    "invalid method",
+			cors:    &models.Cors{AllowedOrigins: []string{"*"}, AllowedMethods: []models.HttpMethodType{"FETCH"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative max age",
+			cors:    &models.Cors{AllowedOrigins: []string{"*"}, MaxAgeSeconds: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := generateValidConfig()
+			config.Cors = tt.cors
+			c := &ConfigService{eventSyncConfig: config}
+			if got, _ := c.checkConfigCors("", ""); (got != "") != tt.wantErr {
+				t.Errorf("checkConfigCors() got = %v, wantErr %v", got, tt.wantErr)
+			}
+		})
+	}
+}
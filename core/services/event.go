@@ -1,124 +1,60 @@
 package services
 
 import (
-	"cloud.google.com/go/firestore"
-	apiAdmin "cloud.google.com/go/firestore/apiv1/admin"
-	"cloud.google.com/go/firestore/apiv1/admin/adminpb"
 	"context"
 	"errors"
-	"eventsync/models"
-	"eventsync/utils"
 	"fmt"
-	"google.golang.org/api/iterator"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
 	"io"
-	"log"
 	"strings"
 	"time"
 )
 
-// EventService handles the event related operation, based on Firestore for persistence layer and a reference to
+// EventService handles the event related operation, based on an EventStore for persistence layer and a reference to
 // the configuration service
 type EventService struct {
-	firestoreClient *firestore.Client
-	configService   *ConfigService
+	store         EventStore
+	configService *ConfigService
+	// metrics are the Prometheus metrics of the instance
+	metrics *Metrics
 }
 
 // EventPathPrefix is the prefix used by the HTTP handler to expose the path prefix to submit events on endpoints
 const EventPathPrefix = "/event/"
 
-// NewEventService creates the Event service. It requires a context to create a FirestoreClient
-// instance and to create/check the firestore index to be able to query correctly the firestore collection.
-// The configService is provided to store and keep the config in the service.
-func NewEventService(ctx context.Context, configService *ConfigService) (event *EventService, err error) {
-	event = &EventService{configService: configService}
+// ErrUnknownEndpoint is returned when the eventKey is not defined in the configuration
+var ErrUnknownEndpoint = errors.New("unknown endpoint")
 
-	projectID := utils.GetProjectId()
+// ErrMethodNotAccepted is returned when the HTTP method is not accepted by the endpoint
+var ErrMethodNotAccepted = errors.New("method not accepted")
 
-	event.firestoreClient, err = firestore.NewClient(ctx, projectID)
-	if err != nil {
-		fmt.Printf("firestore new client error:%s\n", err)
-		return
-	}
+// ErrEventNotFound is returned when no stored event exists for the requested eventKey and document ID
+var ErrEventNotFound = errors.New("event not found")
 
-	//To ensure the correct Firestore collection querying, an index must exist
-	err = checkAndCreateIndex(ctx, projectID, configService.GetConfig().ServiceName)
-	if err != nil {
-		return
-	}
-	return
+// NewEventService creates the Event service on the store (see NewFirestoreStore and NewMemoryStore). The
+// configService is provided to store and keep the config in the service.
+func NewEventService(configService *ConfigService, store EventStore) *EventService {
+	e := &EventService{store: store, configService: configService}
+	e.metrics = NewMetrics(e.countPendingEvents)
+	return e
 }
 
-// checkAndCreateIndex creates the used index in Firestore. If it already exists, nothing is performed.
-func checkAndCreateIndex(ctx context.Context, projectID string, configName string) (err error) {
-
-	// Create the Admin client
-	adminClient, err := apiAdmin.NewFirestoreAdminClient(ctx)
-	if err != nil {
-		fmt.Printf("firestore admin new Client error:%s\n", err)
-		return err
-	}
-
-	// Predefine the default order
-	ascendingFieldOrder := adminpb.Index_IndexField_Order_{
-		Order: adminpb.Index_IndexField_ASCENDING,
-	}
-
-	indexParent := fmt.Sprintf("projects/%s/databases/(default)/collectionGroups/%s", projectID, configName)
-
-	// create the indexes with corresponding fields
-	fields := []*adminpb.Index_IndexField{
-		{
-			FieldPath: "EventKey",
-			ValueMode: &ascendingFieldOrder,
-		},
-		{
-			FieldPath: "AlreadyExported",
-			ValueMode: &ascendingFieldOrder,
-		},
-		{
-			FieldPath: "Datetime",
-			ValueMode: &ascendingFieldOrder,
-		},
-	}
-	operation, err := adminClient.CreateIndex(ctx, &adminpb.CreateIndexRequest{
-		Parent: indexParent,
-		Index: &adminpb.Index{
-			QueryScope: adminpb.Index_COLLECTION,
-			Fields:     fields,
-		},
-	})
-
-	if err != nil && status.Convert(err).Code() == codes.AlreadyExists {
-		fmt.Printf("the index already exist. No need to recreate it, the service is fully ready to use\n")
-		return nil
-	}
-
-	if err != nil {
-		log.Fatalf("impossible to create the firestore index on the collection %s because of this error: %s\n", configName, err)
-	}
-
-	if operation != nil {
-		fmt.Printf("the index has just been created. You have to wait the end of the creation to be able to generate trigger. It can take a few minutes to complete\n")
-		return nil
-	}
-
-	return nil
+// Now returns the current time of the service clock (see ConfigService.SetClock)
+func (e *EventService) Now() time.Time {
+	return e.configService.GetClock().Now()
 }
 
 // FormatEvent takes the raw parts of an HTTP requests and create a models.Event object with those part, without
-// transformation.
-func FormatEvent(eventKey string, queryParam map[string][]string, headers map[string][]string, body io.ReadCloser, method string) (event models.Event, err error) {
+// transformation. The datetime is the reception date of the event (see EventService.Now).
+func FormatEvent(eventKey string, datetime time.Time, queryParam map[string][]string, headers map[string][]string, body io.ReadCloser, method string) (event models.Event, err error) {
 	event.EventKey = eventKey
-	event.Datetime = time.Now()
+	event.Datetime = datetime
 
 	event.QueryParams = queryParam
 	event.Headers = headers
 
 	b, err := io.ReadAll(body)
 	if err != nil {
-		fmt.Printf("impossible to read the body with err %s\n", err)
 		return
 	}
 	defer body.Close()
@@ -139,68 +75,179 @@ func ExtractEventKey(path string) (eventKey string) {
 	return splits[1]
 }
 
-// StoreEvent persists an event in Firestore. The collection name is the config serviceName value
-func (e *EventService) StoreEvent(ctx context.Context, event models.Event) (err error) {
-	_, _, err = e.firestoreClient.Collection(e.configService.GetConfig().ServiceName).Add(ctx, event)
+// StoreEvent persists an event in Firestore and returns the ID of the created document. The collection name is the
+// config serviceName value. The redaction rules are applied before the storage.
+func (e *EventService) StoreEvent(ctx context.Context, event models.Event) (documentID string, err error) {
+	collection := e.configService.GetConfig().ServiceName
+	ctx, span := startFirestoreSpan(ctx, "add", collection)
+	defer func() { endSpan(span, err) }()
+
+	event = e.redactEvent(event)
+	documentID, err = e.store.AddEvent(ctx, collection, event)
+	if err != nil {
+		e.metrics.IncEventsRejected(event.EventKey, RejectionStorageUnavailable)
+		return
+	}
+	e.metrics.IncEventsReceived(event.EventKey)
+	e.configService.GetLogger().InfoContext(ctx, "event stored", "eventKey", event.EventKey, "documentID", documentID)
+	return
+}
+
+// GetEvent retrieves the stored event of the eventKey by its Firestore document ID, and its exported state.
+// ErrEventNotFound is returned if the document doesn't exist or belongs to another eventKey.
+func (e *EventService) GetEvent(ctx context.Context, eventKey string, documentID string) (event models.Event, err error) {
+	// A document ID can't contain a path separator
+	if documentID == "" || strings.Contains(documentID, "/") {
+		return event, ErrEventNotFound
+	}
+	event, err = e.store.GetEvent(ctx, e.configService.GetConfig().ServiceName, documentID)
 	if err != nil {
 		return
 	}
-	fmt.Printf("event correct stored to Firestore collection %s\n", e.configService.GetConfig().ServiceName)
+	if event.EventKey != eventKey {
+		return models.Event{}, ErrEventNotFound
+	}
 	return
 }
 
-// GetEventsOverAPeriod retrieves the events stored in Firestore in the past observationPeriod. Only the not
-// alreadyExported event are taken into account. The events output groups the events per eventKeys.
+// GetEventsOverAPeriod retrieves the events stored in the past observationPeriod. Only the not alreadyExported event
+// are taken into account. The events output groups the events per eventKeys.
 func (e *EventService) GetEventsOverAPeriod(ctx context.Context, observationPeriod int64) (events map[string][]models.Event, err error) {
+	return e.getEventsOverAPeriodUntil(ctx, observationPeriod, e.Now())
+}
 
-	//Define globally the time of reference
-	targetDatetime := time.Now().Add(-time.Duration(observationPeriod) * time.Second)
-
-	// The base query select the events in the observation period and not already exported
-	query := e.firestoreClient.Collection(e.configService.GetConfig().ServiceName).Where("Datetime", ">", targetDatetime).Where("AlreadyExported", "==", false)
+// countPendingEvents returns the number of not exported events in the Trigger's observation period, per eventKey, for
+// the pending events metric. The events are counted by an aggregation query per eventKey, without being read. Nil is
+// returned if the events can't be counted.
+func (e *EventService) countPendingEvents(ctx context.Context) map[string]int {
+	endDate := e.Now()
+	startDate := endDate.Add(-time.Duration(e.configService.GetConfig().Trigger.ObservationPeriod) * time.Second)
+	exported := false
+	nbOfEvents := make(map[string]int, len(e.configService.GetConfig().Endpoints))
+	for _, endpoint := range e.configService.GetConfig().Endpoints {
+		count, err := e.store.CountEvents(ctx, e.configService.GetConfig().ServiceName, EventStoreFilter{
+			EventKeys: []string{endpoint.EventKey},
+			StartDate: &startDate,
+			EndDate:   &endDate,
+			Exported:  &exported,
+		})
+		if err != nil {
+			e.configService.GetLogger().WarnContext(ctx, "impossible to count the pending events of the metrics", "eventKey", endpoint.EventKey, "error", err)
+			return nil
+		}
+		nbOfEvents[endpoint.EventKey] = count
+	}
+	return nbOfEvents
+}
 
-	events = make(map[string][]models.Event, len(e.configService.GetConfig().Endpoints))
+// getEventsOverAPeriodUntil retrieves the not alreadyExported events stored in the observationPeriod before the
+// endDate, per eventKeys.
+func (e *EventService) getEventsOverAPeriodUntil(ctx context.Context, observationPeriod int64, endDate time.Time) (events map[string][]models.Event, err error) {
+	startDate := endDate.Add(-time.Duration(observationPeriod) * time.Second)
 
-	//Perform Query for all endpoints in th config
+	eventKeys := make([]string, 0, len(e.configService.GetConfig().Endpoints))
 	for _, endpoint := range e.configService.GetConfig().Endpoints {
-		// Specialize the query to request per eventKey
-		iter := query.Where("EventKey", "==", endpoint.EventKey).Documents(ctx)
-
-		// Initialize the  list of rawEvents of that eventKey
-		rawEvents := make([]models.Event, 0)
-		for {
-			var doc *firestore.DocumentSnapshot
-			doc, err = iter.Next()
-			if err == iterator.Done {
-				err = nil
-				break
-			}
-			if err != nil {
-				fmt.Printf("error during the document retrieval with error: %s\n", err)
-				return
-			}
-			// Count the number of rawEvents in the observation period
-			event := &models.Event{}
-			err = doc.DataTo(&event)
-			if err != nil {
-				fmt.Printf("error during the document conversion with error: %s\n", err)
-				return
-			}
-			// Keep the documentID for later use
-			event.FirestoreDocumentID = doc.Ref.ID
-			rawEvents = append(rawEvents, *event)
+		eventKeys = append(eventKeys, endpoint.EventKey)
+	}
+
+	return e.GetEventsOverARange(ctx, models.EventFilter{
+		StartDate: &startDate,
+		EndDate:   &endDate,
+		EventKeys: eventKeys,
+	})
+}
+
+// GetEventsOverARange retrieves the events stored between the filter StartDate (excluded) and EndDate
+// (included), for the filter eventKeys only. Only the not alreadyExported event are taken into account. The filter
+// must have been normalized before (see NormalizeEventFilter). The events output groups the events per eventKeys.
+func (e *EventService) GetEventsOverARange(ctx context.Context, filter models.EventFilter) (events map[string][]models.Event, err error) {
+	collection := e.configService.GetConfig().ServiceName
+	ctx, span := startFirestoreSpan(ctx, "events_over_range", collection)
+	defer func() { endSpan(span, err) }()
+
+	events = make(map[string][]models.Event, len(filter.EventKeys))
+	defer func(start time.Time) {
+		e.metrics.ObserveFirestoreQuery("events_over_range", time.Since(start))
+	}(time.Now())
+
+	//Perform Query for all requested eventKeys, not already exported
+	exported := false
+	for _, eventKey := range filter.EventKeys {
+		var rawEvents []models.Event
+		rawEvents, err = e.store.ListEvents(ctx, collection, EventStoreFilter{
+			EventKeys: []string{eventKey},
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+			Exported:  &exported,
+		})
+		if err != nil {
+			e.configService.GetLogger().ErrorContext(ctx, "error during the events retrieval", "eventKey", eventKey, "error", err)
+			return
 		}
-		events[endpoint.EventKey] = rawEvents
+		events[eventKey] = rawEvents
 	}
 	return
 }
 
+// NormalizeEventFilter sets the default values of the missing filter fields and validates the provided ones. The
+// EndDate is set to now, the StartDate to EndDate minus the Trigger's ObservationPeriod and the EventKeys to all the
+// configured endpoints. An error is returned if the range is empty or if an eventKey is not configured.
+func (e *EventService) NormalizeEventFilter(filter *models.EventFilter) (err error) {
+	if filter.EndDate == nil {
+		endDate := e.Now()
+		filter.EndDate = &endDate
+	}
+	if filter.StartDate == nil {
+		startDate := filter.EndDate.Add(-time.Duration(e.configService.GetConfig().Trigger.ObservationPeriod) * time.Second)
+		filter.StartDate = &startDate
+	}
+	if !filter.StartDate.Before(*filter.EndDate) {
+		return errors.New(fmt.Sprintf("the startDate %s must be before the endDate %s", filter.StartDate.Format(time.RFC3339), filter.EndDate.Format(time.RFC3339)))
+	}
+
+	if len(filter.EventKeys) == 0 {
+		for _, endpoint := range e.configService.GetConfig().Endpoints {
+			filter.EventKeys = append(filter.EventKeys, endpoint.EventKey)
+		}
+		return
+	}
+	for _, eventKey := range filter.EventKeys {
+		if e.getEndpoint(eventKey) == nil {
+			return errors.New(fmt.Sprintf("the eventKey %q is not defined in the configuration", eventKey))
+		}
+	}
+	return
+}
+
+// getEndpoint returns the configured endpoint of the eventKey, or nil if it does not exist
+func (e *EventService) getEndpoint(eventKey string) *models.Endpoint {
+	for _, endpoint := range e.configService.GetConfig().Endpoints {
+		if endpoint.EventKey == eventKey {
+			return endpoint
+		}
+	}
+	return nil
+}
+
+// Metrics returns the Prometheus metrics of the instance
+func (e *EventService) Metrics() *Metrics {
+	if e == nil {
+		return nil
+	}
+	return e.metrics
+}
+
+// HasEndpoint checks if the eventKey is defined in the configuration
+func (e *EventService) HasEndpoint(eventKey string) bool {
+	return e.getEndpoint(eventKey) != nil
+}
+
 // MeetTriggerConditions checks if the currently stored events meet the conditions to trigger a trigger. If so, the
 // needTrigger output is True and the events contains the events to put in the trigger
 func (e *EventService) MeetTriggerConditions(ctx context.Context) (events map[string][]models.Event, needTrigger bool, err error) {
 
 	if e.configService.GetConfig().Trigger.Type == models.TriggerTypeNone {
-		fmt.Println("TriggerType set to None. No automatic evaluation")
+		e.configService.GetLogger().DebugContext(ctx, "trigger type set to none. No automatic evaluation")
 		return nil, false, nil
 	}
 
@@ -217,16 +264,33 @@ func (e *EventService) MeetTriggerConditions(ctx context.Context) (events map[st
 // checkTriggerConditions uses a list of events and validate against the configuration the requirement to trigger a
 // trigger
 func (e *EventService) checkTriggerConditions(events map[string][]models.Event) (needTrigger bool) {
+	return e.checkEndpointsConditions(events, e.configService.GetConfig().Endpoints)
+}
 
-	for _, endpoint := range e.configService.GetConfig().Endpoints {
+// CheckTriggerConditions validates the list of events against the conditions of the endpoints of the eventKeys only.
+// It is used to respect the conditions on a subset of endpoints, for instance during a manual trigger.
+func (e *EventService) CheckTriggerConditions(events map[string][]models.Event, eventKeys []string) (needTrigger bool) {
+	endpoints := make([]*models.Endpoint, 0, len(eventKeys))
+	for _, eventKey := range eventKeys {
+		if endpoint := e.getEndpoint(eventKey); endpoint != nil {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return e.checkEndpointsConditions(events, endpoints)
+}
+
+// checkEndpointsConditions validates the list of events against the conditions of each endpoint in parameter
+func (e *EventService) checkEndpointsConditions(events map[string][]models.Event, endpoints []*models.Endpoint) (needTrigger bool) {
+
+	for _, endpoint := range endpoints {
 		numberOfEvents := len(events[endpoint.EventKey])
 		if _, ok := events[endpoint.EventKey]; !ok || numberOfEvents == 0 {
-			fmt.Printf("missing event entry for endpoint %s. Conditions are not met for a trigger\n", endpoint.EventKey)
+			e.configService.GetLogger().Debug("missing event entry for the endpoint. Conditions are not met for a trigger", "eventKey", endpoint.EventKey)
 			return false
 		}
 
 		if endpoint.MinNbOfOccurrence > numberOfEvents {
-			fmt.Printf("minimal number of event not satisfied for endpoint %s. Minimum is %d, got %d \n", endpoint.EventKey, endpoint.MinNbOfOccurrence, numberOfEvents)
+			e.configService.GetLogger().Debug("minimal number of events not satisfied for the endpoint. Conditions are not met for a trigger", "eventKey", endpoint.EventKey, "minNbOfOccurrence", endpoint.MinNbOfOccurrence, "nbOfEvents", numberOfEvents)
 			return false
 		}
 	}
@@ -241,11 +305,11 @@ func (e *EventService) MatchEndpoint(eventKeyValue string, method string) (err e
 			if contains(endpoint.AcceptedHttpMethods, models.HttpMethodType(method)) {
 				return nil
 			} else {
-				return errors.New(fmt.Sprintf("invalid method %q for endpoint %q\n", method, eventKeyValue))
+				return fmt.Errorf("%w: invalid method %q for endpoint %q", ErrMethodNotAccepted, method, eventKeyValue)
 			}
 		}
 	}
-	return errors.New(fmt.Sprintf("invalid endpoint %q\n", eventKeyValue))
+	return fmt.Errorf("%w: invalid endpoint %q", ErrUnknownEndpoint, eventKeyValue)
 }
 
 func contains(s []models.HttpMethodType, str models.HttpMethodType) bool {
@@ -259,26 +323,94 @@ func contains(s []models.HttpMethodType, str models.HttpMethodType) bool {
 }
 
 // ResetEvents updates the events provided in the events to set the parameter AlreadyExported to True. Like that
-// the event won't be retrieved during the future queries.
-func (e *EventService) ResetEvents(ctx context.Context, events map[string][]models.Event) {
+// the event won't be retrieved during the future queries. The number of events correctly updated per eventKey is
+// returned.
+func (e *EventService) ResetEvents(ctx context.Context, events map[string][]models.Event) (nbOfEventsReset map[string]int) {
 
-	fmt.Printf("set all the events has already exported to reset the context.\n")
+	ctx, span := startFirestoreSpan(ctx, "reset_events", e.configService.GetConfig().ServiceName)
+	defer span.End()
 
-	update := []firestore.Update{
-		{
-			Path:  "AlreadyExported",
-			Value: true,
-		},
-	}
+	e.configService.GetLogger().InfoContext(ctx, "set all the events as already exported to reset the context")
 
-	for _, eventGroup := range events {
+	exported := true
+	collection := e.configService.GetConfig().ServiceName
+
+	nbOfEventsReset = make(map[string]int, len(events))
+	for eventKey, eventGroup := range events {
+		nbOfEventsReset[eventKey] = 0
 		for _, event := range eventGroup {
-			_, err := e.firestoreClient.Collection(e.configService.GetConfig().ServiceName).Doc(event.FirestoreDocumentID).Update(ctx, update)
+			err := e.store.UpdateEvents(ctx, collection, []string{event.FirestoreDocumentID}, EventUpdate{AlreadyExported: &exported})
 			if err != nil {
-				fmt.Printf("impossible to update the state of the documentID %s, with error %s", event.FirestoreDocumentID, err)
+				e.configService.GetLogger().ErrorContext(ctx, "impossible to set the event as already exported", "eventKey", eventKey, "documentID", event.FirestoreDocumentID, "error", err)
+				continue
+			}
+			nbOfEventsReset[eventKey]++
+			e.configService.GetLogger().DebugContext(ctx, "event set as already exported", "eventKey", eventKey, "documentID", event.FirestoreDocumentID)
+		}
+	}
+	return
+}
+
+// GetSyncStatus evaluates, for each configured endpoint, the not exported events in the Trigger's observation period
+// against the endpoint conditions, and if an event sync message would be generated now.
+func (e *EventService) GetSyncStatus(ctx context.Context) (syncStatus models.SyncStatus, err error) {
+	events, err := e.GetEventsOverAPeriod(ctx, e.configService.GetConfig().Trigger.ObservationPeriod)
+	if err != nil {
+		return
+	}
+	return e.buildSyncStatus(events, e.Now()), nil
+}
+
+// GetSyncStatusAsOf evaluates the sync status as of the date, for the incident analysis: the not exported events in
+// the Trigger's observation period before the date, against the endpoint conditions. The events exported since the
+// date are not taken into account.
+func (e *EventService) GetSyncStatusAsOf(ctx context.Context, date time.Time) (syncStatus models.SyncStatus, err error) {
+	events, err := e.getEventsOverAPeriodUntil(ctx, e.configService.GetConfig().Trigger.ObservationPeriod, date)
+	if err != nil {
+		return
+	}
+	return e.buildSyncStatus(events, date), nil
+}
+
+// buildSyncStatus computes the SyncStatus of the events at the date now
+func (e *EventService) buildSyncStatus(events map[string][]models.Event, now time.Time) (syncStatus models.SyncStatus) {
+	config := e.configService.GetConfig()
+	syncStatus = models.SyncStatus{
+		Date:              now,
+		ServiceName:       config.ServiceName,
+		TriggerType:       config.Trigger.Type,
+		ObservationPeriod: config.Trigger.ObservationPeriod,
+		ConditionsMet:     true,
+		Endpoints:         make([]models.EndpointStatus, 0, len(config.Endpoints)),
+	}
+
+	for _, endpoint := range config.Endpoints {
+		endpointStatus := models.EndpointStatus{
+			EventKey:          endpoint.EventKey,
+			NumberOfEvents:    len(events[endpoint.EventKey]),
+			MinNbOfOccurrence: endpoint.MinNbOfOccurrence,
+			SignatureFailures: e.metrics.GetEventsRejected(endpoint.EventKey, RejectionInvalidSignature),
+		}
+		endpointStatus.Ready = endpointStatus.NumberOfEvents > 0 && endpointStatus.NumberOfEvents >= endpoint.MinNbOfOccurrence
+		syncStatus.ConditionsMet = syncStatus.ConditionsMet && endpointStatus.Ready
+
+		for _, event := range events[endpoint.EventKey] {
+			if endpointStatus.FirstEventDate == nil || event.Datetime.Before(*endpointStatus.FirstEventDate) {
+				d := event.Datetime
+				endpointStatus.FirstEventDate = &d
 			}
-			fmt.Printf("messageID %s set to already exported", event.FirestoreDocumentID)
+			if endpointStatus.LastEventDate == nil || event.Datetime.After(*endpointStatus.LastEventDate) {
+				d := event.Datetime
+				endpointStatus.LastEventDate = &d
+			}
+		}
+		if endpointStatus.LastEventDate != nil {
+			seconds := int64(now.Sub(*endpointStatus.LastEventDate).Seconds())
+			endpointStatus.SecondsSinceLastEvent = &seconds
 		}
+		syncStatus.Endpoints = append(syncStatus.Endpoints, endpointStatus)
 	}
 
+	syncStatus.WouldTrigger = syncStatus.ConditionsMet && config.Trigger.Type == models.TriggerTypeWindow
+	return
 }
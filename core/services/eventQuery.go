@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultPageSize is the number of events returned when no page size is provided
+const defaultPageSize = 50
+
+// maxPageSize is the maximal number of events returned in one page
+const maxPageSize = 500
+
+// maxScanPerPage is the maximal number of documents read to fill a page. The header and content filters are applied
+// in memory, the scan is stopped after that number of documents to keep the request latency under control.
+const maxScanPerPage = 5000
+
+// maxEventKeysPerQuery is the Firestore limit of values in a "in" filter
+const maxEventKeysPerQuery = 10
+
+// EventCursor is the position of the last read document. It is encoded in base64 JSON in the page token
+type EventCursor struct {
+	Datetime   time.Time `json:"d"`
+	DocumentID string    `json:"id"`
+}
+
+// QueryEvents retrieves the stored events that match the query, the oldest first. The time range, eventKeys and
+// exported filters are performed by the store, the headers and content filters in memory.
+func (e *EventService) QueryEvents(ctx context.Context, eventQuery models.EventQuery) (page models.EventPage, err error) {
+
+	err = e.checkEventQuery(&eventQuery)
+	if err != nil {
+		return
+	}
+
+	filter := EventStoreFilter{
+		EventKeys:        eventQuery.EventKeys,
+		StartDate:        eventQuery.StartDate,
+		IncludeStartDate: true,
+		EndDate:          eventQuery.EndDate,
+		Exported:         eventQuery.Exported,
+	}
+
+	var cursor *EventCursor
+	if eventQuery.PageToken != "" {
+		cursor, err = decodePageToken(eventQuery.PageToken)
+		if err != nil {
+			return
+		}
+	}
+
+	ctx, span := startFirestoreSpan(ctx, "query_events", e.configService.GetConfig().ServiceName)
+	defer func() { endSpan(span, err) }()
+	defer func(start time.Time) {
+		e.metrics.ObserveFirestoreQuery("query_events", time.Since(start))
+	}(time.Now())
+
+	page.Events = make([]models.EventItem, 0)
+	var last *EventCursor
+	scanned := 0
+	pageFull := false
+	err = e.store.ScanEvents(ctx, e.configService.GetConfig().ServiceName, filter, cursor, maxScanPerPage, func(event models.Event) bool {
+		if len(page.Events) == eventQuery.PageSize {
+			pageFull = true
+			return false
+		}
+		last = &EventCursor{Datetime: event.Datetime, DocumentID: event.FirestoreDocumentID}
+		scanned++
+		if matchEvent(event, eventQuery) {
+			page.Events = append(page.Events, models.EventItem{DocumentID: event.FirestoreDocumentID, Event: event})
+		}
+		return true
+	})
+	if err != nil {
+		e.configService.GetLogger().ErrorContext(ctx, "error during the events scan", "error", err)
+		return
+	}
+	if !pageFull && scanned < maxScanPerPage {
+		// All the documents have been read, no next page
+		return
+	}
+
+	page.NextPageToken, err = encodePageToken(last)
+	return
+}
+
+// checkEventQuery validates the query eventKeys and sets the default page size
+func (e *EventService) checkEventQuery(eventQuery *models.EventQuery) (err error) {
+	if len(eventQuery.EventKeys) > maxEventKeysPerQuery {
+		return errors.New(fmt.Sprintf("maximum %d eventKeys can be queried at the same time", maxEventKeysPerQuery))
+	}
+	for _, eventKey := range eventQuery.EventKeys {
+		if e.getEndpoint(eventKey) == nil {
+			return errors.New(fmt.Sprintf("the eventKey %q is not defined in the configuration", eventKey))
+		}
+	}
+	if eventQuery.StartDate != nil && eventQuery.EndDate != nil && eventQuery.EndDate.Before(*eventQuery.StartDate) {
+		return errors.New("the startDate must be before the endDate")
+	}
+	if eventQuery.PageSize <= 0 {
+		eventQuery.PageSize = defaultPageSize
+	}
+	if eventQuery.PageSize > maxPageSize {
+		eventQuery.PageSize = maxPageSize
+	}
+	return
+}
+
+// matchEvent checks if the event has all the headers and contains the content of the query
+func matchEvent(event models.Event, eventQuery models.EventQuery) bool {
+	for name, value := range eventQuery.Headers {
+		found := false
+		for _, headerValue := range event.Headers[http.CanonicalHeaderKey(name)] {
+			if headerValue == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if eventQuery.Content != "" {
+		if event.Content == nil || !strings.Contains(fmt.Sprint(event.Content), eventQuery.Content) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodePageToken encodes the cursor in a base64 JSON page token
+func encodePageToken(cursor *EventCursor) (pageToken string, err error) {
+	if cursor == nil {
+		return
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodePageToken decodes the base64 JSON page token in a cursor
+func decodePageToken(pageToken string) (cursor *EventCursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err == nil {
+		cursor = &EventCursor{}
+		err = json.Unmarshal(data, cursor)
+	}
+	if err != nil || cursor.DocumentID == "" {
+		return nil, errors.New("invalid page token")
+	}
+	return
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventService_matchEvent(t *testing.T) {
+	event := models.Event{
+		Headers: map[string][]string{
+			"X-Source": {"github", "gitlab"},
+		},
+		Content: "Hello, world!",
+	}
+	tests := []struct {
+		name       string
+		eventQuery models.EventQuery
+		want       bool
+	}{
+		{
+			name:       "ok no filter",
+			eventQuery: models.EventQuery{},
+			want:       true,
+		},
+		{
+			name: "ok header and content",
+			eventQuery: models.EventQuery{
+				Headers: map[string]string{"x-source": "gitlab"},
+				Content: "world",
+			},
+			want: true,
+		},
+		{
+			name: "ko header value",
+			eventQuery: models.EventQuery{
+				Headers: map[string]string{"X-Source": "bitbucket"},
+			},
+			want: false,
+		},
+		{
+			name: "ko missing header",
+			eventQuery: models.EventQuery{
+				Headers: map[string]string{"X-Other": "github"},
+			},
+			want: false,
+		},
+		{
+			name: "ko content",
+			eventQuery: models.EventQuery{
+				Content: "bye",
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchEvent(event, tt.eventQuery); got != tt.want {
+				t.Errorf("matchEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventService_checkEventQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		eventQuery   models.EventQuery
+		wantPageSize int
+		wantErr      bool
+	}{
+		{
+			name:         "ok default page size",
+			eventQuery:   models.EventQuery{},
+			wantPageSize: defaultPageSize,
+		},
+		{
+			name:         "ok max page size",
+			eventQuery:   models.EventQuery{PageSize: maxPageSize + 1},
+			wantPageSize: maxPageSize,
+		},
+		{
+			name: "ko unknown eventKey",
+			eventQuery: models.EventQuery{
+				EventFilter: models.EventFilter{EventKeys: []string{"entry1", "entry3"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ko endDate before startDate",
+			eventQuery: models.EventQuery{
+				EventFilter: models.EventFilter{StartDate: &after, EndDate: &before},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &EventService{
+				configService: &ConfigService{
+					eventSyncConfig: generateValidConfig(),
+				},
+			}
+			err := e.checkEventQuery(&tt.eventQuery)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkEventQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && tt.eventQuery.PageSize != tt.wantPageSize {
+				t.Errorf("checkEventQuery() PageSize = %d, want %d", tt.eventQuery.PageSize, tt.wantPageSize)
+			}
+		})
+	}
+}
+
+func TestEventService_pageToken(t *testing.T) {
+	cursor := &EventCursor{
+		Datetime:   time.Date(2022, 03, 28, 0, 0, 0, 42, time.UTC),
+		DocumentID: "id1",
+	}
+	pageToken, err := encodePageToken(cursor)
+	if err != nil {
+		t.Fatalf("encodePageToken() error = %v", err)
+	}
+	got, err := decodePageToken(pageToken)
+	if err != nil {
+		t.Fatalf("decodePageToken() error = %v", err)
+	}
+	if !got.Datetime.Equal(cursor.Datetime) || got.DocumentID != cursor.DocumentID {
+		t.Errorf("decodePageToken() = %+v, want %+v", got, cursor)
+	}
+
+	if _, err = decodePageToken("invalid"); err == nil {
+		t.Errorf("decodePageToken() with invalid token, want error")
+	}
+}
+
+func TestEventService_QueryEvents(t *testing.T) {
+	start := time.Date(2022, 03, 28, 10, 0, 0, 0, time.UTC)
+	store, documentIDs := addMemoryEvents(t, generateValidConfig().ServiceName, []models.Event{
+		{EventKey: "entry1", Datetime: start, Content: "first"},
+		{EventKey: "entry2", Datetime: start.Add(time.Minute), Content: "second"},
+		{EventKey: "entry1", Datetime: start.Add(2 * time.Minute), Content: "third"},
+	})
+	e := NewEventService(&ConfigService{eventSyncConfig: generateValidConfig()}, store)
+
+	tests := []struct {
+		name            string
+		eventQuery      models.EventQuery
+		wantDocumentIDs []string
+	}{
+		{name: "all the events", eventQuery: models.EventQuery{}, wantDocumentIDs: documentIDs},
+		{name: "content filter", eventQuery: models.EventQuery{Content: "ir"}, wantDocumentIDs: []string{documentIDs[0], documentIDs[2]}},
+		{name: "no event", eventQuery: models.EventQuery{Content: "none"}, wantDocumentIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := e.QueryEvents(context.Background(), tt.eventQuery)
+			if err != nil {
+				t.Fatalf("QueryEvents() error = %v", err)
+			}
+			gotDocumentIDs := make([]string, 0, len(page.Events))
+			for _, item := range page.Events {
+				gotDocumentIDs = append(gotDocumentIDs, item.DocumentID)
+			}
+			if !reflect.DeepEqual(gotDocumentIDs, tt.wantDocumentIDs) {
+				t.Errorf("QueryEvents() documentIDs = %v, want %v", gotDocumentIDs, tt.wantDocumentIDs)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package services
 
 import (
-	"eventsync/models"
+	"context"
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
 	"io"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEventService_ExtractEventKey(t *testing.T) {
@@ -74,6 +77,7 @@ func TestFormatEvent(t *testing.T) {
 
 	type args struct {
 		eventKey   string
+		datetime   time.Time
 		queryParam map[string][]string
 		headers    map[string][]string
 		body       io.ReadCloser
@@ -89,6 +93,7 @@ func TestFormatEvent(t *testing.T) {
 			name: "validate copy",
 			args: args{
 				eventKey:   "entry1",
+				datetime:   now,
 				queryParam: queryParam,
 				headers:    header,
 				body:       body,
@@ -97,6 +102,7 @@ func TestFormatEvent(t *testing.T) {
 			//Only validated field! Change the check test if more fields are required
 			wantEvent: models.Event{
 				EventKey:    "entry1",
+				Datetime:    now,
 				Headers:     header,
 				QueryParams: queryParam,
 				Content:     content,
@@ -107,7 +113,7 @@ func TestFormatEvent(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotEvent, err := FormatEvent(tt.args.eventKey, tt.args.queryParam, tt.args.headers, tt.args.body, tt.args.method)
+			gotEvent, err := FormatEvent(tt.args.eventKey, tt.args.datetime, tt.args.queryParam, tt.args.headers, tt.args.body, tt.args.method)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FormatEvent() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -115,6 +121,7 @@ func TestFormatEvent(t *testing.T) {
 			if !reflect.DeepEqual(gotEvent.Headers, tt.wantEvent.Headers) ||
 				!reflect.DeepEqual(gotEvent.QueryParams, tt.wantEvent.QueryParams) ||
 				gotEvent.EventKey != tt.wantEvent.EventKey ||
+				!gotEvent.Datetime.Equal(tt.wantEvent.Datetime) ||
 				gotEvent.Content != tt.wantEvent.Content {
 				t.Errorf("FormatEvent() gotEvent = %v, wantErr %v", gotEvent, tt.wantEvent)
 			}
@@ -131,10 +138,11 @@ func TestEventService_MatchEndpoint(t *testing.T) {
 		method        string
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name      string
+		fields    fields
+		args      args
+		wantErr   bool
+		wantErrIs error
 	}{
 		{
 			name: "ko empty",
@@ -160,7 +168,8 @@ func TestEventService_MatchEndpoint(t *testing.T) {
 				eventKeyValue: "entry3",
 				method:        "GET",
 			},
-			wantErr: true,
+			wantErr:   true,
+			wantErrIs: ErrUnknownEndpoint,
 		},
 		{
 			name: "ko nil endpoint (should never occur)",
@@ -203,7 +212,8 @@ func TestEventService_MatchEndpoint(t *testing.T) {
 				eventKeyValue: "entry1",
 				method:        "POST",
 			},
-			wantErr: true,
+			wantErr:   true,
+			wantErrIs: ErrMethodNotAccepted,
 		},
 		{
 			name: "ko lower case accepted method",
@@ -253,6 +263,9 @@ func TestEventService_MatchEndpoint(t *testing.T) {
 			if got := e.MatchEndpoint(tt.args.eventKeyValue, tt.args.method); (got != nil) != tt.wantErr {
 				t.Errorf("MatchEndpoint() = %v, wantErr %v", got, tt.wantErr)
 			}
+			if got := e.MatchEndpoint(tt.args.eventKeyValue, tt.args.method); tt.wantErrIs != nil && !errors.Is(got, tt.wantErrIs) {
+				t.Errorf("MatchEndpoint() = %v, want %v", got, tt.wantErrIs)
+			}
 		})
 	}
 }
@@ -377,3 +390,296 @@ func TestEventService_checkTriggerConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestEventService_NormalizeEventFilter(t *testing.T) {
+	endDate := time.Date(2022, 03, 28, 0, 0, 0, 0, time.UTC)
+	startDate := endDate.Add(-1 * time.Hour)
+	tooLateDate := endDate.Add(1 * time.Hour)
+
+	tests := []struct {
+		name          string
+		filter        models.EventFilter
+		wantStartDate *time.Time
+		wantEndDate   *time.Time
+		wantEventKeys []string
+		wantErr       bool
+	}{
+		{
+			name:          "ok defaults from the clock",
+			filter:        models.EventFilter{},
+			wantStartDate: &startDate,
+			wantEndDate:   &endDate,
+			wantEventKeys: []string{"entry1", "entry2"},
+			wantErr:       false,
+		},
+		{
+			name:          "ok defaults",
+			filter:        models.EventFilter{EndDate: &endDate},
+			wantStartDate: &startDate,
+			wantEndDate:   &endDate,
+			wantEventKeys: []string{"entry1", "entry2"},
+			wantErr:       false,
+		},
+		{
+			name: "ok subset of eventKeys",
+			filter: models.EventFilter{
+				StartDate: &startDate,
+				EndDate:   &endDate,
+				EventKeys: []string{"entry2"},
+			},
+			wantStartDate: &startDate,
+			wantEndDate:   &endDate,
+			wantEventKeys: []string{"entry2"},
+			wantErr:       false,
+		},
+		{
+			name: "ko unknown eventKey",
+			filter: models.EventFilter{
+				EndDate:   &endDate,
+				EventKeys: []string{"entry3"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ko startDate after endDate",
+			filter: models.EventFilter{
+				StartDate: &tooLateDate,
+				EndDate:   &endDate,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &EventService{
+				configService: &ConfigService{
+					eventSyncConfig: generateValidConfig(),
+					clock:           FixedClock(endDate),
+				},
+			}
+			err := e.NormalizeEventFilter(&tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NormalizeEventFilter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !tt.filter.StartDate.Equal(*tt.wantStartDate) || !tt.filter.EndDate.Equal(*tt.wantEndDate) ||
+				!reflect.DeepEqual(tt.filter.EventKeys, tt.wantEventKeys) {
+				t.Errorf("NormalizeEventFilter() filter = %+v, want %v %v %v", tt.filter, tt.wantStartDate, tt.wantEndDate, tt.wantEventKeys)
+			}
+		})
+	}
+}
+
+func TestEventService_CheckTriggerConditions(t *testing.T) {
+	tests := []struct {
+		name            string
+		events          map[string][]models.Event
+		eventKeys       []string
+		wantNeedTrigger bool
+	}{
+		{
+			name: "ok subset satisfied",
+			events: map[string][]models.Event{
+				"entry2": {
+					{},
+				},
+			},
+			eventKeys:       []string{"entry2"},
+			wantNeedTrigger: true,
+		},
+		{
+			name: "ko subset not satisfied",
+			events: map[string][]models.Event{
+				"entry1": {
+					{},
+				},
+				"entry2": {},
+			},
+			eventKeys:       []string{"entry2"},
+			wantNeedTrigger: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &EventService{
+				configService: &ConfigService{
+					eventSyncConfig: generateValidConfig(),
+				},
+			}
+			if gotNeedTrigger := e.CheckTriggerConditions(tt.events, tt.eventKeys); gotNeedTrigger != tt.wantNeedTrigger {
+				t.Errorf("CheckTriggerConditions() = %v, want %v", gotNeedTrigger, tt.wantNeedTrigger)
+			}
+		})
+	}
+}
+
+func TestEventService_buildSyncStatus(t *testing.T) {
+	now := time.Date(2022, 03, 28, 0, 0, 0, 0, time.UTC)
+	first := now.Add(-30 * time.Minute)
+	last := now.Add(-10 * time.Minute)
+	secondsSinceLast := int64(600)
+
+	tests := []struct {
+		name             string
+		config           *models.EventSyncConfig
+		events           map[string][]models.Event
+		wantConditions   bool
+		wantWouldTrigger bool
+		wantEntry2       models.EndpointStatus
+	}{
+		{
+			name:   "not ready",
+			config: generateValidConfig(),
+			events: map[string][]models.Event{
+				"entry2": {{Datetime: last}, {Datetime: first}},
+			},
+			wantConditions:   false,
+			wantWouldTrigger: false,
+			wantEntry2: models.EndpointStatus{
+				EventKey:              "entry2",
+				NumberOfEvents:        2,
+				MinNbOfOccurrence:     1,
+				Ready:                 true,
+				FirstEventDate:        &first,
+				LastEventDate:         &last,
+				SecondsSinceLastEvent: &secondsSinceLast,
+			},
+		},
+		{
+			name:   "ready",
+			config: generateValidConfig(),
+			events: map[string][]models.Event{
+				"entry1": {{Datetime: first}},
+				"entry2": {{Datetime: last}},
+			},
+			wantConditions:   true,
+			wantWouldTrigger: true,
+			wantEntry2: models.EndpointStatus{
+				EventKey:              "entry2",
+				NumberOfEvents:        1,
+				MinNbOfOccurrence:     1,
+				Ready:                 true,
+				FirstEventDate:        &last,
+				LastEventDate:         &last,
+				SecondsSinceLastEvent: &secondsSinceLast,
+			},
+		},
+		{
+			name: "ready but trigger type none",
+			config: func() *models.EventSyncConfig {
+				g := generateValidConfig()
+				g.Trigger.Type = models.TriggerTypeNone
+				return g
+			}(),
+			events: map[string][]models.Event{
+				"entry1": {{Datetime: first}},
+				"entry2": {{Datetime: last}},
+			},
+			wantConditions:   true,
+			wantWouldTrigger: false,
+			wantEntry2: models.EndpointStatus{
+				EventKey:              "entry2",
+				NumberOfEvents:        1,
+				MinNbOfOccurrence:     1,
+				Ready:                 true,
+				FirstEventDate:        &last,
+				LastEventDate:         &last,
+				SecondsSinceLastEvent: &secondsSinceLast,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &EventService{
+				configService: &ConfigService{
+					eventSyncConfig: tt.config,
+				},
+			}
+			got := e.buildSyncStatus(tt.events, now)
+			if got.ConditionsMet != tt.wantConditions || got.WouldTrigger != tt.wantWouldTrigger {
+				t.Errorf("buildSyncStatus() conditionsMet = %v, wouldTrigger = %v, want %v, %v", got.ConditionsMet, got.WouldTrigger, tt.wantConditions, tt.wantWouldTrigger)
+			}
+			if len(got.Endpoints) != 2 || !reflect.DeepEqual(got.Endpoints[1], tt.wantEntry2) {
+				t.Errorf("buildSyncStatus().Endpoints = %+v, want entry2 %+v", got.Endpoints, tt.wantEntry2)
+			}
+		})
+	}
+}
+
+func TestEventService_GetSyncStatusAsOf(t *testing.T) {
+	now := time.Date(2022, 03, 28, 0, 0, 0, 0, time.UTC)
+	oneHourBefore := now.Add(-1 * time.Hour)
+	twentyMinutesBefore := now.Add(-20 * time.Minute)
+	configService := &ConfigService{eventSyncConfig: generateValidConfig(), clock: FixedClock(now)}
+	store, _ := addMemoryEvents(t, generateValidConfig().ServiceName, []models.Event{
+		{EventKey: "entry1", Datetime: now.Add(-90 * time.Minute)},
+		{EventKey: "entry1", Datetime: now.Add(-30 * time.Minute)},
+		{EventKey: "entry2", Datetime: now.Add(-10 * time.Minute)},
+		{EventKey: "entry2", Datetime: now.Add(-5 * time.Minute), AlreadyExported: true},
+	})
+	e := NewEventService(configService, store)
+
+	tests := []struct {
+		name           string
+		asOf           *time.Time
+		wantDate       time.Time
+		wantEvents     []int
+		wantConditions bool
+	}{
+		{name: "now from the clock", wantDate: now, wantEvents: []int{1, 1}, wantConditions: true},
+		{name: "as of 1 hour before", asOf: &oneHourBefore, wantDate: oneHourBefore, wantEvents: []int{1, 0}, wantConditions: false},
+		{name: "as of 20 minutes before", asOf: &twentyMinutesBefore, wantDate: twentyMinutesBefore, wantEvents: []int{1, 0}, wantConditions: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.SyncStatus
+			var err error
+			if tt.asOf != nil {
+				got, err = e.GetSyncStatusAsOf(context.Background(), *tt.asOf)
+			} else {
+				got, err = e.GetSyncStatus(context.Background())
+			}
+			if err != nil {
+				t.Fatalf("GetSyncStatusAsOf() error = %v", err)
+			}
+			if !got.Date.Equal(tt.wantDate) || got.ConditionsMet != tt.wantConditions {
+				t.Errorf("GetSyncStatusAsOf() date = %v, conditionsMet = %v, want %v, %v", got.Date, got.ConditionsMet, tt.wantDate, tt.wantConditions)
+			}
+			for i, endpoint := range got.Endpoints {
+				if endpoint.NumberOfEvents != tt.wantEvents[i] {
+					t.Errorf("GetSyncStatusAsOf() %s numberOfEvents = %d, want %d", endpoint.EventKey, endpoint.NumberOfEvents, tt.wantEvents[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEventService_HasEndpoint(t *testing.T) {
+	e := &EventService{
+		configService: &ConfigService{eventSyncConfig: generateValidConfig()},
+	}
+	tests := []struct {
+		eventKey string
+		want     bool
+	}{
+		{eventKey: "entry1", want: true},
+		{eventKey: "entry2", want: true},
+		{eventKey: "entry1/abc", want: false},
+		{eventKey: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := e.HasEndpoint(tt.eventKey); got != tt.want {
+			t.Errorf("HasEndpoint(%q) = %v, want %v", tt.eventKey, got, tt.want)
+		}
+	}
+
+	// The invalid document IDs are rejected before any Firestore access
+	for _, documentID := range []string{"", "a/b"} {
+		if _, err := e.GetEvent(context.Background(), "entry1", documentID); err != ErrEventNotFound {
+			t.Errorf("GetEvent(%q) error = %v, want %v", documentID, err, ErrEventNotFound)
+		}
+	}
+}
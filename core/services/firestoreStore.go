@@ -0,0 +1,470 @@
+package services
+
+import (
+	"cloud.google.com/go/firestore"
+	apiAdmin "cloud.google.com/go/firestore/apiv1/admin"
+	"cloud.google.com/go/firestore/apiv1/admin/adminpb"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"os"
+)
+
+// firestoreStore is the EventStore on Firestore, one collection per store collection
+type firestoreStore struct {
+	client *firestore.Client
+	logger *slog.Logger
+}
+
+// NewFirestoreStore creates the Firestore EventStore of the projectID (see utils.GetFirestoreProjectID). It requires a
+// context to create the Firestore client and to create/check the indexes of the events and trigger history
+// collections of the serviceName, to be able to query them correctly. The emulator has no index management, all the
+// queries are accepted. The store logs with the logger.
+func NewFirestoreStore(ctx context.Context, projectID string, serviceName string, logger *slog.Logger) (store EventStore, err error) {
+	client, err := firestore.NewClient(ctx, projectID, utils.GetFirestoreClientOptions()...)
+	if err != nil {
+		logger.ErrorContext(ctx, "impossible to create the Firestore client", "error", err)
+		return
+	}
+
+	if utils.IsFirestoreEmulator() {
+		logger.InfoContext(ctx, "Firestore emulator used, the indexes are not managed", "emulatorHost", os.Getenv(utils.FirestoreEmulatorHostEnvVar), "projectID", projectID)
+		return &firestoreStore{client: client, logger: logger}, nil
+	}
+
+	//To ensure the correct Firestore collection querying, an index must exist
+	err = checkAndCreateIndex(ctx, logger, projectID, serviceName, []*adminpb.Index_IndexField{
+		{
+			FieldPath: "EventKey",
+			ValueMode: &ascendingFieldOrder,
+		},
+		{
+			FieldPath: "AlreadyExported",
+			ValueMode: &ascendingFieldOrder,
+		},
+		{
+			FieldPath: "Datetime",
+			ValueMode: &ascendingFieldOrder,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	//To query the events per eventKey or per exported state without the other filter, the partial indexes must exist
+	err = checkAndCreateIndex(ctx, logger, projectID, serviceName, []*adminpb.Index_IndexField{
+		{
+			FieldPath: "EventKey",
+			ValueMode: &ascendingFieldOrder,
+		},
+		{
+			FieldPath: "Datetime",
+			ValueMode: &ascendingFieldOrder,
+		},
+	})
+	if err != nil {
+		return
+	}
+	err = checkAndCreateIndex(ctx, logger, projectID, serviceName, []*adminpb.Index_IndexField{
+		{
+			FieldPath: "AlreadyExported",
+			ValueMode: &ascendingFieldOrder,
+		},
+		{
+			FieldPath: "Datetime",
+			ValueMode: &ascendingFieldOrder,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	//To list the history per status, an index must exist
+	err = checkAndCreateIndex(ctx, logger, projectID, serviceName+HistoryCollectionSuffix, []*adminpb.Index_IndexField{
+		{
+			FieldPath: "Status",
+			ValueMode: &ascendingFieldOrder,
+		},
+		{
+			FieldPath: "Date",
+			ValueMode: &descendingFieldOrder,
+		},
+	})
+	if err != nil {
+		return
+	}
+	return &firestoreStore{client: client, logger: logger}, nil
+}
+
+var (
+	// ascendingFieldOrder is the ascending order of an index field
+	ascendingFieldOrder = adminpb.Index_IndexField_Order_{
+		Order: adminpb.Index_IndexField_ASCENDING,
+	}
+	// descendingFieldOrder is the descending order of an index field
+	descendingFieldOrder = adminpb.Index_IndexField_Order_{
+		Order: adminpb.Index_IndexField_DESCENDING,
+	}
+)
+
+// checkAndCreateIndex creates the index with the fields on the collectionName in Firestore. If it already exists,
+// nothing is performed. An error is returned if the index can't be created.
+func checkAndCreateIndex(ctx context.Context, logger *slog.Logger, projectID string, collectionName string, fields []*adminpb.Index_IndexField) (err error) {
+
+	// Create the Admin client
+	adminClient, err := apiAdmin.NewFirestoreAdminClient(ctx, utils.GetFirestoreClientOptions()...)
+	if err != nil {
+		logger.ErrorContext(ctx, "impossible to create the Firestore admin client", "error", err)
+		return err
+	}
+	defer adminClient.Close()
+
+	indexParent := fmt.Sprintf("projects/%s/databases/(default)/collectionGroups/%s", projectID, collectionName)
+
+	// create the indexes with corresponding fields
+	operation, err := adminClient.CreateIndex(ctx, &adminpb.CreateIndexRequest{
+		Parent: indexParent,
+		Index: &adminpb.Index{
+			QueryScope: adminpb.Index_COLLECTION,
+			Fields:     fields,
+		},
+	})
+
+	if err != nil && status.Convert(err).Code() == codes.AlreadyExists {
+		logger.InfoContext(ctx, "the index on the collection already exists. No need to recreate it, the service is fully ready to use", "collection", collectionName)
+		return nil
+	}
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("impossible to create the Firestore index on the collection %q: %s", collectionName, err))
+	}
+
+	if operation != nil {
+		logger.WarnContext(ctx, "the index has just been created. You have to wait the end of the creation to be able to generate trigger. It can take a few minutes to complete", "collection", collectionName)
+		return nil
+	}
+
+	return nil
+}
+
+// AddEvent stores the event in a new document of the collection
+func (f *firestoreStore) AddEvent(ctx context.Context, collection string, event models.Event) (documentID string, err error) {
+	docRef, _, err := f.client.Collection(collection).Add(ctx, event)
+	if err != nil {
+		return
+	}
+	return docRef.ID, nil
+}
+
+// GetEvent reads the event document of the collection
+func (f *firestoreStore) GetEvent(ctx context.Context, collection string, documentID string) (event models.Event, err error) {
+	doc, err := f.client.Collection(collection).Doc(documentID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return event, ErrEventNotFound
+	}
+	if err != nil {
+		f.logger.ErrorContext(ctx, "impossible to get the event", "documentID", documentID, "error", err)
+		return
+	}
+
+	err = doc.DataTo(&event)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "error during the document conversion", "documentID", documentID, "error", err)
+		return
+	}
+	event.FirestoreDocumentID = doc.Ref.ID
+	return
+}
+
+// eventQuery returns the Firestore query of the filter on the collection
+func (f *firestoreStore) eventQuery(collection string, filter EventStoreFilter) firestore.Query {
+	query := f.client.Collection(collection).Query
+	if len(filter.EventKeys) == 1 {
+		query = query.Where("EventKey", "==", filter.EventKeys[0])
+	} else if len(filter.EventKeys) > 1 {
+		query = query.Where("EventKey", "in", filter.EventKeys)
+	}
+	if filter.Exported != nil {
+		query = query.Where("AlreadyExported", "==", *filter.Exported)
+	}
+	if filter.StartDate != nil && filter.IncludeStartDate {
+		query = query.Where("Datetime", ">=", *filter.StartDate)
+	} else if filter.StartDate != nil {
+		query = query.Where("Datetime", ">", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		query = query.Where("Datetime", "<=", *filter.EndDate)
+	}
+	return query
+}
+
+// ListEvents reads the event documents of the filter query
+func (f *firestoreStore) ListEvents(ctx context.Context, collection string, filter EventStoreFilter) (events []models.Event, err error) {
+	iter := f.eventQuery(collection, filter).Documents(ctx)
+	defer iter.Stop()
+
+	events = make([]models.Event, 0)
+	for {
+		var doc *firestore.DocumentSnapshot
+		doc, err = iter.Next()
+		if err == iterator.Done {
+			return events, nil
+		}
+		if err != nil {
+			f.logger.ErrorContext(ctx, "error during the document retrieval", "error", err)
+			return
+		}
+		event := models.Event{}
+		err = doc.DataTo(&event)
+		if err != nil {
+			f.logger.ErrorContext(ctx, "error during the document conversion", "documentID", doc.Ref.ID, "error", err)
+			return
+		}
+		// Keep the documentID for later use
+		event.FirestoreDocumentID = doc.Ref.ID
+		events = append(events, event)
+	}
+}
+
+// countAlias is the alias of the count aggregation result
+const countAlias = "count"
+
+// CountEvents runs a count aggregation of the filter query: no document is read, the count is billed per batch of
+// index entries
+func (f *firestoreStore) CountEvents(ctx context.Context, collection string, filter EventStoreFilter) (count int, err error) {
+	query := f.eventQuery(collection, filter)
+	result, err := query.NewAggregationQuery().WithCount(countAlias).Get(ctx)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "error during the documents count", "error", err)
+		return
+	}
+	value, ok := result[countAlias].(*firestorepb.Value)
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("invalid count aggregation result %v", result[countAlias]))
+	}
+	return int(value.GetIntegerValue()), nil
+}
+
+// ListEventIDs reads only the document references of the filter query, no field is read
+func (f *firestoreStore) ListEventIDs(ctx context.Context, collection string, filter EventStoreFilter) (documentIDs []string, err error) {
+	iter := f.eventQuery(collection, filter).Select().Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		var doc *firestore.DocumentSnapshot
+		doc, err = iter.Next()
+		if err == iterator.Done {
+			return documentIDs, nil
+		}
+		if err != nil {
+			f.logger.ErrorContext(ctx, "error during the document retrieval", "error", err)
+			return
+		}
+		documentIDs = append(documentIDs, doc.Ref.ID)
+	}
+}
+
+// ScanEvents reads the event documents of the filter query ordered by date and document ID
+func (f *firestoreStore) ScanEvents(ctx context.Context, collection string, filter EventStoreFilter, after *EventCursor, limit int, scan func(event models.Event) bool) (err error) {
+	query := f.eventQuery(collection, filter).OrderBy("Datetime", firestore.Asc).OrderBy(firestore.DocumentID, firestore.Asc)
+	if after != nil {
+		query = query.StartAfter(after.Datetime, after.DocumentID)
+	}
+	iter := query.Limit(limit).Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		var doc *firestore.DocumentSnapshot
+		doc, err = iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			f.logger.ErrorContext(ctx, "error during the document retrieval", "error", err)
+			return
+		}
+		event := models.Event{}
+		err = doc.DataTo(&event)
+		if err != nil {
+			f.logger.ErrorContext(ctx, "error during the document conversion", "documentID", doc.Ref.ID, "error", err)
+			return
+		}
+		event.FirestoreDocumentID = doc.Ref.ID
+		if !scan(event) {
+			return nil
+		}
+	}
+}
+
+// GetExistingEventIDs reads all the documents at once and groups the existing ones by eventKey
+func (f *firestoreStore) GetExistingEventIDs(ctx context.Context, collection string, documentIDs []string) (existing map[string][]string, notFound []string, err error) {
+	requestedRefs := make([]*firestore.DocumentRef, 0, len(documentIDs))
+	for _, documentID := range documentIDs {
+		requestedRefs = append(requestedRefs, f.client.Collection(collection).Doc(documentID))
+	}
+
+	docs, err := f.client.GetAll(ctx, requestedRefs)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "error during the documents retrieval", "error", err)
+		return
+	}
+	existing = make(map[string][]string)
+	for _, doc := range docs {
+		if doc.Exists() {
+			var event models.Event
+			if err = doc.DataTo(&event); err != nil {
+				f.logger.ErrorContext(ctx, "impossible to read the document", "documentID", doc.Ref.ID, "error", err)
+				return
+			}
+			existing[event.EventKey] = append(existing[event.EventKey], doc.Ref.ID)
+		} else {
+			notFound = append(notFound, doc.Ref.ID)
+		}
+	}
+	return
+}
+
+// UpdateEvents commits the update of all the documents in one batch. The documents must exist
+func (f *firestoreStore) UpdateEvents(ctx context.Context, collection string, documentIDs []string, update EventUpdate) (err error) {
+	updates := make([]firestore.Update, 0, 2)
+	if update.AlreadyExported != nil {
+		updates = append(updates, firestore.Update{Path: "AlreadyExported", Value: *update.AlreadyExported})
+	}
+	if update.EventKey != "" {
+		updates = append(updates, firestore.Update{Path: "EventKey", Value: update.EventKey})
+	}
+
+	batch := f.client.Batch()
+	for _, documentID := range documentIDs {
+		docRef := f.client.Collection(collection).Doc(documentID)
+		if update.Delete {
+			batch.Delete(docRef)
+		} else {
+			batch.Update(docRef, updates)
+		}
+	}
+	_, err = batch.Commit(ctx)
+	return
+}
+
+// AddAuditRecord stores the audit record in a new document of the collection
+func (f *firestoreStore) AddAuditRecord(ctx context.Context, collection string, auditRecord models.AuditRecord) (err error) {
+	_, _, err = f.client.Collection(collection).Add(ctx, auditRecord)
+	return
+}
+
+// CreateHistory creates the trigger history document, with the EventID as document ID
+func (f *firestoreStore) CreateHistory(ctx context.Context, collection string, history models.TriggerHistory) (err error) {
+	_, err = f.client.Collection(collection).Doc(history.EventID).Create(ctx, history)
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrHistoryAlreadyExists
+	}
+	return
+}
+
+// AddHistoryDelivery adds the delivery to the Deliveries array of the trigger history document
+func (f *firestoreStore) AddHistoryDelivery(ctx context.Context, collection string, eventID string, delivery models.Delivery) (err error) {
+	_, err = f.client.Collection(collection).Doc(eventID).Update(ctx, []firestore.Update{
+		{
+			Path:  "Deliveries",
+			Value: firestore.ArrayUnion(delivery),
+		},
+		{
+			Path:  "Status",
+			Value: delivery.Status,
+		},
+	})
+	if status.Code(err) == codes.NotFound {
+		return ErrHistoryNotFound
+	}
+	return
+}
+
+// GetHistory reads the trigger history document of the eventID
+func (f *firestoreStore) GetHistory(ctx context.Context, collection string, eventID string) (history *models.TriggerHistory, err error) {
+	doc, err := f.client.Collection(collection).Doc(eventID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrHistoryNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	history = &models.TriggerHistory{}
+	err = doc.DataTo(history)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "error during the document conversion", "eventID", eventID, "error", err)
+		return nil, err
+	}
+	return
+}
+
+// ListHistoriesOfEvent queries the trigger history documents which contain the document ID in their DocumentIDs
+func (f *firestoreStore) ListHistoriesOfEvent(ctx context.Context, collection string, documentID string) (histories []models.TriggerHistory, err error) {
+	// No ordering in the query, to not require a composite index with the array field
+	iter := f.client.Collection(collection).
+		Where("DocumentIDs", "array-contains", documentID).
+		Select("EventID", "Date").
+		Documents(ctx)
+	defer iter.Stop()
+
+	histories = make([]models.TriggerHistory, 0)
+	for {
+		var doc *firestore.DocumentSnapshot
+		doc, err = iter.Next()
+		if err == iterator.Done {
+			return histories, nil
+		}
+		if err != nil {
+			return
+		}
+		history := models.TriggerHistory{}
+		if err = doc.DataTo(&history); err != nil {
+			f.logger.ErrorContext(ctx, "error during the document conversion", "documentID", documentID, "error", err)
+			return
+		}
+		histories = append(histories, history)
+	}
+}
+
+// ListHistory queries the trigger history documents of the filter, ordered by descending date
+func (f *firestoreStore) ListHistory(ctx context.Context, collection string, filter models.HistoryFilter, limit int) (histories []models.TriggerHistory, err error) {
+	query := f.client.Collection(collection).Query
+	if filter.Status != "" {
+		query = query.Where("Status", "==", filter.Status)
+	}
+	if filter.StartDate != nil {
+		query = query.Where("Date", ">=", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		query = query.Where("Date", "<=", *filter.EndDate)
+	}
+	iter := query.OrderBy("Date", firestore.Desc).Limit(limit).Documents(ctx)
+	defer iter.Stop()
+
+	histories = make([]models.TriggerHistory, 0)
+	for {
+		var doc *firestore.DocumentSnapshot
+		doc, err = iter.Next()
+		if err == iterator.Done {
+			return histories, nil
+		}
+		if err != nil {
+			return
+		}
+		history := models.TriggerHistory{}
+		err = doc.DataTo(&history)
+		if err != nil {
+			f.logger.ErrorContext(ctx, "error during the document conversion", "eventID", doc.Ref.ID, "error", err)
+			return
+		}
+		histories = append(histories, history)
+	}
+}
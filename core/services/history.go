@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"sort"
+	"time"
+)
+
+// HistoryCollectionSuffix is the suffix added to the config serviceName value to name the collection of the
+// trigger history
+const HistoryCollectionSuffix = "-history"
+
+// defaultHistoryLimit is the number of history records returned when no limit is provided
+const defaultHistoryLimit = 50
+
+// maxHistoryLimit is the maximal number of history records returned in one request
+const maxHistoryLimit = 1000
+
+// ErrHistoryNotFound is returned when no trigger history record exists for the requested EventID
+var ErrHistoryNotFound = errors.New("trigger history not found")
+
+// ErrHistoryUnavailable is returned when the TriggerService has no HistoryService to read the trigger history
+var ErrHistoryUnavailable = errors.New("trigger history unavailable")
+
+// HistoryService persists and retrieves the trigger history, i.e. every generated event sync message with its
+// deliveries. It reuses the store of the EventService.
+type HistoryService struct {
+	store         EventStore
+	configService *ConfigService
+}
+
+// NewHistoryService creates the History service. The index to list the history per status is created with the store
+// (see NewFirestoreStore).
+func NewHistoryService(configService *ConfigService, eventService *EventService) *HistoryService {
+	return &HistoryService{
+		store:         eventService.store,
+		configService: configService,
+	}
+}
+
+// collectionName returns the name of the collection of the trigger history
+func (h *HistoryService) collectionName() string {
+	return h.configService.GetConfig().ServiceName + HistoryCollectionSuffix
+}
+
+// RecordTrigger persists the generated event sync message with the contributing events and the delivery outcome. If
+// a record already exists with the same EventID (the same events have been triggered again), the delivery is added to
+// the existing record. The events of the message are not copied in the record, only their document IDs: the record
+// size doesn't depend on the event contents (see GetEventGenerated).
+func (h *HistoryService) RecordTrigger(ctx context.Context, eventGenerated models.EventGenerated, events map[string][]models.Event, delivery models.Delivery) (err error) {
+
+	documentIDs := make([]string, 0)
+	for _, eventGroup := range events {
+		for _, event := range eventGroup {
+			documentIDs = append(documentIDs, event.FirestoreDocumentID)
+		}
+	}
+
+	// The event lists are copied to not change the published message
+	recordedEvents := make(map[string]*models.EventList, len(eventGenerated.Events))
+	eventDocumentIDs := make(map[string][]string, len(eventGenerated.Events))
+	for eventKey, eventList := range eventGenerated.Events {
+		recordedEventList := *eventList
+		recordedEventList.Events = nil
+		recordedEvents[eventKey] = &recordedEventList
+		eventDocumentIDs[eventKey] = make([]string, 0, len(eventList.Events))
+		for _, event := range eventList.Events {
+			eventDocumentIDs[eventKey] = append(eventDocumentIDs[eventKey], event.FirestoreDocumentID)
+		}
+	}
+	recordedEventGenerated := eventGenerated
+	recordedEventGenerated.Events = recordedEvents
+
+	history := models.TriggerHistory{
+		EventID:          eventGenerated.EventID,
+		Date:             eventGenerated.Date,
+		Status:           delivery.Status,
+		DocumentIDs:      documentIDs,
+		Deliveries:       []models.Delivery{delivery},
+		EventGenerated:   recordedEventGenerated,
+		EventDocumentIDs: eventDocumentIDs,
+	}
+
+	err = h.store.CreateHistory(ctx, h.collectionName(), history)
+	if errors.Is(err, ErrHistoryAlreadyExists) {
+		return h.AddDelivery(ctx, history.EventID, delivery)
+	}
+	if err != nil {
+		h.configService.GetLogger().ErrorContext(ctx, "impossible to store the trigger history", "eventID", history.EventID, "error", err)
+		return
+	}
+	h.configService.GetLogger().InfoContext(ctx, "trigger history stored", "eventID", history.EventID, "collection", h.collectionName())
+	return
+}
+
+// AddDelivery appends a delivery to the trigger history record of the eventID and updates its status
+func (h *HistoryService) AddDelivery(ctx context.Context, eventID string, delivery models.Delivery) (err error) {
+	err = h.store.AddHistoryDelivery(ctx, h.collectionName(), eventID, delivery)
+	if err != nil {
+		h.configService.GetLogger().ErrorContext(ctx, "impossible to add the delivery to the trigger history", "eventID", eventID, "error", err)
+	}
+	return
+}
+
+// GetHistory retrieves the trigger history record of the eventID. ErrHistoryNotFound is returned if it does not exist.
+func (h *HistoryService) GetHistory(ctx context.Context, eventID string) (history *models.TriggerHistory, err error) {
+	history, err = h.store.GetHistory(ctx, h.collectionName(), eventID)
+	if err != nil && !errors.Is(err, ErrHistoryNotFound) {
+		h.configService.GetLogger().ErrorContext(ctx, "impossible to get the trigger history", "eventID", eventID, "error", err)
+	}
+	return
+}
+
+// GetEventGenerated rebuilds the event sync message of the trigger history record with the stored events of its
+// EventDocumentIDs. The records without EventDocumentIDs contain the whole message. An error is returned if an event of
+// the message doesn't exist anymore.
+func (h *HistoryService) GetEventGenerated(ctx context.Context, history *models.TriggerHistory) (eventGenerated models.EventGenerated, err error) {
+	eventGenerated = history.EventGenerated
+	if history.EventDocumentIDs == nil {
+		return
+	}
+
+	collection := h.configService.GetConfig().ServiceName
+	eventGenerated.Events = make(map[string]*models.EventList, len(history.EventGenerated.Events))
+	for eventKey, recordedEventList := range history.EventGenerated.Events {
+		eventList := *recordedEventList
+		for _, documentID := range history.EventDocumentIDs[eventKey] {
+			var event models.Event
+			event, err = h.store.GetEvent(ctx, collection, documentID)
+			if err != nil {
+				h.configService.GetLogger().ErrorContext(ctx, "impossible to get an event of the trigger history", "eventID", history.EventID, "documentID", documentID, "error", err)
+				return models.EventGenerated{}, errors.New(fmt.Sprintf("impossible to get the event %s of the event sync message: %s", documentID, err))
+			}
+			eventList.Events = append(eventList.Events, event)
+		}
+		eventGenerated.Events[eventKey] = &eventList
+	}
+	return
+}
+
+// ListConsumingEventIDs returns the EventIDs of the event sync messages to which the event of the document ID
+// contributed, the oldest first.
+func (h *HistoryService) ListConsumingEventIDs(ctx context.Context, documentID string) (eventIDs []string, err error) {
+	histories, err := h.store.ListHistoriesOfEvent(ctx, h.collectionName(), documentID)
+	if err != nil {
+		h.configService.GetLogger().ErrorContext(ctx, "error during the trigger history retrieval", "documentID", documentID, "error", err)
+		return nil, err
+	}
+
+	sort.Slice(histories, func(i, j int) bool {
+		return histories[i].Date.Before(histories[j].Date)
+	})
+	eventIDs = make([]string, 0, len(histories))
+	for _, history := range histories {
+		eventIDs = append(eventIDs, history.EventID)
+	}
+	return
+}
+
+// ListHistory retrieves the trigger history records that match the filter, the most recent first.
+func (h *HistoryService) ListHistory(ctx context.Context, filter models.HistoryFilter) (histories []models.TriggerHistory, err error) {
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	histories, err = h.store.ListHistory(ctx, h.collectionName(), filter, limit)
+	if err != nil {
+		h.configService.GetLogger().ErrorContext(ctx, "error during the trigger history retrieval", "error", err)
+	}
+	return
+}
+
+// newDelivery creates the delivery result of a publication to the target at the date
+func newDelivery(target string, date time.Time, messageID string, err error, replay bool) (delivery models.Delivery) {
+	delivery = models.Delivery{
+		Date:      date,
+		Target:    target,
+		Status:    models.DeliveryStatusTypeSuccess,
+		MessageID: messageID,
+		Replay:    replay,
+	}
+	if err != nil {
+		delivery.Status = models.DeliveryStatusTypeFailure
+		delivery.Error = err.Error()
+	}
+	return
+}
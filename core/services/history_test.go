@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"testing"
+	"time"
+)
+
+func TestHistoryService_newDelivery(t *testing.T) {
+	type args struct {
+		target    string
+		messageID string
+		err       error
+		replay    bool
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantDelivery models.Delivery
+	}{
+		{
+			name: "success",
+			args: args{
+				target:    "projects/project123/topics/eventsync",
+				messageID: "123",
+			},
+			wantDelivery: models.Delivery{
+				Date:      now,
+				Target:    "projects/project123/topics/eventsync",
+				Status:    models.DeliveryStatusTypeSuccess,
+				MessageID: "123",
+			},
+		},
+		{
+			name: "failed replay",
+			args: args{
+				target: "projects/project123/topics/eventsync",
+				err:    errors.New("publish error"),
+				replay: true,
+			},
+			wantDelivery: models.Delivery{
+				Date:   now,
+				Target: "projects/project123/topics/eventsync",
+				Status: models.DeliveryStatusTypeFailure,
+				Error:  "publish error",
+				Replay: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDelivery := newDelivery(tt.args.target, now, tt.args.messageID, tt.args.err, tt.args.replay)
+			if gotDelivery != tt.wantDelivery {
+				t.Errorf("newDelivery() = %+v, want %+v", gotDelivery, tt.wantDelivery)
+			}
+		})
+	}
+}
+
+func TestHistoryService_GetEventGenerated(t *testing.T) {
+	config := generateValidConfig()
+	configService := &ConfigService{eventSyncConfig: config, clock: FixedClock(now)}
+	store, documentIDs := addMemoryEvents(t, config.ServiceName, []models.Event{
+		{EventKey: "entry1", Datetime: now, Content: "first"},
+		{EventKey: "entry2", Datetime: now, Content: "second"},
+		{EventKey: "entry2", Datetime: now.Add(-time.Second), Content: "third"},
+	})
+	eventService := NewEventService(configService, store)
+	historyService := NewHistoryService(configService, eventService)
+	events, err := eventService.GetEventsOverAPeriod(context.Background(), config.Trigger.ObservationPeriod)
+	if err != nil {
+		t.Fatalf("GetEventsOverAPeriod() error = %v", err)
+	}
+	eventGenerated := (&TriggerService{configService: configService}).createEventGenerated(events)
+	if err = historyService.RecordTrigger(context.Background(), eventGenerated, events, models.Delivery{Status: models.DeliveryStatusTypeSuccess}); err != nil {
+		t.Fatalf("RecordTrigger() error = %v", err)
+	}
+	wantMessage, _ := json.Marshal(eventGenerated)
+
+	tests := []struct {
+		name    string
+		delete  string
+		wantErr bool
+	}{
+		{name: "rebuilt message"},
+		{name: "deleted event", delete: documentIDs[2], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.delete != "" {
+				if err := store.UpdateEvents(context.Background(), config.ServiceName, []string{tt.delete}, EventUpdate{Delete: true}); err != nil {
+					t.Fatalf("UpdateEvents() error = %v", err)
+				}
+			}
+			history, err := historyService.GetHistory(context.Background(), eventGenerated.EventID)
+			if err != nil {
+				t.Fatalf("GetHistory() error = %v", err)
+			}
+			// The record doesn't contain the events, only their document IDs
+			for eventKey, eventList := range history.EventGenerated.Events {
+				if len(eventList.Events) != 0 || len(history.EventDocumentIDs[eventKey]) != eventList.NumberOfEvents {
+					t.Fatalf("RecordTrigger() %s events = %d, document IDs = %v", eventKey, len(eventList.Events), history.EventDocumentIDs[eventKey])
+				}
+			}
+
+			got, err := historyService.GetEventGenerated(context.Background(), history)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetEventGenerated() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotMessage, _ := json.Marshal(got); !tt.wantErr && string(gotMessage) != string(wantMessage) {
+				t.Errorf("GetEventGenerated() = %s, want %s", gotMessage, wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"golang.org/x/time/rate"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultMaxBodyBytes is the default maximal size of the event bodies, the Firestore document limit
+const defaultMaxBodyBytes int64 = 1 << 20
+
+// ErrRateLimited is returned when the rate limit of the endpoint or the global one is reached
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ErrTooManyConcurrentRequests is returned when the concurrency limit of the endpoint or the global one is reached
+var ErrTooManyConcurrentRequests = errors.New("too many concurrent requests")
+
+// LimitService enforces the rate and concurrency limits of the ingestion path. The token buckets and the concurrency
+// slots are built from the configuration, per endpoint and globally, and rebuilt after each configuration reload.
+type LimitService struct {
+	configService *ConfigService
+	// mu protects the limiters swap during a configuration reload
+	mu       sync.RWMutex
+	global   *limiter
+	endpoint map[string]*limiter
+}
+
+// limiter is the token bucket and the concurrency slots of a Limits definition. Nil fields are not limited
+type limiter struct {
+	bucket *rate.Limiter
+	slots  chan struct{}
+}
+
+// NewLimitService creates the Limit service and builds the limiters of the configuration
+func NewLimitService(configService *ConfigService) (limitService *LimitService) {
+	limitService = &LimitService{configService: configService}
+	limitService.setLimiters(configService.GetConfig())
+
+	configService.OnConfigChange(func(previousConfig *models.EventSyncConfig, newConfig *models.EventSyncConfig) {
+		limitService.setLimiters(newConfig)
+	})
+	return
+}
+
+// setLimiters builds the limiters of the configuration. The tokens and slots in use are reset
+func (l *LimitService) setLimiters(config *models.EventSyncConfig) {
+	endpointLimiters := make(map[string]*limiter)
+	for _, endpoint := range config.Endpoints {
+		if endpoint.Limits != nil {
+			endpointLimiters[endpoint.EventKey] = newLimiter(endpoint.Limits)
+		}
+	}
+	var globalLimiter *limiter
+	if config.Limits != nil {
+		globalLimiter = newLimiter(config.Limits)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global = globalLimiter
+	l.endpoint = endpointLimiters
+}
+
+// newLimiter creates the token bucket and the concurrency slots of the limits, if defined
+func newLimiter(limits *models.Limits) *limiter {
+	l := &limiter{}
+	if limits.RequestsPerSecond > 0 {
+		l.bucket = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), getBurst(limits))
+	}
+	if limits.MaxConcurrentRequests > 0 {
+		l.slots = make(chan struct{}, limits.MaxConcurrentRequests)
+	}
+	return l
+}
+
+// getBurst returns the token bucket size of the limits, the rounded up RequestsPerSecond by default
+func getBurst(limits *models.Limits) int {
+	if limits.Burst > 0 {
+		return limits.Burst
+	}
+	return int(math.Max(1, math.Ceil(limits.RequestsPerSecond)))
+}
+
+// Acquire takes a token and a concurrency slot of the eventKey endpoint and of the global limits. The release function
+// must be called at the end of the event processing to free the slots. If a limit is reached, ErrRateLimited or
+// ErrTooManyConcurrentRequests (wrapped) is returned with the delay before the next token.
+func (l *LimitService) Acquire(eventKey string) (release func(), retryAfter time.Duration, err error) {
+	l.mu.RLock()
+	limiters := []*limiter{l.endpoint[eventKey], l.global}
+	l.mu.RUnlock()
+
+	// The tokens are reserved first, and given back if any bucket is empty
+	now := time.Now()
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	for _, lim := range limiters {
+		if lim == nil || lim.bucket == nil {
+			continue
+		}
+		reservation := lim.bucket.ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+		if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+			for _, r := range reservations {
+				r.CancelAt(now)
+			}
+			return nil, delay, fmt.Errorf("%w on the endpoint %s", ErrRateLimited, eventKey)
+		}
+	}
+
+	acquired := make([]chan struct{}, 0, len(limiters))
+	release = func() {
+		for _, slots := range acquired {
+			<-slots
+		}
+	}
+	for _, lim := range limiters {
+		if lim == nil || lim.slots == nil {
+			continue
+		}
+		select {
+		case lim.slots <- struct{}{}:
+			acquired = append(acquired, lim.slots)
+		default:
+			// The request is not processed: the slots and the tokens are given back
+			release()
+			for _, r := range reservations {
+				r.CancelAt(now)
+			}
+			return nil, time.Second, fmt.Errorf("%w on the endpoint %s", ErrTooManyConcurrentRequests, eventKey)
+		}
+	}
+	return
+}
+
+// GetMaxBodyBytes returns the maximal size of the eventKey event bodies: the endpoint value, else the global one,
+// else 1 MiB
+func (l *LimitService) GetMaxBodyBytes(eventKey string) int64 {
+	config := l.configService.GetConfig()
+	for _, endpoint := range config.Endpoints {
+		if endpoint.EventKey == eventKey && endpoint.Limits != nil && endpoint.Limits.MaxBodyBytes > 0 {
+			return endpoint.Limits.MaxBodyBytes
+		}
+	}
+	if config.Limits != nil && config.Limits.MaxBodyBytes > 0 {
+		return config.Limits.MaxBodyBytes
+	}
+	return defaultMaxBodyBytes
+}
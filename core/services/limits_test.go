@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"testing"
+)
+
+func TestLimitService_Acquire(t *testing.T) {
+	config := generateValidConfig()
+	config.Limits = &models.Limits{MaxConcurrentRequests: 2}
+	config.Endpoints[0].Limits = &models.Limits{RequestsPerSecond: 0.001, Burst: 2}
+	l := NewLimitService(&ConfigService{eventSyncConfig: config})
+
+	// The burst of the entry1 endpoint is 2 events, the next token comes in 1000 seconds
+	for i := 0; i < 2; i++ {
+		release, _, err := l.Acquire("entry1")
+		if err != nil {
+			t.Fatalf("Acquire() %d error = %v", i, err)
+		}
+		release()
+	}
+	_, retryAfter, err := l.Acquire("entry1")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Acquire() error = %v, want %v", err, ErrRateLimited)
+	}
+	if retryAfter.Seconds() < 900 {
+		t.Errorf("Acquire() retryAfter = %v, want about 1000s", retryAfter)
+	}
+
+	// The global concurrency limit is shared by the endpoints
+	release1, _, err := l.Acquire("entry2")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	release2, _, err := l.Acquire("entry2")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, _, err = l.Acquire("entry2"); !errors.Is(err, ErrTooManyConcurrentRequests) {
+		t.Errorf("Acquire() error = %v, want %v", err, ErrTooManyConcurrentRequests)
+	}
+	release1()
+	release3, _, err := l.Acquire("entry2")
+	if err != nil {
+		t.Errorf("Acquire() after release error = %v", err)
+	}
+	release2()
+	release3()
+}
+
+func TestLimitService_Acquire_rejectedGlobalToken(t *testing.T) {
+	config := generateValidConfig()
+	config.Limits = &models.Limits{RequestsPerSecond: 0.001, Burst: 1}
+	config.Endpoints[0].Limits = &models.Limits{RequestsPerSecond: 0.001, Burst: 1}
+	l := NewLimitService(&ConfigService{eventSyncConfig: config})
+
+	if _, _, err := l.Acquire("entry2"); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, _, err := l.Acquire("entry1"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Acquire() error = %v, want %v", err, ErrRateLimited)
+	}
+	// The entry1 token is given back when the global bucket is empty
+	l.global = nil
+	if _, _, err := l.Acquire("entry1"); err != nil {
+		t.Errorf("Acquire() without global limits error = %v", err)
+	}
+}
+
+func TestLimitService_Acquire_rejectedConcurrency(t *testing.T) {
+	config := generateValidConfig()
+	config.Limits = &models.Limits{MaxConcurrentRequests: 1}
+	config.Endpoints[0].Limits = &models.Limits{RequestsPerSecond: 0.001, Burst: 2}
+	l := NewLimitService(&ConfigService{eventSyncConfig: config})
+
+	release, _, err := l.Acquire("entry2")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, _, err = l.Acquire("entry1"); !errors.Is(err, ErrTooManyConcurrentRequests) {
+		t.Fatalf("Acquire() error = %v, want %v", err, ErrTooManyConcurrentRequests)
+	}
+	release()
+	// The entry1 token is given back when the concurrency limit is reached: the burst of 2 is still available
+	for i := 0; i < 2; i++ {
+		release, _, err = l.Acquire("entry1")
+		if err != nil {
+			t.Fatalf("Acquire() %d after the concurrency rejection error = %v", i, err)
+		}
+		release()
+	}
+}
+
+func TestLimitService_GetMaxBodyBytes(t *testing.T) {
+	config := generateValidConfig()
+	l := NewLimitService(&ConfigService{eventSyncConfig: config})
+	if got := l.GetMaxBodyBytes("entry1"); got != defaultMaxBodyBytes {
+		t.Errorf("GetMaxBodyBytes() default = %d, want %d", got, defaultMaxBodyBytes)
+	}
+
+	config.Limits = &models.Limits{MaxBodyBytes: 2048}
+	config.Endpoints[0].Limits = &models.Limits{MaxBodyBytes: 1024}
+	if got := l.GetMaxBodyBytes("entry1"); got != 1024 {
+		t.Errorf("GetMaxBodyBytes() endpoint = %d, want 1024", got)
+	}
+	if got := l.GetMaxBodyBytes("entry2"); got != 2048 {
+		t.Errorf("GetMaxBodyBytes() global = %d, want 2048", got)
+	}
+}
+
+func TestConfigService_checkConfigLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  *models.Limits
+		wantErr bool
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name:   "valid limits",
+			limits: &models.Limits{RequestsPerSecond: 0.5, MaxBodyBytes: 4096, MaxConcurrentRequests: 10},
+		},
+		{
+			name:    "negative rate",
+			limits:  &models.Limits{RequestsPerSecond: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative burst",
+			limits:  &models.Limits{RequestsPerSecond: 1, Burst: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative body size",
+			limits:  &models.Limits{MaxBodyBytes: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative concurrency",
+			limits:  &models.Limits{MaxConcurrentRequests: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ConfigService{eventSyncConfig: generateValidConfig()}
+			if got, _ := c.checkConfigLimits("", "", "limits", tt.limits); (got != "") != tt.wantErr {
+				t.Errorf("checkConfigLimits() got = %v, wantErr %v", got, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// documentIDAlphabet is the alphabet of the generated document IDs, like the Firestore ones
+const documentIDAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// documentIDLength is the length of the generated document IDs, like the Firestore ones
+const documentIDLength = 20
+
+// memoryStore is an EventStore in memory, for the local development: the data are lost at the stop of the instance
+type memoryStore struct {
+	mu           sync.RWMutex
+	events       map[string]map[string]models.Event
+	histories    map[string]map[string]models.TriggerHistory
+	auditRecords map[string][]models.AuditRecord
+}
+
+// NewMemoryStore creates an empty in-memory EventStore. No index is required
+func NewMemoryStore() EventStore {
+	return &memoryStore{
+		events:       make(map[string]map[string]models.Event),
+		histories:    make(map[string]map[string]models.TriggerHistory),
+		auditRecords: make(map[string][]models.AuditRecord),
+	}
+}
+
+// newDocumentID generates a random document ID
+func newDocumentID() (documentID string, err error) {
+	id := make([]byte, documentIDLength)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(documentIDAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		id[i] = documentIDAlphabet[n.Int64()]
+	}
+	return string(id), nil
+}
+
+// AddEvent stores the event with a new document ID
+func (m *memoryStore) AddEvent(ctx context.Context, collection string, event models.Event) (documentID string, err error) {
+	documentID, err = newDocumentID()
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events[collection] == nil {
+		m.events[collection] = make(map[string]models.Event)
+	}
+	event.FirestoreDocumentID = documentID
+	m.events[collection][documentID] = event
+	return
+}
+
+// GetEvent returns the stored event
+func (m *memoryStore) GetEvent(ctx context.Context, collection string, documentID string) (event models.Event, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	event, found := m.events[collection][documentID]
+	if !found {
+		return models.Event{}, ErrEventNotFound
+	}
+	return
+}
+
+// ListEvents returns the stored events that match the filter
+func (m *memoryStore) ListEvents(ctx context.Context, collection string, filter EventStoreFilter) (events []models.Event, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events = make([]models.Event, 0)
+	for _, event := range m.events[collection] {
+		if filter.match(event) {
+			events = append(events, event)
+		}
+	}
+	return
+}
+
+// CountEvents returns the number of stored events that match the filter
+func (m *memoryStore) CountEvents(ctx context.Context, collection string, filter EventStoreFilter) (count int, err error) {
+	events, err := m.ListEvents(ctx, collection, filter)
+	return len(events), err
+}
+
+// ListEventIDs returns the document IDs of the stored events that match the filter
+func (m *memoryStore) ListEventIDs(ctx context.Context, collection string, filter EventStoreFilter) (documentIDs []string, err error) {
+	events, err := m.ListEvents(ctx, collection, filter)
+	for _, event := range events {
+		documentIDs = append(documentIDs, event.FirestoreDocumentID)
+	}
+	return
+}
+
+// ScanEvents sorts the stored events that match the filter by date and document ID, and scans them
+func (m *memoryStore) ScanEvents(ctx context.Context, collection string, filter EventStoreFilter, after *EventCursor, limit int, scan func(event models.Event) bool) (err error) {
+	events, err := m.ListEvents(ctx, collection, filter)
+	if err != nil {
+		return
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Datetime.Equal(events[j].Datetime) {
+			return events[i].FirestoreDocumentID < events[j].FirestoreDocumentID
+		}
+		return events[i].Datetime.Before(events[j].Datetime)
+	})
+
+	scanned := 0
+	for _, event := range events {
+		if after != nil && (event.Datetime.Before(after.Datetime) ||
+			(event.Datetime.Equal(after.Datetime) && event.FirestoreDocumentID <= after.DocumentID)) {
+			continue
+		}
+		if scanned == limit || !scan(event) {
+			return
+		}
+		scanned++
+	}
+	return
+}
+
+// GetExistingEventIDs splits the document IDs into the stored events, grouped by eventKey, and the missing ones
+func (m *memoryStore) GetExistingEventIDs(ctx context.Context, collection string, documentIDs []string) (existing map[string][]string, notFound []string, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	existing = make(map[string][]string)
+	for _, documentID := range documentIDs {
+		if event, found := m.events[collection][documentID]; found {
+			existing[event.EventKey] = append(existing[event.EventKey], documentID)
+		} else {
+			notFound = append(notFound, documentID)
+		}
+	}
+	return
+}
+
+// UpdateEvents updates the stored events. Like a Firestore batch, nothing is updated if an event doesn't exist
+func (m *memoryStore) UpdateEvents(ctx context.Context, collection string, documentIDs []string, update EventUpdate) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, documentID := range documentIDs {
+		if _, found := m.events[collection][documentID]; !found && !update.Delete {
+			return ErrEventNotFound
+		}
+	}
+	for _, documentID := range documentIDs {
+		if update.Delete {
+			delete(m.events[collection], documentID)
+			continue
+		}
+		event := m.events[collection][documentID]
+		if update.AlreadyExported != nil {
+			event.AlreadyExported = *update.AlreadyExported
+		}
+		if update.EventKey != "" {
+			event.EventKey = update.EventKey
+		}
+		m.events[collection][documentID] = event
+	}
+	return
+}
+
+// AddAuditRecord appends the audit record
+func (m *memoryStore) AddAuditRecord(ctx context.Context, collection string, auditRecord models.AuditRecord) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.auditRecords[collection] = append(m.auditRecords[collection], auditRecord)
+	return
+}
+
+// CreateHistory stores the trigger history record if its EventID is new
+func (m *memoryStore) CreateHistory(ctx context.Context, collection string, history models.TriggerHistory) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.histories[collection] == nil {
+		m.histories[collection] = make(map[string]models.TriggerHistory)
+	}
+	if _, found := m.histories[collection][history.EventID]; found {
+		return ErrHistoryAlreadyExists
+	}
+	m.histories[collection][history.EventID] = history
+	return
+}
+
+// AddHistoryDelivery appends the delivery to the stored trigger history record
+func (m *memoryStore) AddHistoryDelivery(ctx context.Context, collection string, eventID string, delivery models.Delivery) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	history, found := m.histories[collection][eventID]
+	if !found {
+		return ErrHistoryNotFound
+	}
+	// The deliveries array is copied, the previously returned records must not change
+	history.Deliveries = append(append(make([]models.Delivery, 0, len(history.Deliveries)+1), history.Deliveries...), delivery)
+	history.Status = delivery.Status
+	m.histories[collection][eventID] = history
+	return
+}
+
+// GetHistory returns the stored trigger history record
+func (m *memoryStore) GetHistory(ctx context.Context, collection string, eventID string) (history *models.TriggerHistory, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stored, found := m.histories[collection][eventID]
+	if !found {
+		return nil, ErrHistoryNotFound
+	}
+	return &stored, nil
+}
+
+// ListHistoriesOfEvent returns the stored trigger history records which include the document ID
+func (m *memoryStore) ListHistoriesOfEvent(ctx context.Context, collection string, documentID string) (histories []models.TriggerHistory, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	histories = make([]models.TriggerHistory, 0)
+	for _, history := range m.histories[collection] {
+		if containsString(history.DocumentIDs, documentID) {
+			histories = append(histories, models.TriggerHistory{EventID: history.EventID, Date: history.Date})
+		}
+	}
+	return
+}
+
+// ListHistory returns the stored trigger history records of the filter, the most recent first
+func (m *memoryStore) ListHistory(ctx context.Context, collection string, filter models.HistoryFilter, limit int) (histories []models.TriggerHistory, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	histories = make([]models.TriggerHistory, 0)
+	for _, history := range m.histories[collection] {
+		if (filter.Status == "" || history.Status == filter.Status) &&
+			(filter.StartDate == nil || !history.Date.Before(*filter.StartDate)) &&
+			(filter.EndDate == nil || !history.Date.After(*filter.EndDate)) {
+			histories = append(histories, history)
+		}
+	}
+	sort.Slice(histories, func(i, j int) bool {
+		return histories[i].Date.After(histories[j].Date)
+	})
+	if len(histories) > limit {
+		histories = histories[:limit]
+	}
+	return
+}
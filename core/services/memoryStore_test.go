@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// addMemoryEvents stores the events in the collection of a new memory store and returns their document IDs
+func addMemoryEvents(t *testing.T, collection string, events []models.Event) (store EventStore, documentIDs []string) {
+	store = NewMemoryStore()
+	for _, event := range events {
+		documentID, err := store.AddEvent(context.Background(), collection, event)
+		if err != nil {
+			t.Fatalf("AddEvent() error = %v", err)
+		}
+		documentIDs = append(documentIDs, documentID)
+	}
+	return
+}
+
+func TestMemoryStore_ListEvents(t *testing.T) {
+	start := time.Date(2022, 03, 28, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	exported := true
+	notExported := false
+	store, documentIDs := addMemoryEvents(t, "test", []models.Event{
+		{EventKey: "entry1", Datetime: start},
+		{EventKey: "entry1", Datetime: start.Add(time.Minute)},
+		{EventKey: "entry2", Datetime: start.Add(time.Minute), AlreadyExported: true},
+		{EventKey: "entry1", Datetime: end.Add(time.Minute)},
+	})
+
+	tests := []struct {
+		name   string
+		filter EventStoreFilter
+		want   []string
+	}{
+		{name: "no filter", filter: EventStoreFilter{}, want: documentIDs},
+		{name: "eventKey", filter: EventStoreFilter{EventKeys: []string{"entry2"}}, want: documentIDs[2:3]},
+		{name: "start date excluded", filter: EventStoreFilter{StartDate: &start, EndDate: &end}, want: documentIDs[1:3]},
+		{name: "start date included", filter: EventStoreFilter{StartDate: &start, IncludeStartDate: true, EndDate: &end}, want: documentIDs[0:3]},
+		{name: "exported", filter: EventStoreFilter{Exported: &exported}, want: documentIDs[2:3]},
+		{name: "not exported", filter: EventStoreFilter{EventKeys: []string{"entry1", "entry2"}, Exported: &notExported, EndDate: &end}, want: documentIDs[0:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListEventIDs(context.Background(), "test", tt.filter)
+			if err != nil {
+				t.Fatalf("ListEventIDs() error = %v", err)
+			}
+			if !sameStrings(got, tt.want) {
+				t.Errorf("ListEventIDs() = %v, want %v", got, tt.want)
+			}
+			count, err := store.CountEvents(context.Background(), "test", tt.filter)
+			if err != nil || count != len(tt.want) {
+				t.Errorf("CountEvents() = %d, error = %v, want %d", count, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMemoryStore_ScanEvents(t *testing.T) {
+	start := time.Date(2022, 03, 28, 10, 0, 0, 0, time.UTC)
+	store, documentIDs := addMemoryEvents(t, "test", []models.Event{
+		{EventKey: "entry1", Datetime: start.Add(2 * time.Minute)},
+		{EventKey: "entry1", Datetime: start},
+		{EventKey: "entry1", Datetime: start.Add(time.Minute)},
+	})
+
+	tests := []struct {
+		name  string
+		after *EventCursor
+		limit int
+		stop  int
+		want  []string
+	}{
+		{name: "oldest first", limit: 10, want: []string{documentIDs[1], documentIDs[2], documentIDs[0]}},
+		{name: "after the cursor", after: &EventCursor{Datetime: start, DocumentID: documentIDs[1]}, limit: 10, want: []string{documentIDs[2], documentIDs[0]}},
+		{name: "limit", limit: 2, want: []string{documentIDs[1], documentIDs[2]}},
+		{name: "stopped by the scan", limit: 10, stop: 1, want: []string{documentIDs[1]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			err := store.ScanEvents(context.Background(), "test", EventStoreFilter{}, tt.after, tt.limit, func(event models.Event) bool {
+				got = append(got, event.FirestoreDocumentID)
+				return len(got) != tt.stop
+			})
+			if err != nil {
+				t.Fatalf("ScanEvents() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScanEvents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_UpdateEvents(t *testing.T) {
+	exported := true
+	tests := []struct {
+		name      string
+		update    EventUpdate
+		missingID bool
+		wantErr   bool
+		want      *models.Event
+	}{
+		{name: "exported", update: EventUpdate{AlreadyExported: &exported}, want: &models.Event{EventKey: "entry1", AlreadyExported: true}},
+		{name: "relabel", update: EventUpdate{EventKey: "entry2"}, want: &models.Event{EventKey: "entry2"}},
+		{name: "delete", update: EventUpdate{Delete: true}},
+		{name: "delete with missing event", update: EventUpdate{Delete: true}, missingID: true},
+		{name: "update with missing event", update: EventUpdate{EventKey: "entry2"}, missingID: true, wantErr: true, want: &models.Event{EventKey: "entry1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, documentIDs := addMemoryEvents(t, "test", []models.Event{{EventKey: "entry1"}})
+			if tt.missingID {
+				documentIDs = append(documentIDs, "missing")
+			}
+			err := store.UpdateEvents(context.Background(), "test", documentIDs, tt.update)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateEvents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			event, err := store.GetEvent(context.Background(), "test", documentIDs[0])
+			if tt.want == nil {
+				if !errors.Is(err, ErrEventNotFound) {
+					t.Errorf("GetEvent() error = %v, want %v", err, ErrEventNotFound)
+				}
+				return
+			}
+			if event.EventKey != tt.want.EventKey || event.AlreadyExported != tt.want.AlreadyExported {
+				t.Errorf("GetEvent() = %v, want %v", event, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_History(t *testing.T) {
+	date := time.Date(2022, 03, 28, 10, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	ctx := context.Background()
+	for i, eventID := range []string{"id1", "id2", "id3"} {
+		err := store.CreateHistory(ctx, "test", models.TriggerHistory{
+			EventID:     eventID,
+			Date:        date.Add(time.Duration(i) * time.Minute),
+			Status:      models.DeliveryStatusTypeSuccess,
+			DocumentIDs: []string{"doc" + eventID, "shared"},
+		})
+		if err != nil {
+			t.Fatalf("CreateHistory() error = %v", err)
+		}
+	}
+	if err := store.CreateHistory(ctx, "test", models.TriggerHistory{EventID: "id1"}); !errors.Is(err, ErrHistoryAlreadyExists) {
+		t.Errorf("CreateHistory() error = %v, want %v", err, ErrHistoryAlreadyExists)
+	}
+	if err := store.AddHistoryDelivery(ctx, "test", "id2", models.Delivery{Status: models.DeliveryStatusTypeFailure}); err != nil {
+		t.Fatalf("AddHistoryDelivery() error = %v", err)
+	}
+
+	startDate := date.Add(time.Minute)
+	tests := []struct {
+		name   string
+		filter models.HistoryFilter
+		limit  int
+		want   []string
+	}{
+		{name: "most recent first", limit: 10, want: []string{"id3", "id2", "id1"}},
+		{name: "limit", limit: 1, want: []string{"id3"}},
+		{name: "status", filter: models.HistoryFilter{Status: models.DeliveryStatusTypeFailure}, limit: 10, want: []string{"id2"}},
+		{name: "start date", filter: models.HistoryFilter{StartDate: &startDate, EndDate: &startDate}, limit: 10, want: []string{"id2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			histories, err := store.ListHistory(ctx, "test", tt.filter, tt.limit)
+			if err != nil {
+				t.Fatalf("ListHistory() error = %v", err)
+			}
+			got := make([]string, 0)
+			for _, history := range histories {
+				got = append(got, history.EventID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListHistory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	histories, err := store.ListHistoriesOfEvent(ctx, "test", "docid2")
+	if err != nil || len(histories) != 1 || histories[0].EventID != "id2" {
+		t.Errorf("ListHistoriesOfEvent() = %v, %v, want id2", histories, err)
+	}
+}
+
+// sameStrings checks if both lists have the same values, in any order
+func sameStrings(got []string, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for _, value := range want {
+		if !containsString(got, value) {
+			return false
+		}
+	}
+	return true
+}
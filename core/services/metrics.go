@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"net/http"
+	"time"
+)
+
+// metricsNamespace is the prefix of the metric names
+const metricsNamespace = "eventsync"
+
+// pendingEventsScrapeTimeout is the maximal duration of the count of the pending events during a scrape
+const pendingEventsScrapeTimeout = 10 * time.Second
+
+// RejectionReason is the reason of an event rejection, a label of the rejected events metric
+type RejectionReason string
+
+const (
+	// RejectionUnknownEndpoint is the rejection of the events of an eventKey not defined in the configuration
+	RejectionUnknownEndpoint RejectionReason = "unknown_endpoint"
+	// RejectionMethodNotAllowed is the rejection of the events with a method not accepted by the endpoint
+	RejectionMethodNotAllowed RejectionReason = "method_not_allowed"
+	// RejectionPayloadTooLarge is the rejection of the events bigger than the maximal body size
+	RejectionPayloadTooLarge RejectionReason = "payload_too_large"
+	// RejectionRateLimited is the rejection of the events over the rate or the concurrency limits
+	RejectionRateLimited RejectionReason = "rate_limited"
+	// RejectionInvalidSignature is the rejection of the events without valid signature
+	RejectionInvalidSignature RejectionReason = "invalid_signature"
+	// RejectionInvalidEvent is the rejection of the events which can't be read
+	RejectionInvalidEvent RejectionReason = "invalid_event"
+	// RejectionStorageUnavailable is the rejection of the events which can't be stored
+	RejectionStorageUnavailable RejectionReason = "storage_unavailable"
+)
+
+// Metrics are the Prometheus metrics of the instance, since its start. The methods are safe on a nil Metrics, nothing
+// is measured.
+type Metrics struct {
+	registry               *prometheus.Registry
+	eventsReceived         *prometheus.CounterVec
+	eventsRejected         *prometheus.CounterVec
+	pendingEvents          *pendingEventsCollector
+	triggerEvaluations     *prometheus.CounterVec
+	messagesPublished      *prometheus.CounterVec
+	publishDuration        prometheus.Histogram
+	firestoreQueryDuration *prometheus.HistogramVec
+	httpRequests           *prometheus.CounterVec
+	httpRequestDuration    *prometheus.HistogramVec
+}
+
+// NewMetrics creates the metrics of the instance in a dedicated registry, with the Go runtime and process metrics. The
+// pendingEvents function counts the not exported events per eventKey at each scrape; the gauge is not exposed if nil.
+func NewMetrics(pendingEvents func(ctx context.Context) map[string]int) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_received_total",
+			Help:      "Number of events stored, per eventKey.",
+		}, []string{"eventKey"}),
+		eventsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_rejected_total",
+			Help:      "Number of events rejected before or during the storage, per eventKey and reason.",
+		}, []string{"eventKey", "reason"}),
+		pendingEvents: &pendingEventsCollector{
+			desc: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "pending_events"),
+				"Number of not exported events in the observation period, per eventKey, at the scrape.",
+				[]string{"eventKey"}, nil),
+			count: pendingEvents,
+		},
+		triggerEvaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "trigger_evaluations_total",
+			Help:      "Number of trigger evaluations after the event storage, per outcome.",
+		}, []string{"outcome"}),
+		messagesPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "messages_published_total",
+			Help:      "Number of event sync messages published to Pub/Sub, per status and replay flag.",
+		}, []string{"status", "replay"}),
+		publishDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Duration of the event sync message publications to Pub/Sub.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		firestoreQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "firestore_query_duration_seconds",
+			Help:      "Duration of the Firestore event queries, per operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests, per handler, method and status code.",
+		}, []string{"handler", "method", "code"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the HTTP requests, per handler and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler", "method"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.eventsReceived, m.eventsRejected, m.pendingEvents, m.triggerEvaluations, m.messagesPublished,
+		m.publishDuration, m.firestoreQueryDuration, m.httpRequests, m.httpRequestDuration,
+	)
+	return m
+}
+
+// Handler returns the HTTP handler of the metrics, in the Prometheus or OpenMetrics format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// InstrumentHandler wraps the handler function to count and time its requests, with the handler name as label
+func (m *Metrics) InstrumentHandler(handler string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	if m == nil {
+		return handlerFunc
+	}
+	labels := prometheus.Labels{"handler": handler}
+	return promhttp.InstrumentHandlerCounter(m.httpRequests.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(m.httpRequestDuration.MustCurryWith(labels), handlerFunc))
+}
+
+// IncEventsReceived increments the number of events stored for the eventKey
+func (m *Metrics) IncEventsReceived(eventKey string) {
+	if m == nil {
+		return
+	}
+	m.eventsReceived.WithLabelValues(eventKey).Inc()
+}
+
+// IncEventsRejected increments the number of events rejected for the eventKey with the reason. The eventKey must be
+// empty for the unknown endpoints, to not create a metric per invalid path
+func (m *Metrics) IncEventsRejected(eventKey string, reason RejectionReason) {
+	if m == nil {
+		return
+	}
+	m.eventsRejected.WithLabelValues(eventKey, string(reason)).Inc()
+}
+
+// GetEventsRejected returns the number of events rejected for the eventKey with the reason
+func (m *Metrics) GetEventsRejected(eventKey string, reason RejectionReason) int64 {
+	if m == nil {
+		return 0
+	}
+	metric := &dto.Metric{}
+	if err := m.eventsRejected.WithLabelValues(eventKey, string(reason)).Write(metric); err != nil {
+		return 0
+	}
+	return int64(metric.GetCounter().GetValue())
+}
+
+// IncTriggerEvaluations increments the number of trigger evaluations with the outcome
+func (m *Metrics) IncTriggerEvaluations(outcome string) {
+	if m == nil {
+		return
+	}
+	m.triggerEvaluations.WithLabelValues(outcome).Inc()
+}
+
+// ObservePublish records an event sync message publication, its duration and its status
+func (m *Metrics) ObservePublish(duration time.Duration, err error, replay bool) {
+	if m == nil {
+		return
+	}
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	replayLabel := "false"
+	if replay {
+		replayLabel = "true"
+	}
+	m.messagesPublished.WithLabelValues(status, replayLabel).Inc()
+	m.publishDuration.Observe(duration.Seconds())
+}
+
+// ObserveFirestoreQuery records the duration of a Firestore query of the operation
+func (m *Metrics) ObserveFirestoreQuery(operation string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.firestoreQueryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// pendingEventsCollector is the gauge of the not exported events, counted at the scrape time. Unlike a gauge updated
+// by the trigger evaluations, the value is never stale and the concurrent exports can't corrupt it.
+type pendingEventsCollector struct {
+	desc  *prometheus.Desc
+	count func(ctx context.Context) map[string]int
+}
+
+// Describe sends the description of the pending events gauge
+func (p *pendingEventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.desc
+}
+
+// Collect counts the pending events and sends a gauge per eventKey. Nothing is sent if the count fails
+func (p *pendingEventsCollector) Collect(ch chan<- prometheus.Metric) {
+	if p.count == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pendingEventsScrapeTimeout)
+	defer cancel()
+	for eventKey, nbOfEvents := range p.count(ctx) {
+		ch <- prometheus.MustNewConstMetric(p.desc, prometheus.GaugeValue, float64(nbOfEvents), eventKey)
+	}
+}
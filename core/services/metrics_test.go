@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_GetEventsRejected(t *testing.T) {
+	m := NewMetrics(nil)
+	m.IncEventsRejected("entry1", RejectionInvalidSignature)
+	m.IncEventsRejected("entry1", RejectionInvalidSignature)
+	m.IncEventsRejected("entry1", RejectionRateLimited)
+	m.IncEventsRejected("", RejectionUnknownEndpoint)
+
+	tests := []struct {
+		name     string
+		eventKey string
+		reason   RejectionReason
+		want     int64
+	}{
+		{name: "counted reason", eventKey: "entry1", reason: RejectionInvalidSignature, want: 2},
+		{name: "other reason", eventKey: "entry1", reason: RejectionRateLimited, want: 1},
+		{name: "unknown endpoint", eventKey: "", reason: RejectionUnknownEndpoint, want: 1},
+		{name: "no rejection", eventKey: "entry2", reason: RejectionInvalidSignature, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.GetEventsRejected(tt.eventKey, tt.reason); got != tt.want {
+				t.Errorf("GetEventsRejected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetrics_pendingEvents(t *testing.T) {
+	pending := map[string]int{"entry1": 5}
+	m := NewMetrics(func(ctx context.Context) map[string]int {
+		return pending
+	})
+
+	tests := []struct {
+		name    string
+		pending map[string]int
+		want    string
+		wantNot string
+	}{
+		{name: "counted at the scrape", pending: map[string]int{"entry1": 5}, want: `eventsync_pending_events{eventKey="entry1"} 5`},
+		{name: "exported events", pending: map[string]int{"entry1": 2}, want: `eventsync_pending_events{eventKey="entry1"} 2`},
+		{name: "count failure", pending: nil, wantNot: `eventsync_pending_events`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending = tt.pending
+			got := scrapeMetrics(t, m)
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("Handler() metrics don't contain %q", tt.want)
+			}
+			if tt.wantNot != "" && strings.Contains(got, tt.wantNot) {
+				t.Errorf("Handler() metrics contain %q", tt.wantNot)
+			}
+		})
+	}
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := NewMetrics(nil)
+	m.IncEventsReceived("entry1")
+	m.IncTriggerEvaluations("triggered")
+	m.ObservePublish(time.Second, errors.New("unavailable"), true)
+	m.ObserveFirestoreQuery("query_events", time.Millisecond)
+
+	got := scrapeMetrics(t, m)
+	for _, want := range []string{
+		`eventsync_events_received_total{eventKey="entry1"} 1`,
+		`eventsync_trigger_evaluations_total{outcome="triggered"} 1`,
+		`eventsync_messages_published_total{replay="true",status="failure"} 1`,
+		`eventsync_publish_duration_seconds_count 1`,
+		`eventsync_firestore_query_duration_seconds_count{operation="query_events"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Handler() metrics don't contain %q", want)
+		}
+	}
+}
+
+func TestMetrics_nil(t *testing.T) {
+	var m *Metrics
+	// The methods of a nil Metrics don't measure anything, without panic
+	m.IncEventsReceived("entry1")
+	m.IncEventsRejected("entry1", RejectionInvalidEvent)
+	m.IncTriggerEvaluations("triggered")
+	m.ObservePublish(time.Second, nil, false)
+	m.ObserveFirestoreQuery("query_events", time.Second)
+	if got := m.GetEventsRejected("entry1", RejectionInvalidEvent); got != 0 {
+		t.Errorf("GetEventsRejected() = %v, want 0", got)
+	}
+}
+
+// scrapeMetrics returns the text exposition of the metrics
+func scrapeMetrics(t *testing.T, m *Metrics) string {
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	if err != nil {
+		t.Fatalf("impossible to read the metrics: %v", err)
+	}
+	return string(body)
+}
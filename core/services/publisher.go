@@ -0,0 +1,169 @@
+package services
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StdoutTarget is the target of the writer publisher which prints the messages on the standard output
+const StdoutTarget = "stdout"
+
+// MessagePublisher delivers the event sync messages to the target: the Pub/Sub topic of the configuration, or a
+// stream for the local development (see NewWriterPublisher).
+type MessagePublisher interface {
+	// Target returns the name of the destination of the messages, recorded in the deliveries
+	Target() string
+	// Publish sends the message data with its attributes and returns the ID of the published message
+	Publish(ctx context.Context, data []byte, attributes map[string]string) (messageID string, err error)
+}
+
+// pubsubPublisher publishes the messages on the targetPubSub topic of the configuration
+type pubsubPublisher struct {
+	configService *ConfigService
+	pubsubClient  *pubsub.Client
+	pubsubTopic   *pubsub.Topic
+	// topicMu protects the pubsubClient and pubsubTopic swap when the target topic changes after a configuration reload
+	topicMu sync.RWMutex
+}
+
+// NewPubSubPublisher creates the publisher of the targetPubSub topic. The context is required to create a PubSub client
+// and a Topic object. The topic changes of the configuration reloads are followed.
+func NewPubSubPublisher(ctx context.Context, configService *ConfigService) (publisher MessagePublisher, err error) {
+	p := &pubsubPublisher{configService: configService}
+
+	err = p.setPubSubTopic(ctx, configService.GetConfig().TargetPubSub.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Follow the target topic changes of the configuration reloads
+	configService.OnConfigChange(func(previousConfig *models.EventSyncConfig, newConfig *models.EventSyncConfig) {
+		if previousConfig.TargetPubSub.Topic == newConfig.TargetPubSub.Topic {
+			return
+		}
+		p.configService.GetLogger().InfoContext(ctx, "target topic changed", "previousTopic", previousConfig.TargetPubSub.Topic, "topic", newConfig.TargetPubSub.Topic)
+		if err := p.setPubSubTopic(ctx, newConfig.TargetPubSub.Topic); err != nil {
+			p.configService.GetLogger().ErrorContext(ctx, "impossible to use the new target topic", "topic", newConfig.TargetPubSub.Topic, "error", err)
+		}
+	})
+	return p, nil
+}
+
+// setPubSubTopic creates the PubSub client and the Topic object of the fully qualified topic name, and replaces the
+// current ones. The previous client is closed after the flush of the pending messages of the previous topic
+func (p *pubsubPublisher) setPubSubTopic(ctx context.Context, topic string) (err error) {
+	//Topic Split size must be 4. The check has been performed during the load config
+	topicSplit := strings.Split(topic, "/")
+
+	client, err := pubsub.NewClient(ctx, topicSplit[1], utils.GetPubSubClientOptions()...)
+	if err != nil {
+		p.configService.GetLogger().ErrorContext(ctx, "impossible to create the Pub/Sub client", "topic", topic, "error", err)
+		return
+	}
+
+	// The client is kept with its topic, to be closed when the topic changes
+	p.topicMu.Lock()
+	previousClient, previousTopic := p.pubsubClient, p.pubsubTopic
+	p.pubsubClient, p.pubsubTopic = client, client.Topic(topicSplit[3])
+	p.topicMu.Unlock()
+
+	if previousTopic != nil {
+		// Flush the pending messages of the previous topic before the release of its client connections
+		previousTopic.Stop()
+		if err := previousClient.Close(); err != nil {
+			p.configService.GetLogger().WarnContext(ctx, "impossible to close the Pub/Sub client of the previous topic", "error", err)
+		}
+	}
+	return
+}
+
+// getPubSubTopic returns the current Topic object
+func (p *pubsubPublisher) getPubSubTopic() *pubsub.Topic {
+	p.topicMu.RLock()
+	defer p.topicMu.RUnlock()
+	return p.pubsubTopic
+}
+
+// Target returns the fully qualified topic of the configuration
+func (p *pubsubPublisher) Target() string {
+	return p.configService.GetConfig().TargetPubSub.Topic
+}
+
+// Publish sends the PubSub message and waits for its message ID
+func (p *pubsubPublisher) Publish(ctx context.Context, data []byte, attributes map[string]string) (messageID string, err error) {
+	trace.SpanFromContext(ctx).SetAttributes(semconv.MessagingSystemGCPPubsub)
+	result := p.getPubSubTopic().Publish(ctx, &pubsub.Message{
+		Data:       data,
+		Attributes: attributes,
+	})
+	return result.Get(ctx)
+}
+
+// writerPublisher writes the messages as JSON lines, for the local development
+type writerPublisher struct {
+	target string
+	writer io.Writer
+	// mu serializes the writes and the message counter
+	mu            sync.Mutex
+	lastMessageID int
+}
+
+// writtenMessage is the JSON line of a message written by the writerPublisher
+type writtenMessage struct {
+	PublishTime time.Time         `json:"publishTime"`
+	MessageID   string            `json:"messageID"`
+	Attributes  map[string]string `json:"attributes"`
+	Data        json.RawMessage   `json:"data"`
+}
+
+// NewWriterPublisher creates the publisher which writes the messages as JSON lines on the standard output if the
+// target is StdoutTarget, else appends them to the target file. The message IDs are a counter of the instance.
+func NewWriterPublisher(target string) (publisher MessagePublisher, err error) {
+	if target == StdoutTarget {
+		return &writerPublisher{target: target, writer: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("impossible to open the target file %q: %s", target, err))
+	}
+	return &writerPublisher{target: target, writer: file}, nil
+}
+
+// Target returns StdoutTarget or the path of the target file
+func (w *writerPublisher) Target() string {
+	return w.target
+}
+
+// Publish writes the message as a JSON line
+func (w *writerPublisher) Publish(ctx context.Context, data []byte, attributes map[string]string) (messageID string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastMessageID++
+	messageID = fmt.Sprint(w.lastMessageID)
+	line, err := json.Marshal(writtenMessage{
+		PublishTime: time.Now(),
+		MessageID:   messageID,
+		Attributes:  attributes,
+		Data:        data,
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, err = w.writer.Write(append(line, '\n')); err != nil {
+		return "", err
+	}
+	return
+}
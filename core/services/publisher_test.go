@@ -0,0 +1,58 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriterPublisher_Publish(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "messages.jsonl")
+	publisher, err := NewWriterPublisher(target)
+	if err != nil {
+		t.Fatalf("NewWriterPublisher() error = %v", err)
+	}
+	if publisher.Target() != target {
+		t.Errorf("Target() = %v, want %v", publisher.Target(), target)
+	}
+
+	tests := []struct {
+		name          string
+		data          string
+		attributes    map[string]string
+		wantMessageID string
+	}{
+		{name: "first message", data: `{"eventID":"id1"}`, attributes: map[string]string{"serviceName": "test"}, wantMessageID: "1"},
+		{name: "replay", data: `{"eventID":"id1"}`, attributes: map[string]string{"serviceName": "test", "replay": "true"}, wantMessageID: "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageID, err := publisher.Publish(context.Background(), []byte(tt.data), tt.attributes)
+			if err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			if messageID != tt.wantMessageID {
+				t.Errorf("Publish() = %v, want %v", messageID, tt.wantMessageID)
+			}
+		})
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		t.Fatalf("impossible to open the target: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for i := 0; scanner.Scan(); i++ {
+		message := writtenMessage{}
+		if err = json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		if message.MessageID != tests[i].wantMessageID || string(message.Data) != tests[i].data || message.Attributes["replay"] != tests[i].attributes["replay"] {
+			t.Errorf("line %d = %s, want the message %v", i, scanner.Text(), tests[i])
+		}
+	}
+}
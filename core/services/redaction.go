@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultDeniedHeaders are the headers always redacted, unless explicitly allowed
+var defaultDeniedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", APIKeyHeader}
+
+// jsonPathSegment matches one segment of a masked JSON path: a field name, "[index]" or "[*]"
+var jsonPathSegment = regexp.MustCompile(`^(?:\.?([^.\[\]]+)|\[(\d+|\*)\])`)
+
+// redactEvent applies the global and the endpoint redaction rules to the event headers, query parameters and content
+func (e *EventService) redactEvent(event models.Event) models.Event {
+	var endpointRedaction *models.Redaction
+	if endpoint := e.getEndpoint(event.EventKey); endpoint != nil {
+		endpointRedaction = endpoint.Redaction
+	}
+	redaction := mergeRedaction(e.configService.GetConfig().Redaction, endpointRedaction)
+
+	event.Headers = redactHeaders(event.Headers, redaction)
+	event.QueryParams = removeQueryParams(event.QueryParams, redaction.RemovedQueryParams)
+	if content, ok := event.Content.(string); ok && len(redaction.MaskedJSONPaths) > 0 {
+		event.Content = maskJSONContent(content, redaction.MaskedJSONPaths)
+	}
+	return event
+}
+
+// mergeRedaction combines the global and the endpoint rules. The endpoint allowed headers replace the global ones, the
+// other lists are added.
+func mergeRedaction(global *models.Redaction, endpoint *models.Redaction) (redaction models.Redaction) {
+	for _, rules := range []*models.Redaction{global, endpoint} {
+		if rules == nil {
+			continue
+		}
+		if rules.AllowedHeaders != nil {
+			redaction.AllowedHeaders = rules.AllowedHeaders
+		}
+		redaction.DeniedHeaders = append(redaction.DeniedHeaders, rules.DeniedHeaders...)
+		redaction.MaskedJSONPaths = append(redaction.MaskedJSONPaths, rules.MaskedJSONPaths...)
+		redaction.RemovedQueryParams = append(redaction.RemovedQueryParams, rules.RemovedQueryParams...)
+	}
+	return
+}
+
+// redactHeaders returns a copy of the headers with only the allowed headers, if any, and the denied headers values
+// replaced. The default denied headers are redacted unless they are explicitly allowed.
+func redactHeaders(headers map[string][]string, redaction models.Redaction) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	allowed := canonicalHeaderSet(redaction.AllowedHeaders)
+	denied := canonicalHeaderSet(redaction.DeniedHeaders)
+	for _, header := range defaultDeniedHeaders {
+		if !allowed[http.CanonicalHeaderKey(header)] {
+			denied[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+
+	redacted := make(map[string][]string, len(headers))
+	for name, values := range headers {
+		canonicalName := http.CanonicalHeaderKey(name)
+		if len(allowed) > 0 && !allowed[canonicalName] {
+			continue
+		}
+		if denied[canonicalName] {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = redactedValue
+			}
+			values = masked
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+// canonicalHeaderSet returns the set of the canonical header names
+func canonicalHeaderSet(headers []string) map[string]bool {
+	set := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		set[http.CanonicalHeaderKey(header)] = true
+	}
+	return set
+}
+
+// removeQueryParams returns a copy of the query parameters without the removed ones
+func removeQueryParams(queryParams map[string][]string, removed []string) map[string][]string {
+	if queryParams == nil || len(removed) == 0 {
+		return queryParams
+	}
+	kept := make(map[string][]string, len(queryParams))
+	for name, values := range queryParams {
+		if !containsString(removed, name) {
+			kept[name] = values
+		}
+	}
+	return kept
+}
+
+// maskJSONContent replaces the values of the JSON paths in the JSON content. The content is returned unchanged if it
+// is not JSON or if no path matches.
+func maskJSONContent(content string, paths []string) string {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	// Keep the numbers as is, without float conversion
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return content
+	}
+
+	masked := false
+	for _, path := range paths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			continue
+		}
+		var pathMasked bool
+		document, pathMasked = maskJSONPath(document, segments)
+		masked = masked || pathMasked
+	}
+	if !masked {
+		return content
+	}
+
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(document); err != nil {
+		return content
+	}
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// parseJSONPath splits the JSON path in field names, "[index]" and "[*]" segments. The "$" root is optional.
+func parseJSONPath(path string) (segments []string, err error) {
+	remaining := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if remaining == "" {
+		return nil, errors.New(fmt.Sprintf("the JSON path %q is empty", path))
+	}
+	for remaining != "" {
+		match := jsonPathSegment.FindStringSubmatch(remaining)
+		if match == nil {
+			return nil, errors.New(fmt.Sprintf("the JSON path %q is invalid at %q", path, remaining))
+		}
+		if match[1] != "" {
+			segments = append(segments, match[1])
+		} else {
+			segments = append(segments, "["+match[2]+"]")
+		}
+		remaining = remaining[len(match[0]):]
+	}
+	return
+}
+
+// maskJSONPath replaces the values at the path segments in the node, and returns the node and whether a value has
+// been replaced
+func maskJSONPath(node interface{}, segments []string) (interface{}, bool) {
+	if len(segments) == 0 {
+		return redactedValue, true
+	}
+	segment := segments[0]
+
+	switch value := node.(type) {
+	case map[string]interface{}:
+		child, found := value[segment]
+		if !found {
+			return node, false
+		}
+		var masked bool
+		value[segment], masked = maskJSONPath(child, segments[1:])
+		return value, masked
+	case []interface{}:
+		if segment == "[*]" {
+			maskedAny := false
+			for i := range value {
+				var masked bool
+				value[i], masked = maskJSONPath(value[i], segments[1:])
+				maskedAny = maskedAny || masked
+			}
+			return value, maskedAny
+		}
+		if strings.HasPrefix(segment, "[") {
+			index, err := strconv.Atoi(strings.Trim(segment, "[]"))
+			if err != nil || index >= len(value) {
+				return node, false
+			}
+			var masked bool
+			value[index], masked = maskJSONPath(value[index], segments[1:])
+			return value, masked
+		}
+	}
+	return node, false
+}
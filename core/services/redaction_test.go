@@ -0,0 +1,152 @@
+package services
+
+import (
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"reflect"
+	"testing"
+)
+
+func Test_redactHeaders(t *testing.T) {
+	headers := map[string][]string{
+		"Authorization": {"Bearer token"},
+		"Cookie":        {"session=1", "user=2"},
+		"Content-Type":  {"application/json"},
+		"X-Request-Id":  {"1234"},
+	}
+
+	tests := []struct {
+		name      string
+		redaction models.Redaction
+		want      map[string][]string
+	}{
+		{
+			name: "default denied headers",
+			want: map[string][]string{
+				"Authorization": {"REDACTED"},
+				"Cookie":        {"REDACTED", "REDACTED"},
+				"Content-Type":  {"application/json"},
+				"X-Request-Id":  {"1234"},
+			},
+		},
+		{
+			name:      "denied headers",
+			redaction: models.Redaction{DeniedHeaders: []string{"x-request-id"}},
+			want: map[string][]string{
+				"Authorization": {"REDACTED"},
+				"Cookie":        {"REDACTED", "REDACTED"},
+				"Content-Type":  {"application/json"},
+				"X-Request-Id":  {"REDACTED"},
+			},
+		},
+		{
+			name:      "allowed headers",
+			redaction: models.Redaction{AllowedHeaders: []string{"content-type", "Authorization"}},
+			want: map[string][]string{
+				"Authorization": {"Bearer token"},
+				"Content-Type":  {"application/json"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactHeaders(headers, tt.redaction); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redactHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if headers["Authorization"][0] != "Bearer token" {
+		t.Errorf("redactHeaders() must not change the headers")
+	}
+}
+
+func Test_maskJSONContent(t *testing.T) {
+	content := `{"user":{"email":"a@b.c","id":12345678901234567890},"cards":[{"number":"4242","brand":"visa"},{"number":"5555"}],"note":"<b>"}`
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{
+			name:  "nested field",
+			paths: []string{"$.user.email"},
+			want:  `{"cards":[{"brand":"visa","number":"4242"},{"number":"5555"}],"note":"<b>","user":{"email":"REDACTED","id":12345678901234567890}}`,
+		},
+		{
+			name:  "wildcard",
+			paths: []string{"cards[*].number"},
+			want:  `{"cards":[{"brand":"visa","number":"REDACTED"},{"number":"REDACTED"}],"note":"<b>","user":{"email":"a@b.c","id":12345678901234567890}}`,
+		},
+		{
+			name:  "index and object",
+			paths: []string{"cards[1]", "user"},
+			want:  `{"cards":[{"brand":"visa","number":"4242"},"REDACTED"],"note":"<b>","user":"REDACTED"}`,
+		},
+		{
+			name:  "no match",
+			paths: []string{"user.phone", "cards[5].number", "note.value"},
+			want:  content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maskJSONContent(content, tt.paths); got != tt.want {
+				t.Errorf("maskJSONContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := maskJSONContent("user=a@b.c", []string{"user"}); got != "user=a@b.c" {
+		t.Errorf("maskJSONContent() must not change a non JSON content, got %v", got)
+	}
+}
+
+func Test_parseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{path: "$.user.email", want: []string{"user", "email"}},
+		{path: "cards[*].number", want: []string{"cards", "[*]", "number"}},
+		{path: "$[0].id", want: []string{"[0]", "id"}},
+		{path: "$", wantErr: true},
+		{path: "cards[a]", wantErr: true},
+		{path: "user..email", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parseJSONPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJSONPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventService_redactEvent(t *testing.T) {
+	config := generateValidConfig()
+	config.Redaction = &models.Redaction{RemovedQueryParams: []string{"token"}, MaskedJSONPaths: []string{"password"}}
+	config.Endpoints[0].Redaction = &models.Redaction{RemovedQueryParams: []string{"signature"}, AllowedHeaders: []string{"Content-Type"}}
+	e := &EventService{configService: &ConfigService{eventSyncConfig: config}}
+
+	event := e.redactEvent(models.Event{
+		EventKey:    config.Endpoints[0].EventKey,
+		Headers:     map[string][]string{"Content-Type": {"application/json"}, "Authorization": {"Bearer token"}},
+		QueryParams: map[string][]string{"token": {"1"}, "signature": {"2"}, "page": {"3"}},
+		Content:     `{"login":"user","password":"secret"}`,
+	})
+
+	if want := map[string][]string{"Content-Type": {"application/json"}}; !reflect.DeepEqual(event.Headers, want) {
+		t.Errorf("redactEvent() headers = %v, want %v", event.Headers, want)
+	}
+	if want := map[string][]string{"page": {"3"}}; !reflect.DeepEqual(event.QueryParams, want) {
+		t.Errorf("redactEvent() queryParams = %v, want %v", event.QueryParams, want)
+	}
+	if want := `{"login":"user","password":"REDACTED"}`; event.Content != want {
+		t.Errorf("redactEvent() content = %v, want %v", event.Content, want)
+	}
+}
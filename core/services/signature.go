@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSignatureTolerance is the default maximal difference between the signature timestamp and the reception date
+const defaultSignatureTolerance = 300 * time.Second
+
+const (
+	githubSignatureHeader = "X-Hub-Signature-256"
+	githubSignaturePrefix = "sha256="
+	stripeSignatureHeader = "Stripe-Signature"
+)
+
+// ErrInvalidSignature is returned when the event signature is missing, invalid or expired
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// VerifySignature verifies the signature of the event body and headers, if the endpoint of the eventKey defines a
+// verification. The failures are counted per eventKey, logged with the request context, and ErrInvalidSignature
+// (wrapped) is returned.
+func (e *EventService) VerifySignature(ctx context.Context, eventKey string, headers http.Header, body []byte) (err error) {
+	endpoint := e.getEndpoint(eventKey)
+	if endpoint == nil || endpoint.Verification == nil {
+		return nil
+	}
+
+	err = verifySignature(ctx, endpoint.Verification, headers, body, e.Now(), e.configService.GetLogger())
+	if err != nil {
+		e.metrics.IncEventsRejected(eventKey, RejectionInvalidSignature)
+		e.configService.GetLogger().WarnContext(ctx, "event rejected by the signature verification", "eventKey", eventKey, "error", err)
+	}
+	return
+}
+
+// verifySignature verifies the signature of the body and headers according to the verification, at the date now. The
+// configuration issues are logged with the logger
+func verifySignature(ctx context.Context, verification *models.Verification, headers http.Header, body []byte, now time.Time, logger *slog.Logger) (err error) {
+	secret, err := getSignatureSecret(verification)
+	if err != nil {
+		// A configuration issue, not a forged event. It is logged and the event rejected
+		logger.ErrorContext(ctx, "impossible to get the signature secret", "error", err)
+		return fmt.Errorf("%w: the secret is not available", ErrInvalidSignature)
+	}
+
+	switch verification.Type {
+	case models.VerificationTypeGithub:
+		signature := strings.TrimPrefix(headers.Get(githubSignatureHeader), githubSignaturePrefix)
+		return checkHmacSignatures(secret, body, []string{signature}, models.SignatureEncodingHex)
+
+	case models.VerificationTypeStripe:
+		// Format: t=<timestamp>,v1=<signature>[,v1=<signature>...]
+		timestamp := ""
+		signatures := make([]string, 0)
+		for _, part := range strings.Split(headers.Get(stripeSignatureHeader), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch key {
+			case "t":
+				timestamp = value
+			case "v1":
+				signatures = append(signatures, value)
+			}
+		}
+		if err = checkSignatureTimestamp(timestamp, verification.ToleranceSeconds, now); err != nil {
+			return
+		}
+		return checkHmacSignatures(secret, signedPayload(timestamp, body), signatures, models.SignatureEncodingHex)
+
+	default:
+		signature := headers.Get(verification.Header)
+		if verification.Prefix != "" && !strings.HasPrefix(signature, verification.Prefix) {
+			return fmt.Errorf("%w: the %s header must start with %q", ErrInvalidSignature, verification.Header, verification.Prefix)
+		}
+		payload := body
+		if verification.TimestampHeader != "" {
+			timestamp := headers.Get(verification.TimestampHeader)
+			if err = checkSignatureTimestamp(timestamp, verification.ToleranceSeconds, now); err != nil {
+				return
+			}
+			payload = signedPayload(timestamp, body)
+		}
+		return checkHmacSignatures(secret, payload, []string{strings.TrimPrefix(signature, verification.Prefix)}, verification.Encoding)
+	}
+}
+
+// getSignatureSecret returns the secret of the verification, from the configuration or from the secret file
+func getSignatureSecret(verification *models.Verification) (secret []byte, err error) {
+	if verification.SecretFile == "" {
+		return []byte(verification.Secret), nil
+	}
+	secret, err = os.ReadFile(verification.SecretFile)
+	if err != nil {
+		return
+	}
+	// The files often end with a line break, never part of the secret
+	return []byte(strings.TrimRight(string(secret), "\r\n")), nil
+}
+
+// signedPayload returns the "<timestamp>.<body>" payload of the timestamped signatures
+func signedPayload(timestamp string, body []byte) []byte {
+	return append([]byte(timestamp+"."), body...)
+}
+
+// checkSignatureTimestamp checks that the Unix timestamp is within the tolerance (default 300 seconds) of now
+func checkSignatureTimestamp(timestamp string, toleranceSeconds int64, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or invalid timestamp %q", ErrInvalidSignature, timestamp)
+	}
+	tolerance := defaultSignatureTolerance
+	if toleranceSeconds > 0 {
+		tolerance = time.Duration(toleranceSeconds) * time.Second
+	}
+	if delta := now.Sub(time.Unix(seconds, 0)); delta > tolerance || delta < -tolerance {
+		return fmt.Errorf("%w: the timestamp %s is outside the tolerance of %s", ErrInvalidSignature, timestamp, tolerance)
+	}
+	return nil
+}
+
+// checkHmacSignatures checks that one of the encoded signatures is the HMAC-SHA256 of the payload with the secret
+func checkHmacSignatures(secret []byte, payload []byte, signatures []string, encoding models.SignatureEncodingType) error {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		var decoded []byte
+		var err error
+		if encoding == models.SignatureEncodingBase64 {
+			decoded, err = base64.StdEncoding.DecodeString(signature)
+		} else {
+			decoded, err = hex.DecodeString(signature)
+		}
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no valid signature", ErrInvalidSignature)
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sign(secret string, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func Test_verifySignature(t *testing.T) {
+	body := `{"action":"opened"}`
+	timestamp := "1700000000"
+	signatureDate := time.Unix(1700000000, 0)
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("fileSecret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		verification *models.Verification
+		headers      map[string]string
+		now          time.Time
+		wantErr      bool
+	}{
+		{
+			name:         "github ok",
+			verification: &models.Verification{Type: models.VerificationTypeGithub, Secret: "secret"},
+			headers:      map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(sign("secret", body))},
+		},
+		{
+			name:         "github wrong secret",
+			verification: &models.Verification{Type: models.VerificationTypeGithub, Secret: "secret"},
+			headers:      map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(sign("other", body))},
+			wantErr:      true,
+		},
+		{
+			name:         "github missing header",
+			verification: &models.Verification{Type: models.VerificationTypeGithub, Secret: "secret"},
+			wantErr:      true,
+		},
+		{
+			name:         "stripe ok with several signatures",
+			verification: &models.Verification{Type: models.VerificationTypeStripe, Secret: "whsec_123"},
+			headers: map[string]string{"Stripe-Signature": "t=" + timestamp + ",v1=" + hex.EncodeToString(sign("old", timestamp+"."+body)) +
+				",v1=" + hex.EncodeToString(sign("whsec_123", timestamp+"."+body))},
+			now: signatureDate.Add(time.Minute),
+		},
+		{
+			name:         "stripe expired",
+			verification: &models.Verification{Type: models.VerificationTypeStripe, Secret: "whsec_123"},
+			headers:      map[string]string{"Stripe-Signature": "t=" + timestamp + ",v1=" + hex.EncodeToString(sign("whsec_123", timestamp+"."+body))},
+			now:          signatureDate.Add(10 * time.Minute),
+			wantErr:      true,
+		},
+		{
+			name:         "stripe custom tolerance",
+			verification: &models.Verification{Type: models.VerificationTypeStripe, Secret: "whsec_123", ToleranceSeconds: 3600},
+			headers:      map[string]string{"Stripe-Signature": "t=" + timestamp + ",v1=" + hex.EncodeToString(sign("whsec_123", timestamp+"."+body))},
+			now:          signatureDate.Add(-10 * time.Minute),
+		},
+		{
+			name:         "generic base64 with prefix and secret file",
+			verification: &models.Verification{Type: models.VerificationTypeHmacSha256, Header: "X-Signature", Prefix: "v1,", Encoding: models.SignatureEncodingBase64, SecretFile: secretFile},
+			headers:      map[string]string{"X-Signature": "v1," + base64.StdEncoding.EncodeToString(sign("fileSecret", body))},
+		},
+		{
+			name:         "generic missing prefix",
+			verification: &models.Verification{Type: models.VerificationTypeHmacSha256, Header: "X-Signature", Prefix: "v1,", Encoding: models.SignatureEncodingBase64, Secret: "secret"},
+			headers:      map[string]string{"X-Signature": base64.StdEncoding.EncodeToString(sign("secret", body))},
+			wantErr:      true,
+		},
+		{
+			name:         "generic timestamped",
+			verification: &models.Verification{Type: models.VerificationTypeHmacSha256, Header: "X-Signature", TimestampHeader: "X-Timestamp", Secret: "secret"},
+			headers:      map[string]string{"X-Signature": hex.EncodeToString(sign("secret", timestamp+"."+body)), "X-Timestamp": timestamp},
+			now:          signatureDate,
+		},
+		{
+			name:         "generic timestamp replaced",
+			verification: &models.Verification{Type: models.VerificationTypeHmacSha256, Header: "X-Signature", TimestampHeader: "X-Timestamp", Secret: "secret"},
+			headers:      map[string]string{"X-Signature": hex.EncodeToString(sign("secret", timestamp+"."+body)), "X-Timestamp": "1700000001"},
+			now:          signatureDate,
+			wantErr:      true,
+		},
+		{
+			name:         "missing secret file",
+			verification: &models.Verification{Type: models.VerificationTypeGithub, SecretFile: filepath.Join(t.TempDir(), "missing")},
+			headers:      map[string]string{"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(sign("", body))},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			for name, value := range tt.headers {
+				headers.Set(name, value)
+			}
+			err := verifySignature(context.Background(), tt.verification, headers, []byte(body), tt.now, slog.Default())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("verifySignature() error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestEventService_VerifySignature(t *testing.T) {
+	config := generateValidConfig()
+	config.Endpoints[1].Verification = &models.Verification{Type: models.VerificationTypeGithub, Secret: "secret"}
+	e := &EventService{
+		configService: &ConfigService{eventSyncConfig: config},
+		metrics:       NewMetrics(nil),
+	}
+
+	if err := e.VerifySignature(context.Background(), "entry1", http.Header{}, nil); err != nil {
+		t.Errorf("VerifySignature() without verification error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := e.VerifySignature(context.Background(), "entry2", http.Header{}, nil); err == nil {
+			t.Errorf("VerifySignature() without signature must fail")
+		}
+	}
+	if got := e.metrics.GetEventsRejected("entry2", RejectionInvalidSignature); got != 2 {
+		t.Errorf("GetSignatureFailures() = %d, want 2", got)
+	}
+}
+
+func TestConfigService_checkConfigVerification(t *testing.T) {
+	tests := []struct {
+		name         string
+		verification *models.Verification
+		wantErr      bool
+		wantEncoding models.SignatureEncodingType
+	}{
+		{
+			name:         "generic with default encoding",
+			verification: &models.Verification{Type: models.VerificationTypeHmacSha256, Header: "X-Signature", Secret: "secret"},
+			wantEncoding: models.SignatureEncodingHex,
+		},
+		{
+			name:         "github",
+			verification: &models.Verification{Type: models.VerificationTypeGithub, SecretFile: "/secrets/github"},
+		},
+		{
+			name:         "generic without header",
+			verification: &models.Verification{Type: models.VerificationTypeHmacSha256, Secret: "secret"},
+			wantErr:      true,
+		},
+		{
+			name:         "invalid encoding",
+			verification: &models.Verification{Type: models.VerificationTypeHmacSha256, Header: "X-Signature", Encoding: "base32", Secret: "secret"},
+			wantErr:      true,
+		},
+		{
+			name:         "preset header override",
+			verification: &models.Verification{Type: models.VerificationTypeStripe, Header: "X-Signature", Secret: "secret"},
+			wantErr:      true,
+		},
+		{
+			name:         "invalid type",
+			verification: &models.Verification{Type: "md5", Secret: "secret"},
+			wantErr:      true,
+		},
+		{
+			name:         "secret and secretFile",
+			verification: &models.Verification{Type: models.VerificationTypeGithub, Secret: "secret", SecretFile: "/secrets/github"},
+			wantErr:      true,
+		},
+		{
+			name:         "no secret",
+			verification: &models.Verification{Type: models.VerificationTypeGithub},
+			wantErr:      true,
+		},
+		{
+			name:         "undefined environment variable secret",
+			verification: &models.Verification{Type: models.VerificationTypeGithub, Secret: "${TEST_UNDEFINED}"},
+			wantErr:      true,
+		},
+		{
+			name:         "negative tolerance",
+			verification: &models.Verification{Type: models.VerificationTypeStripe, Secret: "secret", ToleranceSeconds: -1},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ConfigService{eventSyncConfig: generateValidConfig()}
+			endpoint := &models.Endpoint{EventKey: "entry1", Verification: tt.verification}
+			if got, _ := c.checkConfigVerification("", "", 0, endpoint); (got != "") != tt.wantErr {
+				t.Errorf("checkConfigVerification() got = %v, wantErr %v", got, tt.wantErr)
+			}
+			if tt.wantEncoding != "" && tt.verification.Encoding != tt.wantEncoding {
+				t.Errorf("checkConfigVerification() encoding = %q, want %q", tt.verification.Encoding, tt.wantEncoding)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"time"
+)
+
+// ErrHistoryAlreadyExists is returned when a trigger history record already exists for the EventID
+var ErrHistoryAlreadyExists = errors.New("trigger history already exists")
+
+// EventStore persists the events, the trigger history and the audit of the administration operations. The
+// collections are named after the config serviceName (see HistoryCollectionSuffix and AuditCollectionSuffix). The
+// events read from the store have their FirestoreDocumentID and AlreadyExported values.
+type EventStore interface {
+	// AddEvent stores the event in the collection and returns the ID of the created document
+	AddEvent(ctx context.Context, collection string, event models.Event) (documentID string, err error)
+	// GetEvent returns the event of the document ID. ErrEventNotFound is returned if it doesn't exist
+	GetEvent(ctx context.Context, collection string, documentID string) (event models.Event, err error)
+	// ListEvents returns the events that match the filter, in no particular order
+	ListEvents(ctx context.Context, collection string, filter EventStoreFilter) (events []models.Event, err error)
+	// CountEvents returns the number of events that match the filter, without reading the events
+	CountEvents(ctx context.Context, collection string, filter EventStoreFilter) (count int, err error)
+	// ListEventIDs returns the document IDs of the events that match the filter, without reading the events
+	ListEventIDs(ctx context.Context, collection string, filter EventStoreFilter) (documentIDs []string, err error)
+	// ScanEvents calls the scan function with the events that match the filter, the oldest first and by document ID
+	// for the same date, after the cursor if any, until the limit or until the scan function returns false
+	ScanEvents(ctx context.Context, collection string, filter EventStoreFilter, after *EventCursor, limit int, scan func(event models.Event) bool) (err error)
+	// GetExistingEventIDs splits the document IDs into the existing events, grouped by eventKey, and the missing ones
+	GetExistingEventIDs(ctx context.Context, collection string, documentIDs []string) (existing map[string][]string, notFound []string, err error)
+	// UpdateEvents applies the update to all the events of the document IDs, atomically
+	UpdateEvents(ctx context.Context, collection string, documentIDs []string, update EventUpdate) (err error)
+	// AddAuditRecord stores the record of an administration operation
+	AddAuditRecord(ctx context.Context, collection string, auditRecord models.AuditRecord) (err error)
+
+	// CreateHistory stores the trigger history record, with its EventID as ID. ErrHistoryAlreadyExists is returned if
+	// a record already exists with the same EventID
+	CreateHistory(ctx context.Context, collection string, history models.TriggerHistory) (err error)
+	// AddHistoryDelivery appends the delivery to the trigger history record of the eventID and sets its status
+	AddHistoryDelivery(ctx context.Context, collection string, eventID string, delivery models.Delivery) (err error)
+	// GetHistory returns the trigger history record of the eventID. ErrHistoryNotFound is returned if it doesn't exist
+	GetHistory(ctx context.Context, collection string, eventID string) (history *models.TriggerHistory, err error)
+	// ListHistoriesOfEvent returns the EventID and Date of the trigger history records which include the event of the
+	// document ID, in no particular order
+	ListHistoriesOfEvent(ctx context.Context, collection string, documentID string) (histories []models.TriggerHistory, err error)
+	// ListHistory returns at most limit trigger history records that match the filter, the most recent first
+	ListHistory(ctx context.Context, collection string, filter models.HistoryFilter, limit int) (histories []models.TriggerHistory, err error)
+}
+
+// EventStoreFilter selects the stored events. The empty fields don't filter the events
+type EventStoreFilter struct {
+	// EventKeys are the accepted eventKeys
+	EventKeys []string
+	// StartDate is the beginning of the time range, excluded unless IncludeStartDate
+	StartDate        *time.Time
+	IncludeStartDate bool
+	// EndDate is the end (included) of the time range
+	EndDate *time.Time
+	// Exported is the accepted exported state
+	Exported *bool
+}
+
+// match checks if the event is selected by the filter
+func (f EventStoreFilter) match(event models.Event) bool {
+	if len(f.EventKeys) > 0 && !containsString(f.EventKeys, event.EventKey) {
+		return false
+	}
+	if f.StartDate != nil && (event.Datetime.Before(*f.StartDate) || (!f.IncludeStartDate && event.Datetime.Equal(*f.StartDate))) {
+		return false
+	}
+	if f.EndDate != nil && event.Datetime.After(*f.EndDate) {
+		return false
+	}
+	return f.Exported == nil || *f.Exported == event.AlreadyExported
+}
+
+// EventUpdate is the change to apply to stored events: deletion, or new exported state and/or eventKey
+type EventUpdate struct {
+	// Delete removes the events, the other fields are ignored
+	Delete bool
+	// AlreadyExported is the new exported state, unchanged if nil
+	AlreadyExported *bool
+	// EventKey is the new eventKey, unchanged if empty
+	EventKey string
+}
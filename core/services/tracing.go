@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"github.com/guillaumeblaquiere/eventsync/core/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"sort"
+)
+
+// startSpan starts a span of the service operation, child of the span of the context
+func startSpan(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(utils.TracerName).Start(ctx, name, options...)
+}
+
+// startFirestoreSpan starts a client span of the Firestore operation on the collection
+func startFirestoreSpan(ctx context.Context, operation string, collection string) (context.Context, trace.Span) {
+	return startSpan(ctx, "firestore "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemKey.String("firestore"), semconv.DBOperationName(operation),
+			semconv.DBCollectionName(collection)))
+}
+
+// endSpan records the error of the operation on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// eventLinks returns the links to the ingestion spans of the events, from their traceparent, sorted by eventKey. The
+// links are labelled with the eventKey and the documentID of the event. The events without valid traceparent and the
+// duplicated spans are ignored.
+func eventLinks(events map[string][]models.Event) (links []trace.Link) {
+	eventKeys := make([]string, 0, len(events))
+	for eventKey := range events {
+		eventKeys = append(eventKeys, eventKey)
+	}
+	sort.Strings(eventKeys)
+
+	linked := make(map[string]bool)
+	for _, eventKey := range eventKeys {
+		for _, event := range events[eventKey] {
+			spanContext, ok := utils.ParseTraceParent(event.TraceParent)
+			if !ok || linked[event.TraceParent] {
+				continue
+			}
+			linked[event.TraceParent] = true
+			links = append(links, trace.Link{SpanContext: spanContext, Attributes: []attribute.KeyValue{
+				attribute.String("eventsync.event_key", eventKey),
+				attribute.String("eventsync.document_id", event.FirestoreDocumentID),
+			}})
+		}
+	}
+	return
+}
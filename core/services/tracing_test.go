@@ -0,0 +1,63 @@
+package services
+
+import (
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"testing"
+)
+
+func TestEventLinks(t *testing.T) {
+	const (
+		traceParent1 = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+		traceParent2 = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	)
+
+	tests := []struct {
+		name   string
+		events map[string][]models.Event
+		want   []string
+	}{
+		{
+			name: "one link per event, sorted by eventKey",
+			events: map[string][]models.Event{
+				"entry2": {{TraceParent: traceParent2, FirestoreDocumentID: "doc2"}},
+				"entry1": {{TraceParent: traceParent1, FirestoreDocumentID: "doc1"}},
+			},
+			want: []string{"b7ad6b7169203331", "00f067aa0ba902b7"},
+		},
+		{
+			name: "duplicated span linked once",
+			events: map[string][]models.Event{
+				"entry1": {{TraceParent: traceParent1}, {TraceParent: traceParent1}},
+			},
+			want: []string{"b7ad6b7169203331"},
+		},
+		{
+			name: "events without valid traceparent ignored",
+			events: map[string][]models.Event{
+				"entry1": {{}, {TraceParent: "invalid"}, {TraceParent: traceParent2}},
+			},
+			want: []string{"00f067aa0ba902b7"},
+		},
+		{
+			name:   "no event",
+			events: map[string][]models.Event{},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := eventLinks(tt.events)
+			if len(got) != len(tt.want) {
+				t.Fatalf("eventLinks() = %d links, want %d", len(got), len(tt.want))
+			}
+			for i, link := range got {
+				if spanID := link.SpanContext.SpanID().String(); spanID != tt.want[i] {
+					t.Errorf("eventLinks()[%d] spanID = %v, want %v", i, spanID, tt.want[i])
+				}
+				if !link.SpanContext.IsRemote() {
+					t.Errorf("eventLinks()[%d] is not a remote span", i)
+				}
+			}
+		})
+	}
+}
@@ -1,96 +1,141 @@
 package services
 
 import (
-	"cloud.google.com/go/pubsub"
 	"context"
-	"crypto/md5"
+	"crypto/sha256"
 	"encoding/json"
-	"eventsync/models"
 	"fmt"
-	"strings"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"sort"
 	"time"
 )
 
+// EventIDVersion is the version of the EventID generation scheme, exported in the event sync message idVersion field.
+// Version 1 (legacy) was the MD5 hash of the concatenated Firestore document IDs.
+const EventIDVersion = 2
+
 // TriggerService is in charge to submit the events when a trigger need to be performed.
 type TriggerService struct {
-	configService *ConfigService
-	eventService  *EventService
-	pubsubTopic   *pubsub.Topic
+	configService  *ConfigService
+	eventService   *EventService
+	historyService *HistoryService
+	publisher      MessagePublisher
 }
 
-// NewTriggerService creates a TriggerService instance. The context is required to create a PubSub client and a Topic
-// object to be able to send PubSub message when required.
-func NewTriggerService(ctx context.Context, configService *ConfigService, eventService *EventService) (triggerService *TriggerService, err error) {
-	triggerService = &TriggerService{
-		configService: configService,
-		eventService:  eventService,
+// NewTriggerService creates a TriggerService instance. The event sync messages are delivered by the publisher (see
+// NewPubSubPublisher and NewWriterPublisher). Each generated event sync message is recorded in the historyService.
+func NewTriggerService(configService *ConfigService, eventService *EventService, historyService *HistoryService, publisher MessagePublisher) *TriggerService {
+	return &TriggerService{
+		configService:  configService,
+		eventService:   eventService,
+		historyService: historyService,
+		publisher:      publisher,
 	}
+}
+
+// now returns the current time of the service clock (see ConfigService.SetClock)
+func (t *TriggerService) now() time.Time {
+	return t.configService.GetClock().Now()
+}
+
+// TriggerEvent generates a models.EventGenerated object based on the events and send it through the publisher of the
+// target. The generated event and its delivery are recorded in the trigger history, whatever the target.
+// The events are flagged as already exported after the trigger, unless keepEventAfterTrigger is true. The generated
+// event is returned.
+func (t *TriggerService) TriggerEvent(ctx context.Context, events map[string][]models.Event, keepEventAfterTrigger bool) (eventGenerated models.EventGenerated, err error) {
+	// The trigger span links to the reception spans of all the events of the message
+	ctx, span := startSpan(ctx, "TriggerEvent", trace.WithLinks(eventLinks(events)...))
+	defer func() { endSpan(span, err) }()
 
-	//TODO create the firestoreClient only if the PubSub trigger type is used
+	eventGenerated = t.createEventGenerated(events)
+	span.SetAttributes(attribute.String("eventsync.event_id", eventGenerated.EventID))
 
-	//Topic Split size must be 4. The check has been performed during the load config
-	topicSplit := strings.Split(configService.GetConfig().TargetPubSub.Topic, "/")
+	var messageID string
+	messageID, err = t.triggerPubSub(ctx, &eventGenerated, false)
 
-	client, err := pubsub.NewClient(ctx, topicSplit[1])
+	// The history failure must not fail the trigger, the message could have been sent
+	delivery := newDelivery(t.publisher.Target(), t.now(), messageID, err, false)
+	if t.historyService != nil {
+		t.historyService.RecordTrigger(ctx, eventGenerated, events, delivery)
+	}
 	if err != nil {
-		fmt.Printf("pubsub new firestoreClient error:%s\n", err)
-		return nil, err
+		return
+	}
+
+	t.configService.GetLogger().DebugContext(ctx, "event sync message triggered", "eventID", eventGenerated.EventID, "keepEventAfterTrigger", keepEventAfterTrigger)
+	//Cleanup the context
+	if !keepEventAfterTrigger {
+		t.eventService.ResetEvents(ctx, events)
 	}
-	// Only the topic is kept in memory. The PubSub client is not required
-	triggerService.pubsubTopic = client.Topic(topicSplit[3])
 
 	return
 }
 
-// TriggerEvent generates a models.EventGenerated object based on the events and send it through the configured
-// trigger channels (only PubSub for now)
-func (t *TriggerService) TriggerEvent(ctx context.Context, events map[string][]models.Event) (err error) {
-
-	eventGenerated := t.createEventGenerated(events)
-
-	// Send it according to the target configuration
-	if t.configService.GetConfig().TargetPubSub != nil {
-		err = t.triggerPubSub(ctx, &eventGenerated)
-		if err != nil {
-			return err
-		}
+// ReplayEvent re-publishes the event sync message of the eventID, stored in the trigger history, to the configured
+// targets. The delivery is added to the trigger history and returned. ErrHistoryUnavailable is returned if the service
+// has no trigger history.
+func (t *TriggerService) ReplayEvent(ctx context.Context, eventID string) (delivery models.Delivery, err error) {
+	if t.historyService == nil {
+		return delivery, ErrHistoryUnavailable
 	}
-
-	fmt.Printf("keep the events after the trigger set to %v\n", t.configService.GetConfig().Trigger.KeepEventAfterTrigger)
-	//Cleanup the context
-	if !t.configService.GetConfig().Trigger.KeepEventAfterTrigger {
-		t.eventService.ResetEvents(ctx, events)
-	} else {
-		fmt.Printf("no clean-up to do\n")
+	history, err := t.historyService.GetHistory(ctx, eventID)
+	if err != nil {
+		return
+	}
+	eventGenerated, err := t.historyService.GetEventGenerated(ctx, history)
+	if err != nil {
+		return
 	}
 
+	messageID, err := t.triggerPubSub(ctx, &eventGenerated, true)
+	delivery = newDelivery(t.publisher.Target(), t.now(), messageID, err, true)
+	if historyErr := t.historyService.AddDelivery(ctx, eventID, delivery); historyErr != nil && err == nil {
+		err = historyErr
+	}
 	return
 }
 
 // triggerPubSub effectively format the eventGenerated parameter into a PubSub message and submit it to the topic.
-// The serviceName is added as attribute
-func (t *TriggerService) triggerPubSub(ctx context.Context, eventGenerated *models.EventGenerated) (err error) {
+// The serviceName is added as attribute, the replay flag if the message is re-published, and the trace context of the
+// publication. The PubSub message ID is returned.
+func (t *TriggerService) triggerPubSub(ctx context.Context, eventGenerated *models.EventGenerated, replay bool) (messageID string, err error) {
+	target := t.publisher.Target()
+	ctx, span := startSpan(ctx, "publish "+target,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconv.MessagingOperationTypePublish, semconv.MessagingDestinationName(target), attribute.String("eventsync.event_id", eventGenerated.EventID),
+			attribute.Bool("eventsync.replay", replay)))
+	defer func() { endSpan(span, err) }()
 
 	data, err := json.Marshal(eventGenerated)
 	if err != nil {
-		fmt.Printf("impossible to generate the PubSub message with error:%s\n", err)
+		t.configService.GetLogger().ErrorContext(ctx, "impossible to generate the Pub/Sub message", "eventID", eventGenerated.EventID, "error", err)
 		return
 	}
 
-	fmt.Printf("content to send to PubSub: %s\n", string(data))
+	t.configService.GetLogger().DebugContext(ctx, "content to send to Pub/Sub", "eventID", eventGenerated.EventID, "content", string(data))
 
-	message := &pubsub.Message{
-		Data: data,
-		Attributes: map[string]string{
-			"serviceName": t.configService.GetConfig().ServiceName,
-		},
+	attributes := map[string]string{
+		"serviceName": t.configService.GetConfig().ServiceName,
 	}
+	if replay {
+		attributes["replay"] = "true"
+	}
+	// The consumers continue the trace with the traceparent attribute
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(attributes))
 
-	result := t.pubsubTopic.Publish(ctx, message)
-	if _, err = result.Get(ctx); err != nil {
-		fmt.Printf("impossible to publish the message %s with error:%s\n", string(data), err)
+	start := time.Now()
+	messageID, err = t.publisher.Publish(ctx, data, attributes)
+	t.eventService.Metrics().ObservePublish(time.Since(start), err, replay)
+	if err != nil {
+		t.configService.GetLogger().ErrorContext(ctx, "impossible to publish the event sync message", "eventID", eventGenerated.EventID, "error", err)
 	} else {
-		fmt.Printf("event sent to topic %s\n", t.configService.GetConfig().TargetPubSub.Topic)
+		span.SetAttributes(semconv.MessagingMessageID(messageID))
+		t.configService.GetLogger().InfoContext(ctx, "event sync message published", "eventID", eventGenerated.EventID, "target", target, "messageID", messageID)
 	}
 
 	return
@@ -99,18 +144,20 @@ func (t *TriggerService) triggerPubSub(ctx context.Context, eventGenerated *mode
 // createEventGenerated produces an eventGenerated structure based on the events in entry and the configuration
 // of the endpoints. Some metrics are extracted such as firstEventDate, LastEventDate, number of events.
 // Other configuration option are duplicated to help the consumer of the message to understand the context.
-// A unique EventID is generated based on the FirestoreIDs of the events included in the eventGenerated message. That
-// event help the consumer to deduplicate the messages, if any.
+// A unique EventID is generated based on the serviceName, the time window and the FirestoreIDs of the events included
+// in the eventGenerated message (see generateEventID). That event help the consumer to deduplicate the messages, if any.
 func (t *TriggerService) createEventGenerated(events map[string][]models.Event) (eventGenerated models.EventGenerated) {
 
 	eventGenerated = models.EventGenerated{
-		Date:        time.Now(),
+		Date:        t.now(),
 		Events:      make(map[string]*models.EventList, len(t.configService.GetConfig().Endpoints)),
 		ServiceName: t.configService.GetConfig().ServiceName,
 		TriggerTpe:  t.configService.GetConfig().Trigger.Type,
+		IDVersion:   EventIDVersion,
 	}
 
-	eventIds := ""
+	eventIds := make([]string, 0)
+	var windowStart, windowEnd *time.Time
 	for _, endpoint := range t.configService.GetConfig().Endpoints {
 		eventList := &models.EventList{
 			MinNbOfOccurrence: endpoint.MinNbOfOccurrence,
@@ -123,7 +170,7 @@ func (t *TriggerService) createEventGenerated(events map[string][]models.Event)
 
 			// If all the event are kept, aggregate all the IDs
 			if endpoint.EventToSend == models.EventToSendTypeAll {
-				eventIds += event.FirestoreDocumentID
+				eventIds = append(eventIds, event.FirestoreDocumentID)
 			}
 
 			if eventList.LastEventDate == nil || event.Datetime.After(*eventList.LastEventDate) {
@@ -147,18 +194,30 @@ func (t *TriggerService) createEventGenerated(events map[string][]models.Event)
 				eventList.Events = events[endpoint.EventKey]
 			case models.EventToSendTypeFirst:
 				eventList.Events = []models.Event{firstEvent}
-				eventIds += firstEvent.FirestoreDocumentID
+				eventIds = append(eventIds, firstEvent.FirestoreDocumentID)
 			case models.EventToSendTypeLast:
 				eventList.Events = []models.Event{lastEvent}
-				eventIds += lastEvent.FirestoreDocumentID
+				eventIds = append(eventIds, lastEvent.FirestoreDocumentID)
 			case models.EventToSendTypeBoundaries:
 				// If there is only one element, add only one, else add the boudaries
 				if firstEvent.FirestoreDocumentID == lastEvent.FirestoreDocumentID {
 					eventList.Events = []models.Event{firstEvent}
-					eventIds += firstEvent.FirestoreDocumentID
+					eventIds = append(eventIds, firstEvent.FirestoreDocumentID)
 				} else {
 					eventList.Events = []models.Event{firstEvent, lastEvent}
-					eventIds += firstEvent.FirestoreDocumentID + lastEvent.FirestoreDocumentID
+					eventIds = append(eventIds, firstEvent.FirestoreDocumentID, lastEvent.FirestoreDocumentID)
+				}
+			}
+
+			// The window spans the events included in the event sync message
+			for _, event := range eventList.Events {
+				if windowStart == nil || event.Datetime.Before(*windowStart) {
+					d := event.Datetime
+					windowStart = &d
+				}
+				if windowEnd == nil || event.Datetime.After(*windowEnd) {
+					d := event.Datetime
+					windowEnd = &d
 				}
 			}
 		}
@@ -166,10 +225,34 @@ func (t *TriggerService) createEventGenerated(events map[string][]models.Event)
 		eventGenerated.Events[endpoint.EventKey] = eventList
 	}
 
-	// EventID is generated with the MD5 hash of the string composed of event's firestoreID contains in event sync
-	// message generated
-	fmt.Println(eventIds)
-	eventGenerated.EventID = fmt.Sprintf("%x", md5.Sum([]byte(eventIds)))
+	// Without event, the window is the generation date to avoid a constant EventID
+	if windowStart == nil {
+		windowStart, windowEnd = &eventGenerated.Date, &eventGenerated.Date
+	}
+	eventGenerated.EventID = generateEventID(eventGenerated.ServiceName, *windowStart, *windowEnd, eventIds)
 
 	return
 }
+
+// generateEventID computes the SHA-256 hash of the EventIDVersion, the serviceName, the window boundaries and the
+// sorted Firestore document IDs. Each field is length-prefixed to prevent collisions between different splits of the
+// same characters, and the IDs are sorted to be independent of the events order.
+func generateEventID(serviceName string, windowStart time.Time, windowEnd time.Time, eventIds []string) string {
+	sortedIds := make([]string, len(eventIds))
+	copy(sortedIds, eventIds)
+	sort.Strings(sortedIds)
+
+	fields := []string{
+		fmt.Sprintf("v%d", EventIDVersion),
+		serviceName,
+		windowStart.UTC().Format(time.RFC3339Nano),
+		windowEnd.UTC().Format(time.RFC3339Nano),
+	}
+	fields = append(fields, sortedIds...)
+
+	hash := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(hash, "%d:%s", len(field), field)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
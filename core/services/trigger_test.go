@@ -1,7 +1,10 @@
 package services
 
 import (
-	"eventsync/models"
+	"context"
+	"errors"
+	"github.com/guillaumeblaquiere/eventsync/core/models"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -85,7 +88,8 @@ func TestTriggerService_createEventGenerated(t1 *testing.T) {
 				events: map[string][]models.Event{},
 			},
 			wantEventGenerated: models.EventGenerated{
-				EventID:     "d41d8cd98f00b204e9800998ecf8427e",
+				// No event, the EventID is namespaced by the window of the generation date
+				EventID:     "bc8dc0147c9f5c1bbb1b1fd0df7b24956424b1b73847af8cf548aa64c8c5899c",
 				Date:        now,
 				ServiceName: generateValidConfig().ServiceName,
 				TriggerTpe:  generateValidConfig().Trigger.Type,
@@ -112,7 +116,7 @@ func TestTriggerService_createEventGenerated(t1 *testing.T) {
 				events: generateEvents(),
 			},
 			wantEventGenerated: models.EventGenerated{
-				EventID:     "5ad5318b7a774c084bad1d649ac0eb90",
+				EventID:     "5417094ffbedab94a330f0f61debbfbaf43d10c0067e8f30dacd9aa74bc37b05",
 				Date:        now,
 				ServiceName: generateValidConfig().ServiceName,
 				TriggerTpe:  generateValidConfig().Trigger.Type,
@@ -135,7 +139,7 @@ func TestTriggerService_createEventGenerated(t1 *testing.T) {
 				events: generateEvents(),
 			},
 			wantEventGenerated: models.EventGenerated{
-				EventID:     "d24f5dca0b6a5633a2b53f6ff7d82299",
+				EventID:     "e414b7e8761e60aaedf16756d050b030c0b4792ae0fe142fc2c3b8e93808bdea",
 				Date:        now,
 				ServiceName: generateValidConfig().ServiceName,
 				TriggerTpe:  generateValidConfig().Trigger.Type,
@@ -168,7 +172,7 @@ func TestTriggerService_createEventGenerated(t1 *testing.T) {
 				events: generateEvents(),
 			},
 			wantEventGenerated: models.EventGenerated{
-				EventID:     "6d52da24f61dda7b9b5ac9ad5ebea7b0",
+				EventID:     "2cc73caf83b13e8686c2ae0e4d9ed429f1783a2e8c9f28faaf2badb2a6ab0ee7",
 				Date:        now,
 				ServiceName: generateValidConfig().ServiceName,
 				TriggerTpe:  generateValidConfig().Trigger.Type,
@@ -201,7 +205,7 @@ func TestTriggerService_createEventGenerated(t1 *testing.T) {
 				events: generateEvents(),
 			},
 			wantEventGenerated: models.EventGenerated{
-				EventID:     "ca92803edad18d2bbf3da3ea90a33ead",
+				EventID:     "9d0a432d56510e0646b95415668bd6a8d6a19c8dbd8d223e71a392d95ab84243",
 				Date:        now,
 				ServiceName: generateValidConfig().ServiceName,
 				TriggerTpe:  generateValidConfig().Trigger.Type,
@@ -222,14 +226,15 @@ func TestTriggerService_createEventGenerated(t1 *testing.T) {
 	}
 	for _, tt := range tests {
 		t1.Run(tt.name, func(t1 *testing.T) {
+			tt.fields.configService.SetClock(FixedClock(now))
 			t := &TriggerService{
 				configService: tt.fields.configService,
 			}
 			gotEventGenerated := t.createEventGenerated(tt.args.events)
-			gotEventGenerated.Date = now
 			if gotEventGenerated.ServiceName != tt.wantEventGenerated.ServiceName ||
 				gotEventGenerated.Date != tt.wantEventGenerated.Date ||
 				gotEventGenerated.TriggerTpe != tt.wantEventGenerated.TriggerTpe ||
+				gotEventGenerated.IDVersion != EventIDVersion ||
 				gotEventGenerated.EventID != tt.wantEventGenerated.EventID {
 				t1.Errorf("createEventGenerated() = %+v, want %+v", gotEventGenerated, tt.wantEventGenerated)
 			} else {
@@ -246,3 +251,131 @@ func TestTriggerService_createEventGenerated(t1 *testing.T) {
 		})
 	}
 }
+
+func TestTriggerService_generateEventID(t *testing.T) {
+	reference := generateEventID("myTest", before, after, []string{"id1", "id2"})
+
+	tests := []struct {
+		name        string
+		serviceName string
+		windowStart time.Time
+		windowEnd   time.Time
+		eventIds    []string
+		wantSame    bool
+	}{
+		{
+			name:        "same input",
+			serviceName: "myTest",
+			windowStart: before,
+			windowEnd:   after,
+			eventIds:    []string{"id1", "id2"},
+			wantSame:    true,
+		},
+		{
+			name:        "order independent",
+			serviceName: "myTest",
+			windowStart: before,
+			windowEnd:   after,
+			eventIds:    []string{"id2", "id1"},
+			wantSame:    true,
+		},
+		{
+			name:        "time zone independent",
+			serviceName: "myTest",
+			windowStart: before.In(time.FixedZone("UTC+2", 2*60*60)),
+			windowEnd:   after,
+			eventIds:    []string{"id1", "id2"},
+			wantSame:    true,
+		},
+		{
+			name:        "no separator collision",
+			serviceName: "myTest",
+			windowStart: before,
+			windowEnd:   after,
+			eventIds:    []string{"id1id2"},
+			wantSame:    false,
+		},
+		{
+			name:        "namespaced by service",
+			serviceName: "otherTest",
+			windowStart: before,
+			windowEnd:   after,
+			eventIds:    []string{"id1", "id2"},
+			wantSame:    false,
+		},
+		{
+			name:        "namespaced by window",
+			serviceName: "myTest",
+			windowStart: now,
+			windowEnd:   after,
+			eventIds:    []string{"id1", "id2"},
+			wantSame:    false,
+		},
+		{
+			name:        "empty set namespaced by window",
+			serviceName: "myTest",
+			windowStart: now,
+			windowEnd:   now,
+			eventIds:    []string{},
+			wantSame:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := generateEventID(tt.serviceName, tt.windowStart, tt.windowEnd, tt.eventIds)
+			if len(got) != 64 {
+				t.Errorf("generateEventID() = %v, want a SHA-256 hex string", got)
+			}
+			if (got == reference) != tt.wantSame {
+				t.Errorf("generateEventID() = %v, reference %v, wantSame %v", got, reference, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestTriggerService_ReplayEvent(t *testing.T) {
+	config := generateValidConfig()
+	// The trigger history is recorded whatever the target
+	config.TargetPubSub = nil
+	configService := &ConfigService{eventSyncConfig: config, clock: FixedClock(now)}
+	store, _ := addMemoryEvents(t, config.ServiceName, []models.Event{
+		{EventKey: "entry1", Datetime: now},
+		{EventKey: "entry2", Datetime: now},
+	})
+	eventService := NewEventService(configService, store)
+	publisher, err := NewWriterPublisher(filepath.Join(t.TempDir(), "messages.jsonl"))
+	if err != nil {
+		t.Fatalf("NewWriterPublisher() error = %v", err)
+	}
+	events, err := eventService.GetEventsOverAPeriod(context.Background(), config.Trigger.ObservationPeriod)
+	if err != nil {
+		t.Fatalf("GetEventsOverAPeriod() error = %v", err)
+	}
+	withHistory := NewTriggerService(configService, eventService, NewHistoryService(configService, eventService), publisher)
+	eventGenerated, err := withHistory.TriggerEvent(context.Background(), events, true)
+	if err != nil {
+		t.Fatalf("TriggerEvent() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		triggerService *TriggerService
+		eventID        string
+		wantErr        error
+	}{
+		{name: "recorded trigger", triggerService: withHistory, eventID: eventGenerated.EventID},
+		{name: "unknown eventID", triggerService: withHistory, eventID: "unknown", wantErr: ErrHistoryNotFound},
+		{name: "no trigger history", triggerService: NewTriggerService(configService, eventService, nil, publisher), eventID: eventGenerated.EventID, wantErr: ErrHistoryUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery, err := tt.triggerService.ReplayEvent(context.Background(), tt.eventID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReplayEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (delivery.Status != models.DeliveryStatusTypeSuccess || !delivery.Replay) {
+				t.Errorf("ReplayEvent() delivery = %+v, want a successful replay", delivery)
+			}
+		})
+	}
+}
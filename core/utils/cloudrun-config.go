@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"golang.org/x/oauth2/google"
 	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"strings"
 )
@@ -19,11 +21,15 @@ const kServiceEnvVar = "K_SERVICE"
 func getCloudRunProjectNumberAndRegion() (projectNumber string, region string, err error) {
 	resp, err := metadata.Get("/instance/region")
 	if err != nil {
-		fmt.Printf("impossible to get the metadata values with error %s\n", err)
+		slog.Warn("impossible to get the metadata values", "error", err)
 		return
 	}
 	// response pattern is projects/<projectNumber>/regions/<region>
 	r := strings.Split(resp, "/")
+	if len(r) != 4 {
+		err = errors.New(fmt.Sprintf("unexpected region metadata value %q", resp))
+		return
+	}
 	projectNumber = r[1]
 	region = r[3]
 	return
@@ -45,39 +51,50 @@ func getCloudRunJsonConfig() (data []byte, err error) {
 	}
 
 	projectNumber, region, err := getCloudRunProjectNumberAndRegion()
+	if err != nil {
+		return
+	}
 
 	ctx := context.Background()
-	client, err := google.DefaultClient(ctx)
+	client, err := google.DefaultClient(ctx, cloudPlatformScope)
+	if err != nil {
+		slog.Warn("impossible to create the Cloud Run Admin API client", "error", err)
+		return
+	}
 
 	cloudRunApi := fmt.Sprintf("https://%s-run.googleapis.com/apis/serving.knative.dev/v1/namespaces/%s/services/%s", region, projectNumber, service)
 	resp, err := client.Get(cloudRunApi)
 
 	if err != nil {
-		fmt.Printf("impossible to get the Cloud Run service configuration by API call with error %s\n", err)
+		slog.Warn("impossible to get the Cloud Run service configuration by API call", "error", err)
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = errors.New(fmt.Sprintf("the Cloud Run Admin API answered with the status %s", resp.Status))
+		return
+	}
 
 	data, err = io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("impossible to read the Cloud Run service configuration with error %s\n", err)
+		slog.Warn("impossible to read the Cloud Run service configuration", "error", err)
 		return
 	}
 	return
 }
 
-// IsCloudRunCPUThrottled checks the current Cloud Run configuration and return is Cloud Run CPU Always ON feature
-// is activated (false) or not (true). If the app does not run on Cloud Run, true (CPU Throttled) is returned by default
-func IsCloudRunCPUThrottled() (throttled bool) {
+// isCloudRunCPUThrottled checks the current Cloud Run configuration and return is Cloud Run CPU Always ON feature
+// is activated (false) or not (true). If the configuration can't be read, true (CPU Throttled) is returned by default
+func isCloudRunCPUThrottled() (throttled bool) {
 	config, err := getCloudRunJsonConfig()
 	if err != nil {
-		fmt.Printf("impossible to get the Cloud Run config. Set the throttled to TRUE by default (thread safe solution)")
+		slog.Info("impossible to get the Cloud Run config. Set the throttled to TRUE by default (thread safe solution)", "error", err)
 		return true
 	}
 	cloudRunResp := &cloudRunAPIAnnotationThrottlingOnly{}
 	err = json.Unmarshal(config, cloudRunResp)
 	if err != nil {
-		fmt.Printf("impossible to get the Cloud Run Cpu Throttling config. Set the throttled to TRUE by default (thread safe solution)")
+		slog.Warn("impossible to get the Cloud Run Cpu Throttling config. Set the throttled to TRUE by default (thread safe solution)", "error", err)
 		return true
 	}
 	data := cloudRunResp.Spec.Template.Metadata.Annotations.RunGoogleapisComCPUThrottling
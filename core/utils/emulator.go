@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"google.golang.org/api/option"
+	"os"
+)
+
+// FirestoreEmulatorHostEnvVar is the host:port of the Firestore emulator. When set, the Firestore clients connect to
+// the emulator, without credentials, and the indexes are not managed
+const FirestoreEmulatorHostEnvVar = "FIRESTORE_EMULATOR_HOST"
+
+// PubSubEmulatorHostEnvVar is the host:port of the Pub/Sub emulator. When set, the Pub/Sub clients connect to the
+// emulator, without credentials
+const PubSubEmulatorHostEnvVar = "PUBSUB_EMULATOR_HOST"
+
+// EmulatorProjectID is the project ID used on the emulators when no project is detected. The demo- prefix is the
+// convention of the projects which exist only in the emulators
+const EmulatorProjectID = "demo-eventsync"
+
+// IsFirestoreEmulator returns true if the Firestore emulator is used
+func IsFirestoreEmulator() bool {
+	return os.Getenv(FirestoreEmulatorHostEnvVar) != ""
+}
+
+// IsPubSubEmulator returns true if the Pub/Sub emulator is used
+func IsPubSubEmulator() bool {
+	return os.Getenv(PubSubEmulatorHostEnvVar) != ""
+}
+
+// GetFirestoreClientOptions returns the options of the Firestore clients: none on the emulator, the client connects
+// to it from the environment variable, else the runtime ones
+func GetFirestoreClientOptions() []option.ClientOption {
+	if IsFirestoreEmulator() {
+		return nil
+	}
+	return GetClientOptions()
+}
+
+// GetPubSubClientOptions returns the options of the Pub/Sub clients: none on the emulator, the client connects to it
+// from the environment variable, else the runtime ones
+func GetPubSubClientOptions() []option.ClientOption {
+	if IsPubSubEmulator() {
+		return nil
+	}
+	return GetClientOptions()
+}
+
+// GetFirestoreProjectID returns the project ID of the Firestore clients: the runtime one, else EmulatorProjectID on
+// the emulator
+func GetFirestoreProjectID() string {
+	projectID := GetRuntime().ProjectID()
+	if projectID == "" && IsFirestoreEmulator() {
+		return EmulatorProjectID
+	}
+	return projectID
+}
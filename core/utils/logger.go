@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// LogLevelEnvVar is the environment variable of the minimal level of the logs: debug, info (default), warning or
+// error
+const LogLevelEnvVar = "LOG_LEVEL"
+
+// LevelCritical is the level of the errors which stop the service
+const LevelCritical = slog.Level(12)
+
+// Cloud Logging special fields, see https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+const (
+	cloudLoggingTraceKey   = "logging.googleapis.com/trace"
+	cloudLoggingSpanKey    = "logging.googleapis.com/spanId"
+	cloudLoggingSampledKey = "logging.googleapis.com/trace_sampled"
+)
+
+// cloudTraceContext matches the X-Cloud-Trace-Context header: TRACE_ID/SPAN_ID;o=OPTIONS
+var cloudTraceContext = regexp.MustCompile(`^([0-9a-fA-F]{32})(?:/(\d+))?(?:;o=(\d))?`)
+
+// traceParent matches the W3C traceparent header: VERSION-TRACE_ID-SPAN_ID-FLAGS
+var traceParent = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// ParseLogLevel returns the level of the name: debug, info, warn or warning, error, critical. Info if the name is empty
+func ParseLogLevel(name string) (level slog.Level, err error) {
+	switch strings.ToUpper(name) {
+	case "":
+		return slog.LevelInfo, nil
+	case "WARNING":
+		return slog.LevelWarn, nil
+	case "CRITICAL":
+		return LevelCritical, nil
+	}
+	if err = level.UnmarshalText([]byte(name)); err != nil {
+		return level, errors.New(fmt.Sprintf("invalid log level %q, must be debug, info, warning or error", name))
+	}
+	return
+}
+
+// NewCloudLoggingHandler creates a JSON log handler with the Cloud Logging fields: severity, message, and the trace
+// and span of the request context (see WithTrace), in the projectID. The attributes of the context (see WithLogAttrs)
+// are added to the records.
+func NewCloudLoggingHandler(w io.Writer, level slog.Leveler, projectID string) slog.Handler {
+	return &cloudLoggingHandler{
+		Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level:       level,
+			ReplaceAttr: replaceCloudLoggingAttr,
+		}),
+		projectID: projectID,
+	}
+}
+
+// cloudLoggingHandler adds the request context trace and attributes to the JSON handler records
+type cloudLoggingHandler struct {
+	slog.Handler
+	projectID string
+}
+
+// Handle adds the trace and the attributes of the context to the record. The record attributes take precedence over
+// the context ones with the same key
+func (h *cloudLoggingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsContextKey{}).([]slog.Attr); ok {
+		keys := make(map[string]bool, record.NumAttrs())
+		record.Attrs(func(attr slog.Attr) bool {
+			keys[attr.Key] = true
+			return true
+		})
+		for _, attr := range attrs {
+			if !keys[attr.Key] {
+				record.AddAttrs(attr)
+			}
+		}
+	}
+	if trace, ok := ctx.Value(traceContextKey{}).(Trace); ok {
+		if h.projectID != "" {
+			record.AddAttrs(slog.String(cloudLoggingTraceKey, fmt.Sprintf("projects/%s/traces/%s", h.projectID, trace.TraceID)))
+		} else {
+			record.AddAttrs(slog.String(cloudLoggingTraceKey, trace.TraceID))
+		}
+		if trace.SpanID != "" {
+			record.AddAttrs(slog.String(cloudLoggingSpanKey, trace.SpanID))
+		}
+		record.AddAttrs(slog.Bool(cloudLoggingSampledKey, trace.Sampled))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the trace and context attributes support of the derived handlers
+func (h *cloudLoggingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &cloudLoggingHandler{Handler: h.Handler.WithAttrs(attrs), projectID: h.projectID}
+}
+
+// WithGroup keeps the trace and context attributes support of the derived handlers
+func (h *cloudLoggingHandler) WithGroup(name string) slog.Handler {
+	return &cloudLoggingHandler{Handler: h.Handler.WithGroup(name), projectID: h.projectID}
+}
+
+// replaceCloudLoggingAttr renames the level and the message keys to the Cloud Logging ones, with the Cloud Logging
+// severity names
+func replaceCloudLoggingAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return attr
+	}
+	switch attr.Key {
+	case slog.MessageKey:
+		attr.Key = "message"
+	case slog.LevelKey:
+		attr.Key = "severity"
+		level, _ := attr.Value.Any().(slog.Level)
+		switch {
+		case level >= LevelCritical:
+			attr.Value = slog.StringValue("CRITICAL")
+		case level >= slog.LevelError:
+			attr.Value = slog.StringValue("ERROR")
+		case level >= slog.LevelWarn:
+			attr.Value = slog.StringValue("WARNING")
+		case level >= slog.LevelInfo:
+			attr.Value = slog.StringValue("INFO")
+		default:
+			attr.Value = slog.StringValue("DEBUG")
+		}
+	}
+	return attr
+}
+
+// Trace is the trace and the span of a request, to correlate its logs in Cloud Logging
+type Trace struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// traceContextKey is the context key of the request Trace
+type traceContextKey struct{}
+
+// logAttrsContextKey is the context key of the log attributes
+type logAttrsContextKey struct{}
+
+// GetRequestTrace extracts the trace of the request from the traceparent header, else from the X-Cloud-Trace-Context
+// header added by Cloud Run. False if the request has no trace.
+func GetRequestTrace(r *http.Request) (trace Trace, ok bool) {
+	if match := traceParent.FindStringSubmatch(r.Header.Get("traceparent")); match != nil {
+		return Trace{TraceID: match[1], SpanID: match[2], Sampled: match[3] == "01"}, true
+	}
+	if match := cloudTraceContext.FindStringSubmatch(r.Header.Get("X-Cloud-Trace-Context")); match != nil {
+		trace = Trace{TraceID: strings.ToLower(match[1]), Sampled: match[3] == "1"}
+		if match[2] != "" {
+			// The Cloud Trace span ID is decimal, Cloud Logging expects hexadecimal
+			var spanID uint64
+			if _, err := fmt.Sscan(match[2], &spanID); err == nil {
+				trace.SpanID = fmt.Sprintf("%016x", spanID)
+			}
+		}
+		return trace, true
+	}
+	return
+}
+
+// WithTrace returns a copy of the context with the trace, added to its logs
+func WithTrace(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// WithLogAttrs returns a copy of the context with the attributes added to its logs, after the existing ones
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(logAttrsContextKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(append(merged, existing...), attrs...)
+	return context.WithValue(ctx, logAttrsContextKey{}, merged)
+}
@@ -1,35 +1,9 @@
 package utils
 
-import (
-	"context"
-	"fmt"
-	"golang.org/x/oauth2/google"
-	"os"
-)
-
 const projectIdKeyEnvVar = "PROJECT_ID"
 
-// GetProjectId extracts the project ID from the server metadata on Google Cloud (through credential) else try to finc
-// the value in the projectIdKeyEnvVar environment variable
+// GetProjectId returns the project ID of the runtime: the value of the projectIdKeyEnvVar environment variable if
+// set, else the runtime one (metadata server, credentials). Empty if not detected
 func GetProjectId() (projectID string) {
-	//Test the GCP environment first
-	ctx := context.Background()
-
-	credentials, err := google.FindDefaultCredentials(ctx)
-	if err != nil {
-		fmt.Printf("impossible to get the default credentials with error %s\n", err)
-		return
-	}
-	projectID = credentials.ProjectID
-
-	//Check the Environment Variables
-	if projectID == "" {
-		projectID = os.Getenv(projectIdKeyEnvVar)
-	}
-
-	if projectID == "" {
-		fmt.Println("WARNING: ProjectID not detected!")
-	}
-
-	return
+	return GetRuntime().ProjectID()
 }
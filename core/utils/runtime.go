@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"cloud.google.com/go/compute/metadata"
+	"context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// RuntimeEnvVar is the environment variable to force the runtime: cloudrun, kubernetes (GKE or Knative) or local.
+// Detected from the environment if not set
+const RuntimeEnvVar = "RUNTIME"
+
+// The runtime names
+const (
+	RuntimeCloudRun   = "cloudrun"
+	RuntimeKubernetes = "kubernetes"
+	RuntimeLocal      = "local"
+)
+
+// kubernetesServiceHostEnvVar is set in all the Kubernetes containers, GKE and Knative included
+const kubernetesServiceHostEnvVar = "KUBERNETES_SERVICE_HOST"
+
+// cloudPlatformScope is the OAuth scope of the credentials, shared by all the Google Cloud clients
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Runtime is the environment on which EventSync runs. It provides the Google Cloud project and credentials, and the
+// default post event processing mode, without calling Google Cloud where it's not available.
+type Runtime interface {
+	// Name returns the name of the runtime, like RuntimeCloudRun
+	Name() string
+	// ProjectID returns the Google Cloud project ID: the PROJECT_ID environment variable, else the runtime one. Empty
+	// if not detected
+	ProjectID() string
+	// ClientOptions returns the options of the Google Cloud clients, with the runtime credentials
+	ClientOptions() []option.ClientOption
+	// DefaultAsyncEventTrigger returns true if the post event processing runs by default after the response, i.e. if
+	// the runtime allocates the CPU outside the requests
+	DefaultAsyncEventTrigger() bool
+}
+
+var (
+	runtimeOnce    sync.Once
+	currentRuntime Runtime
+)
+
+// GetRuntime returns the runtime of the instance, detected at the first call (see DetectRuntime)
+func GetRuntime() Runtime {
+	runtimeOnce.Do(func() {
+		currentRuntime = DetectRuntime()
+	})
+	return currentRuntime
+}
+
+// DetectRuntime returns the runtime of the RUNTIME environment variable, else the runtime detected from the
+// environment variables only: K_SERVICE without Kubernetes is Cloud Run, a Kubernetes container is GKE or Knative,
+// the rest is a local run (laptop, docker-compose)
+func DetectRuntime() Runtime {
+	name := strings.ToLower(os.Getenv(RuntimeEnvVar))
+	if name == "" {
+		switch {
+		case os.Getenv(kubernetesServiceHostEnvVar) != "":
+			name = RuntimeKubernetes
+		case getCloudRunServiceName() != "":
+			name = RuntimeCloudRun
+		default:
+			name = RuntimeLocal
+		}
+	}
+
+	switch name {
+	case RuntimeCloudRun:
+		return &cloudRunRuntime{}
+	case RuntimeKubernetes:
+		return &kubernetesRuntime{}
+	case RuntimeLocal:
+		return &localRuntime{}
+	}
+	slog.Warn("unknown runtime, the local runtime is used", "runtime", name)
+	return &localRuntime{}
+}
+
+// GetClientOptions returns the options of the Google Cloud clients of the runtime
+func GetClientOptions() []option.ClientOption {
+	return GetRuntime().ClientOptions()
+}
+
+// googleRuntime resolves once the project ID and the Application Default Credentials, common to the runtimes
+type googleRuntime struct {
+	projectOnce     sync.Once
+	projectID       string
+	credentialsOnce sync.Once
+	credentials     *google.Credentials
+}
+
+// getProjectID returns the PROJECT_ID environment variable, else the project of the metadata server if available,
+// else the project of the credentials
+func (g *googleRuntime) getProjectID() string {
+	g.projectOnce.Do(func() {
+		if g.projectID = os.Getenv(projectIdKeyEnvVar); g.projectID != "" {
+			return
+		}
+		if metadata.OnGCE() {
+			projectID, err := metadata.ProjectID()
+			if err == nil && projectID != "" {
+				g.projectID = projectID
+				return
+			}
+			slog.Warn("impossible to get the project ID from the metadata server", "error", err)
+		}
+		if credentials := g.getCredentials(); credentials != nil {
+			g.projectID = credentials.ProjectID
+		}
+		if g.projectID == "" {
+			slog.Warn("project ID not detected", "envVar", projectIdKeyEnvVar)
+		}
+	})
+	return g.projectID
+}
+
+// getCredentials returns the Application Default Credentials. Nil if they are not found
+func (g *googleRuntime) getCredentials() *google.Credentials {
+	g.credentialsOnce.Do(func() {
+		credentials, err := google.FindDefaultCredentials(context.Background(), cloudPlatformScope)
+		if err != nil {
+			slog.Warn("impossible to get the default credentials", "error", err)
+			return
+		}
+		g.credentials = credentials
+	})
+	return g.credentials
+}
+
+// clientOptions returns the credentials option, none if the credentials are not found: the clients report the error
+func (g *googleRuntime) clientOptions() []option.ClientOption {
+	if credentials := g.getCredentials(); credentials != nil {
+		return []option.ClientOption{option.WithCredentials(credentials)}
+	}
+	return nil
+}
+
+// cloudRunRuntime is Cloud Run, with the metadata server and the Cloud Run Admin API
+type cloudRunRuntime struct {
+	googleRuntime
+	cpuThrottledOnce sync.Once
+	cpuThrottled     bool
+}
+
+// Name returns RuntimeCloudRun
+func (c *cloudRunRuntime) Name() string {
+	return RuntimeCloudRun
+}
+
+// ProjectID returns the PROJECT_ID environment variable, else the project of the metadata server
+func (c *cloudRunRuntime) ProjectID() string {
+	return c.getProjectID()
+}
+
+// ClientOptions returns the credentials of the service account of the revision
+func (c *cloudRunRuntime) ClientOptions() []option.ClientOption {
+	return c.clientOptions()
+}
+
+// DefaultAsyncEventTrigger returns true if the CPU Always ON feature is activated on the service, read once with the
+// Cloud Run Admin API: the revision configuration doesn't change during the instance life
+func (c *cloudRunRuntime) DefaultAsyncEventTrigger() bool {
+	c.cpuThrottledOnce.Do(func() {
+		c.cpuThrottled = isCloudRunCPUThrottled()
+	})
+	return !c.cpuThrottled
+}
+
+// kubernetesRuntime is GKE or Knative: the pods keep their CPU after the responses
+type kubernetesRuntime struct {
+	googleRuntime
+}
+
+// Name returns RuntimeKubernetes
+func (k *kubernetesRuntime) Name() string {
+	return RuntimeKubernetes
+}
+
+// ProjectID returns the PROJECT_ID environment variable, else the project of the GKE metadata server, if any
+func (k *kubernetesRuntime) ProjectID() string {
+	return k.getProjectID()
+}
+
+// ClientOptions returns the credentials of the workload identity or of the node
+func (k *kubernetesRuntime) ClientOptions() []option.ClientOption {
+	return k.clientOptions()
+}
+
+// DefaultAsyncEventTrigger returns true, the CPU of the pods is not throttled outside the requests
+func (k *kubernetesRuntime) DefaultAsyncEventTrigger() bool {
+	return true
+}
+
+// localRuntime is a laptop or a docker-compose: no metadata server, nor Cloud Run API
+type localRuntime struct {
+	googleRuntime
+}
+
+// Name returns RuntimeLocal
+func (l *localRuntime) Name() string {
+	return RuntimeLocal
+}
+
+// ProjectID returns the PROJECT_ID environment variable only. The credentials are not looked up, the local runs don't
+// require Google Cloud
+func (l *localRuntime) ProjectID() string {
+	return os.Getenv(projectIdKeyEnvVar)
+}
+
+// ClientOptions returns the credentials of the gcloud application default login or of the
+// GOOGLE_APPLICATION_CREDENTIALS file
+func (l *localRuntime) ClientOptions() []option.ClientOption {
+	return l.clientOptions()
+}
+
+// DefaultAsyncEventTrigger returns false: the trigger outcome is in the responses, easier to debug
+func (l *localRuntime) DefaultAsyncEventTrigger() bool {
+	return false
+}
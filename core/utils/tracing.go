@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TracesExporterEnvVar is the OpenTelemetry environment variable of the spans exporter: none (default), console or otlp
+const TracesExporterEnvVar = "OTEL_TRACES_EXPORTER"
+
+// TracerName is the instrumentation name of the spans of the service
+const TracerName = "eventsync"
+
+// SetupTracing sets the global OpenTelemetry tracer provider of the serviceName and the W3C trace context propagator.
+// The spans are always created, to propagate the trace context, and written on the standard error with the console
+// exporter or sent to an OpenTelemetry collector with the otlp exporter (see TracesExporterEnvVar). The otlp exporter
+// is configured by the standard OTEL_EXPORTER_OTLP_* environment variables.
+func SetupTracing(serviceName string) (err error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	}
+	switch exporter := strings.ToLower(os.Getenv(TracesExporterEnvVar)); exporter {
+	case "", "none":
+	case "console":
+		consoleExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+		if err != nil {
+			return err
+		}
+		// The console exporter is for debugging, the spans are written synchronously to never lose them at exit
+		options = append(options, sdktrace.WithSyncer(consoleExporter))
+	case "otlp":
+		otlpExporter, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			return err
+		}
+		options = append(options, sdktrace.WithBatcher(otlpExporter))
+	default:
+		return errors.New(fmt.Sprintf("unsupported traces exporter %q, must be none, console or otlp", exporter))
+	}
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(options...))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return nil
+}
+
+// ExtractTraceContext returns a copy of the context with the remote span of the request headers: the traceparent
+// header, else the X-Cloud-Trace-Context header added by Cloud Run. The context is unchanged if the request has no
+// valid span.
+func ExtractTraceContext(ctx context.Context, r *http.Request) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	requestTrace, ok := GetRequestTrace(r)
+	if !ok {
+		return ctx
+	}
+	traceID, err := trace.TraceIDFromHex(requestTrace.TraceID)
+	if err != nil {
+		return ctx
+	}
+	spanID, err := trace.SpanIDFromHex(requestTrace.SpanID)
+	if err != nil {
+		return ctx
+	}
+	config := trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, Remote: true}
+	if requestTrace.Sampled {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(config))
+}
+
+// TraceParent returns the W3C traceparent of the span of the context. Empty if the context has no valid span
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// ParseTraceParent returns the span context of the W3C traceparent. False if the traceparent is invalid
+func ParseTraceParent(traceParent string) (spanContext trace.SpanContext, ok bool) {
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceParent})
+	spanContext = trace.SpanContextFromContext(ctx)
+	return spanContext, spanContext.IsValid()
+}